@@ -0,0 +1,130 @@
+package xloading
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/falcolee/xutils/xcli/xcolor"
+	"github.com/falcolee/xutils/xfile"
+)
+
+// Progress 进度条, total <= 0 时只显示已完成的大小
+//
+//	p := xloading.NewProgress(total, "downloading")
+//	p.Add(n)
+//	p.Success()
+type Progress struct {
+	mu       sync.Mutex
+	message  string
+	total    int64
+	current  int64
+	width    int
+	symbol   Symbol
+	writer   io.Writer
+	rendered time.Time
+	stopOnce sync.Once
+}
+
+// NewProgress ...
+func NewProgress(total int64, messages ...string) *Progress {
+	return &Progress{
+		message: strings.Join(messages, " "),
+		total:   total,
+		width:   30,
+		symbol:  Symbol1,
+		writer:  os.Stdout,
+	}
+}
+
+// Symbol 结束字符
+func (t *Progress) Symbol(symbol Symbol) *Progress {
+	t.symbol = symbol
+	return t
+}
+
+// Width 进度条宽度
+func (t *Progress) Width(width int) *Progress {
+	if width > 0 {
+		t.width = width
+	}
+	return t
+}
+
+// Total 开始时未知总大小时可以之后设置
+func (t *Progress) Total(total int64) *Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = total
+	return t
+}
+
+// Set 设置当前进度, 输出间隔至少 100ms
+func (t *Progress) Set(current int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current = current
+	if time.Since(t.rendered) < 100*time.Millisecond && (t.total <= 0 || current < t.total) {
+		return
+	}
+	t.rendered = time.Now()
+	fmt.Fprint(t.writer, "\r\033[0K"+t.line())
+}
+
+// Add 增加进度
+func (t *Progress) Add(n int64) {
+	t.mu.Lock()
+	current := t.current + n
+	t.mu.Unlock()
+	t.Set(current)
+}
+
+// Success ...
+func (t *Progress) Success(messages ...string) {
+	t.stop(true, messages...)
+}
+
+// Fail ...
+func (t *Progress) Fail(messages ...string) {
+	t.stop(false, messages...)
+}
+
+// ----------------------------------------------------------------
+
+// line [=========>          ]  45.00%  4.50MB/10.00MB message
+func (t *Progress) line() string {
+	size := xfile.SizeText(t.current)
+	if t.total <= 0 {
+		return strings.TrimSpace(size + " " + t.message)
+	}
+	percent := float64(t.current) / float64(t.total)
+	if percent > 1 {
+		percent = 1
+	}
+	done := int(percent * float64(t.width))
+	bar := strings.Repeat("=", done)
+	if done < t.width {
+		bar += ">" + strings.Repeat(" ", t.width-done-1)
+	}
+	return strings.TrimSpace(fmt.Sprintf("[%s] %6.2f%% %s/%s %s",
+		bar, percent*100, size, xfile.SizeText(t.total), t.message))
+}
+
+// stop ...
+func (t *Progress) stop(success bool, messages ...string) {
+	t.stopOnce.Do(func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if len(messages) > 0 {
+			t.message = strings.Join(messages, " ")
+		}
+		color, symbol := xcolor.FgGreen, t.symbol.Elements()[0]
+		if !success {
+			color, symbol = xcolor.FgRed, t.symbol.Elements()[1]
+		}
+		fmt.Fprintln(t.writer, "\r\033[0K"+xcolor.New(symbol, t.line()).Fg(color).Text())
+	})
+}
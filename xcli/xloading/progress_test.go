@@ -0,0 +1,35 @@
+package xloading
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := NewProgress(2048, "download").Width(10)
+	p.writer = buf
+	p.Set(512)
+	assert.True(t, strings.HasSuffix(buf.String(), "[==>       ]  25.00% 512.00B/2.00KB download"))
+
+	// 100ms 内不重复输出, 完成时立即输出
+	p.Add(512)
+	assert.False(t, strings.Contains(buf.String(), "50.00%"))
+	p.Add(1024)
+	assert.True(t, strings.Contains(buf.String(), "[==========] 100.00% 2.00KB/2.00KB download"))
+
+	p.Success("done")
+	p.Fail()
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+	assert.True(t, strings.Contains(buf.String(), "done"))
+
+	u := NewProgress(0, "unknown")
+	u.writer = buf
+	u.Set(1024)
+	assert.True(t, strings.HasSuffix(buf.String(), "1.00KB unknown"))
+	u.Total(4096).Fail()
+	assert.True(t, strings.Contains(buf.String(), "25.00%"))
+}
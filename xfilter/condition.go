@@ -1,6 +1,7 @@
 package xfilter
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -46,6 +47,7 @@ func (t *ConditionSingle) Assert(ctx *Context) error {
 // ConditionGroup 群组条件, 包含 Logic 关系
 type ConditionGroup struct {
 	Logic      Logic
+	Threshold  int // LogicAtLeast 至少通过的条件数量
 	Conditions []Condition
 }
 
@@ -55,43 +57,144 @@ func (t *ConditionGroup) Name() string {
 	for _, condition := range t.Conditions {
 		res = append(res, condition.Name())
 	}
-	return fmt.Sprintf(`["%s", "%s"]`, strings.Join(res, `", "`), t.Logic.String())
+	return fmt.Sprintf(`["%s", "%s"]`, strings.Join(res, `", "`), t.logicName())
+}
+
+// logicName atleast 带上数量
+func (t *ConditionGroup) logicName() string {
+	if t.Logic == LogicAtLeast {
+		return fmt.Sprintf("%s %d", t.Logic, t.Threshold)
+	}
+	return t.Logic.String()
 }
 
 // Assert ...
-func (t *ConditionGroup) Assert(ctx *Context) (err error) {
-	// 群组条件需关注条件之间的逻辑关联
-	defer func() {
-		// 返回错误带上条件组名称描述
-		if err != nil {
-			err = fmt.Errorf("group %s, %s", t.Name(), err.Error())
+func (t *ConditionGroup) Assert(ctx *Context) error {
+	ok, errs := t.evaluate(func(condition Condition) error {
+		return condition.Assert(ctx)
+	})
+	if ok {
+		return nil
+	}
+	// 返回错误带上条件组名称描述
+	return &GroupError{Name: t.Name(), Errors: errs}
+}
+
+// evaluate 按逻辑关系依次断言子条件, 结果确定后立即返回, 未断言的条件视为被短路
+func (t *ConditionGroup) evaluate(assert func(Condition) error) (bool, []error) {
+	if t.Logic == LogicAtLeast && t.Threshold <= 0 {
+		return true, nil
+	}
+	passed := 0
+	errs := make([]error, 0)
+	for i, condition := range t.Conditions {
+		err := assert(condition)
+		if err == nil {
+			passed++
+		} else {
+			errs = append(errs, err)
 		}
-	}()
-	for _, condition := range t.Conditions {
-		err = condition.Assert(ctx)
-		if err != nil {
-			if t.Logic == LogicAnd {
-				return
-			} else if t.Logic == LogicOr {
-				continue
+		switch t.Logic {
+		case LogicOr:
+			if err == nil {
+				return true, nil
 			}
-		} else {
-			if t.Logic == LogicAnd {
-				continue
-			} else if t.Logic == LogicOr {
-				return
+		case LogicNot:
+			if err != nil {
+				return true, nil
+			}
+		case LogicNone:
+			if err == nil {
+				return false, []error{fmt.Errorf("condition [%s] pass", condition.Name())}
+			}
+		case LogicAtLeast:
+			if passed >= t.Threshold {
+				return true, nil
 			}
+			if passed+len(t.Conditions)-i-1 < t.Threshold {
+				return false, errs
+			}
+		default:
+			if err != nil {
+				return false, errs
+			}
+		}
+	}
+	switch t.Logic {
+	case LogicOr:
+		// 全部失败时汇总所有错误
+		return false, errs
+	case LogicNot:
+		return false, []error{fmt.Errorf("all conditions pass")}
+	}
+	return true, nil
+}
+
+// ----------------------------------------------------------------
+
+// GroupError 条件组断言失败, Errors 为导致失败的子条件错误
+type GroupError struct {
+	Name   string
+	Errors []error
+}
+
+// Error ...
+func (t *GroupError) Error() string {
+	msgs := make([]string, 0, len(t.Errors))
+	for _, err := range t.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("group %s, %s", t.Name, strings.Join(msgs, "; "))
+}
+
+// Unwrap ...
+func (t *GroupError) Unwrap() []error {
+	return t.Errors
+}
+
+// Is go1.20 之前 errors.Is 不支持 Unwrap() []error, 逐个匹配子条件错误
+func (t *GroupError) Is(target error) bool {
+	for _, err := range t.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As 逐个匹配子条件错误, 同 Is
+func (t *GroupError) As(target interface{}) bool {
+	for _, err := range t.Errors {
+		if errors.As(err, target) {
+			return true
 		}
 	}
-	return
+	return false
 }
 
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 
-// NewConditionSingle 初始化单个条件实例
+// NewConditionSingle 使用默认 Engine 初始化单个条件实例
 func NewConditionSingle(filter []interface{}) (*ConditionSingle, error) {
+	return _defaultEngine.NewConditionSingle(filter)
+}
+
+// NewConditionGroup 使用默认 Engine 初始化群组条件实例
+func NewConditionGroup(filters []interface{}) (Condition, error) {
+	return _defaultEngine.NewConditionGroup(filters)
+}
+
+// NewCondition 使用默认 Engine 初始化复合条件
+func NewCondition(filters []interface{}) (Condition, error) {
+	return _defaultEngine.NewCondition(filters)
+}
+
+// ----------------------------------------------------------------
+
+// NewConditionSingle 初始化单个条件实例
+func (e *Engine) NewConditionSingle(filter []interface{}) (*ConditionSingle, error) {
 	prefix := xjson.Encode(filter)
 	if len(filter) != 3 {
 		return nil, fmt.Errorf("%s: 条件必须有 3 个元素", prefix)
@@ -101,7 +204,7 @@ func NewConditionSingle(filter []interface{}) (*ConditionSingle, error) {
 	if !ok {
 		return nil, fmt.Errorf("%s: 条件的第 1 个元素必须是字符串: %v", prefix, filter[0])
 	}
-	variable, err := NewVariable(variableName)
+	variable, err := e.NewVariable(variableName)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %s", prefix, err.Error())
 	}
@@ -110,7 +213,7 @@ func NewConditionSingle(filter []interface{}) (*ConditionSingle, error) {
 	if !ok {
 		return nil, fmt.Errorf("%s: 条件的第 2 个元素必须是字符串: %v", prefix, filter[1])
 	}
-	operation, err := NewOperation(operationName)
+	operation, err := e.NewOperation(operationName)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %s", prefix, err.Error())
 	}
@@ -127,25 +230,33 @@ func NewConditionSingle(filter []interface{}) (*ConditionSingle, error) {
 }
 
 // NewConditionGroup 初始化群组条件实例
-func NewConditionGroup(filters []interface{}) (Condition, error) {
+func (e *Engine) NewConditionGroup(filters []interface{}) (Condition, error) {
 	prefix := xjson.Encode(filters)
 	if len(filters) == 0 {
 		return nil, fmt.Errorf("%s: 条件组至少要有 1 个条件", prefix)
 	}
 
 	// 条件组间逻辑默认为 and
-	logic := LogicAnd
+	logic, threshold := LogicAnd, 0
 	if s, ok := filters[len(filters)-1].(string); ok {
 		// 如果最后一位元素是字符串
 		logic = ToLogic(s)
 		filters = filters[:len(filters)-1]
-		if len(filters) == 0 {
-			return nil, fmt.Errorf("%s: 条件组至少要有 1 个条件", prefix)
-		}
+	} else if n, ok := parseAtLeast(filters[len(filters)-1]); ok {
+		// ["atleast", 2]
+		logic, threshold = LogicAtLeast, n
+		filters = filters[:len(filters)-1]
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("%s: 条件组至少要有 1 个条件", prefix)
+	}
+	if logic == LogicAtLeast && (threshold < 1 || threshold > len(filters)) {
+		return nil, fmt.Errorf("%s: atleast 的数量必须在 1 到 %d 之间", prefix, len(filters))
 	}
 
 	conditionGroup := &ConditionGroup{
 		Logic:      logic,
+		Threshold:  threshold,
 		Conditions: make([]Condition, 0),
 	}
 	for _, filter := range filters {
@@ -154,7 +265,7 @@ func NewConditionGroup(filters []interface{}) (Condition, error) {
 				"条件组的元素必须是一个数组: %v", xjson.Encode(filter),
 			)
 		}
-		subCondition, err := NewCondition(filter.([]interface{}))
+		subCondition, err := e.NewCondition(filter.([]interface{}))
 		if err != nil {
 			return nil, err
 		}
@@ -164,12 +275,12 @@ func NewConditionGroup(filters []interface{}) (Condition, error) {
 }
 
 // NewCondition 初始化复合条件
-func NewCondition(filters []interface{}) (Condition, error) {
-	conditionSingle, errSingle := NewConditionSingle(filters)
+func (e *Engine) NewCondition(filters []interface{}) (Condition, error) {
+	conditionSingle, errSingle := e.NewConditionSingle(filters)
 	if errSingle == nil {
 		return conditionSingle, nil
 	}
-	conditionGroup, errGroup := NewConditionGroup(filters)
+	conditionGroup, errGroup := e.NewConditionGroup(filters)
 	if errGroup == nil {
 		return conditionGroup, nil
 	}
@@ -0,0 +1,153 @@
+package xfilter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// variableCounter 记录被求值的次数
+type variableCounter struct {
+	name  string
+	value interface{}
+	count *int
+}
+
+func (t *variableCounter) Name() string {
+	return t.name
+}
+
+func (t *variableCounter) Value(ctx *Context) interface{} {
+	*t.count++
+	return t.value
+}
+
+func newCounterEngine(count *int) *Engine {
+	engine := NewEngine()
+	engine.RegisterVariable("count.", func(name string) Variable {
+		// count.1 值为 1
+		return &variableCounter{name: name, value: strings.TrimPrefix(name, "count."), count: count}
+	})
+	return engine
+}
+
+func TestConditionGroupLogic(t *testing.T) {
+	ctx := NewContext()
+	ctx.Set("uid", 7)
+	pass, fail := `["ctx.uid", "=", 7]`, `["ctx.uid", "=", 8]`
+	group := func(logic string, items ...string) string {
+		return "[" + strings.Join(items, ", ") + ", " + logic + "]"
+	}
+	cases := []struct {
+		rule string
+		ok   bool
+	}{
+		{group(`"and"`, pass, pass), true},
+		{group(`"and"`, pass, fail), false},
+		{group(`"or"`, fail, pass), true},
+		{group(`"or"`, fail, fail), false},
+		{group(`"not"`, pass, pass), false},
+		{group(`"not"`, pass, fail), true},
+		{group(`"NOT"`, fail), true},
+		{group(`"none"`, fail, fail), true},
+		{group(`"none"`, fail, pass), false},
+		{group(`["atleast", 2]`, pass, fail, pass), true},
+		{group(`["atleast", 2]`, fail, fail, pass), false},
+		{group(`["atleast", "1"]`, fail, pass), true},
+		{group(`["atleast", 3]`, pass, pass, pass), true},
+		// 未知的逻辑按 and 处理
+		{group(`"xor"`, pass, fail), false},
+	}
+	for _, c := range cases {
+		err := Assert(ctx, c.rule)
+		assert.Equal(t, c.ok, err == nil, c.rule)
+		if res, e := Explain(ctx, c.rule); assert.Nil(t, e) {
+			assert.Equal(t, c.ok, res.Result, c.rule)
+		}
+	}
+
+	for _, rule := range []string{
+		group(`["atleast", 3]`, pass, pass),
+		group(`["atleast", 0]`, pass),
+		`[["atleast", 1]]`,
+		group(`["atleast", 1.5]`, pass),
+	} {
+		assert.NotNil(t, Assert(ctx, rule), rule)
+	}
+}
+
+func TestConditionGroupError(t *testing.T) {
+	ctx := NewContext()
+	ctx.Set("uid", 7)
+
+	// or 全部失败时汇总错误
+	err := Assert(ctx, `[["ctx.uid", "=", 1], ["ctx.uid", "=", 2], "or"]`)
+	groupErr := &GroupError{}
+	assert.True(t, errors.As(err, &groupErr))
+	assert.Equal(t, 2, len(groupErr.Errors))
+	assert.Equal(t, `group ["ctx.uid = 1", "ctx.uid = 2", "or"], condition [ctx.uid = 1] fail; condition [ctx.uid = 2] fail`, err.Error())
+
+	// and 与之前的错误格式一致
+	err = Assert(ctx, `[["ctx.uid", "=", 7], ["ctx.uid", "=", 2], "and"]`)
+	assert.Equal(t, `group ["ctx.uid = 7", "ctx.uid = 2", "and"], condition [ctx.uid = 2] fail`, err.Error())
+
+	err = Assert(ctx, `[["ctx.uid", "=", 7], ["ctx.uid", "=", 2], "none"]`)
+	assert.Equal(t, `group ["ctx.uid = 7", "ctx.uid = 2", "none"], condition [ctx.uid = 7] pass`, err.Error())
+	err = Assert(ctx, `[["ctx.uid", "=", 7], "not"]`)
+	assert.Equal(t, `group ["ctx.uid = 7", "not"], all conditions pass`, err.Error())
+	err = Assert(ctx, `[["ctx.uid", "=", 1], ["ctx.uid", "=", 2], ["ctx.uid", "=", 7], ["atleast", 2]]`)
+	assert.Equal(t, `group ["ctx.uid = 1", "ctx.uid = 2", "ctx.uid = 7", "atleast 2"], condition [ctx.uid = 1] fail; condition [ctx.uid = 2] fail`, err.Error())
+
+	// errors.Is/As 匹配子条件错误
+	errFail := errors.New("fail")
+	_, numErr := strconv.Atoi("x")
+	err = &GroupError{Name: "g", Errors: []error{fmt.Errorf("a: %w", errFail), &GroupError{Name: "sub", Errors: []error{numErr}}}}
+	assert.True(t, errors.Is(err, errFail))
+	assert.False(t, errors.Is(err, strconv.ErrRange))
+	assert.True(t, errors.Is(err, strconv.ErrSyntax))
+	target := &strconv.NumError{}
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, "x", target.Num)
+}
+
+func TestConditionGroupShortCircuit(t *testing.T) {
+	count := 0
+	engine := newCounterEngine(&count)
+	ctx := NewContext()
+	cases := map[string]int{
+		`[["count.1", "=", 1], ["count.2", "=", 1], ["count.3", "=", 1], "or"]`:                      1,
+		`[["count.1", "=", 2], ["count.2", "=", 1], ["count.3", "=", 1], "and"]`:                     1,
+		`[["count.1", "=", 2], ["count.2", "=", 1], ["count.3", "=", 1], "not"]`:                     1,
+		`[["count.1", "=", 1], ["count.2", "=", 1], ["count.3", "=", 1], "none"]`:                    1,
+		`[["count.1", "=", 1], ["count.2", "=", 2], ["count.3", "=", 3], ["atleast", 2]]`:            2,
+		`[["count.1", "=", 2], ["count.2", "=", 1], ["count.3", "=", 3], ["atleast", 2]]`:            2,
+		`[["count.1", "=", 2], ["count.2", "=", 3], ["count.3", "=", 3], ["count.4", "=", 4], "or"]`: 3,
+	}
+	for rule, expect := range cases {
+		count = 0
+		_ = engine.Assert(ctx, rule)
+		assert.Equal(t, expect, count, rule)
+
+		// 求值树中被短路的条件不会求值
+		count = 0
+		res, err := engine.Explain(ctx, rule)
+		assert.Nil(t, err)
+		assert.Equal(t, expect, count, rule)
+		skipped := 0
+		for _, child := range res.Children {
+			if child.Skipped {
+				skipped++
+			}
+		}
+		assert.Equal(t, len(res.Children)-expect, skipped, rule)
+	}
+
+	res, _ := engine.Explain(ctx, `[["count.1", "=", 1], ["count.2", "=", 1], ["atleast", 1]]`)
+	assert.Equal(t, LogicAtLeast, res.Logic)
+	assert.Equal(t, 1, res.Threshold)
+	assert.Equal(t, "atleast 1", res.Name)
+}
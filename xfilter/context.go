@@ -3,12 +3,17 @@ package xfilter
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 // Context filter 上下文
 type Context struct {
-	ctx  context.Context
-	data *sync.Map
+	ctx      context.Context
+	data     *sync.Map
+	item     interface{}      // FilterSlice 当前元素
+	clock    func() time.Time // 当前时间, 为空时使用 Engine 的配置或 time.Now
+	location *time.Location   // 时间变量使用的时区, 为空时使用 Engine 的配置或 time.Local
+	engine   *Engine          // 规则所属的 Engine
 }
 
 // Set 写入数据
@@ -21,6 +26,57 @@ func (t *Context) Get(k string) (interface{}, bool) {
 	return t.data.Load(k)
 }
 
+// SetClock 设置当前时间, 用于固定时刻的规则测试
+func (t *Context) SetClock(clock func() time.Time) {
+	t.clock = clock
+}
+
+// SetLocation 设置时间变量使用的时区
+func (t *Context) SetLocation(location *time.Location) {
+	t.location = location
+}
+
+// Now 按上下文、Engine 的顺序读取时钟与时区
+func (t *Context) Now() time.Time {
+	var now time.Time
+	if t.clock != nil {
+		now = t.clock()
+	} else if t.engine != nil && t.engine.clock != nil {
+		now = t.engine.clock()
+	} else {
+		now = time.Now()
+	}
+	return now.In(t.Location())
+}
+
+// Location ...
+func (t *Context) Location() *time.Location {
+	if t.location != nil {
+		return t.location
+	}
+	if t.engine != nil && t.engine.location != nil {
+		return t.engine.location
+	}
+	return time.Local
+}
+
+// withItem 共享数据并绑定当前元素
+func (t *Context) withItem(item interface{}) *Context {
+	c := *t
+	c.item = item
+	return &c
+}
+
+// withEngine 绑定 Engine 的时钟与时区配置, 没有配置时不复制上下文
+func (t *Context) withEngine(e *Engine) *Context {
+	if e == nil || t.engine == e || e.clock == nil && e.location == nil {
+		return t
+	}
+	c := *t
+	c.engine = e
+	return &c
+}
+
 // Ctx 读取 ctx 数据
 func (t *Context) Ctx(k string) interface{} {
 	return t.ctx.Value(k)
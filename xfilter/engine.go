@@ -0,0 +1,147 @@
+package xfilter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/falcolee/xutils/xjson"
+)
+
+// Engine 规则引擎, 拥有独立的操作符与变量注册表, 不同的规则方言互不影响
+//
+//	engine := xfilter.NewEngine()
+//	engine.RegisterVariable("req.", func(name string) xfilter.Variable { ... })
+//	engine.RegisterOperation(&InCityList{})
+//	err := engine.Assert(ctx, `[["req.header.x", "in_city_list", "east"]]`)
+type Engine struct {
+	operations *OperationFactory
+	variables  *VariableFactory
+	cache      *RuleCache
+	clock      func() time.Time
+	location   *time.Location
+}
+
+// EngineOption ...
+type EngineOption func(*Engine)
+
+// WithClock 时间变量使用的时钟, Context 中的配置优先
+func WithClock(clock func() time.Time) EngineOption {
+	return func(e *Engine) {
+		e.clock = clock
+	}
+}
+
+// WithLocation 时间变量使用的时区, Context 中的配置优先
+func WithLocation(location *time.Location) EngineOption {
+	return func(e *Engine) {
+		e.location = location
+	}
+}
+
+// NewEngine 初始化引擎, 包含内置的操作符与变量
+func NewEngine(opts ...EngineOption) *Engine {
+	e := &Engine{
+		operations: NewOperationFactory(),
+		variables:  NewVariableFactory(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.cache = newRuleCache(1024, e.Compile)
+	return e
+}
+
+// Default 包级别函数使用的默认引擎
+func Default() *Engine {
+	return _defaultEngine
+}
+
+// RegisterOperation 注册操作符, 同名时覆盖, 并清空已缓存的规则
+func (e *Engine) RegisterOperation(operation Operation) {
+	e.operations.Register(operation)
+	e.cache.Purge()
+}
+
+// RegisterVariable 注册变量, name 以 . 结尾时匹配该前缀的全部变量, 并清空已缓存的规则
+func (e *Engine) RegisterVariable(name string, creator VariableCreator) {
+	e.variables.Register(name, creator)
+	e.cache.Purge()
+}
+
+// NewOperation ...
+func (e *Engine) NewOperation(operationName string) (Operation, error) {
+	operation := e.operations.Discovery(operationName)
+	if operation == nil {
+		return nil, fmt.Errorf("无效的操作符 [%s]", operationName)
+	}
+	return operation, nil
+}
+
+// NewVariable ...
+func (e *Engine) NewVariable(name string) (Variable, error) {
+	variable := e.variables.Discovery(name)
+	if variable == nil {
+		return nil, fmt.Errorf("无效的变量 [%s]", name)
+	}
+	return variable, nil
+}
+
+// Compile 解析规则并构建条件树, 正则等预期值只计算一次, 非 [ 开头的规则按表达式编译
+func (e *Engine) Compile(rule string) (*Rule, error) {
+	if isExpr(rule) {
+		return e.CompileExpr(rule)
+	}
+	filters, err := ParseFilter(rule)
+	if err != nil {
+		return nil, err
+	}
+	condition, err := e.NewCondition(filters)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{
+		text:      rule,
+		condition: condition,
+		engine:    e,
+	}, nil
+}
+
+// Assert filter 规则断言, 编译结果按规则文本缓存
+func (e *Engine) Assert(ctx *Context, rule string) error {
+	r, err := e.cache.Compile(rule)
+	if err != nil {
+		return err
+	}
+	return r.Assert(ctx)
+}
+
+// Filter 列表数据过滤
+func (e *Engine) Filter(ctx *Context, list []map[string]interface{}) ([]map[string]interface{}, error) {
+	if len(list) == 0 {
+		return nil, errors.New("empty filter list")
+	}
+	res := make([]map[string]interface{}, 0)
+	for _, v := range list {
+		if _, ok := v["filter"]; !ok {
+			res = append(res, v)
+			continue
+		}
+		rule, ok := v["filter"].(string)
+		if !ok {
+			rule = xjson.Encode(v["filter"])
+		}
+		err := e.Assert(ctx, rule)
+		if err != nil {
+			continue
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+var _defaultEngine = NewEngine()
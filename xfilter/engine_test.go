@@ -0,0 +1,112 @@
+package xfilter
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// operationInCityList 按区域名称匹配城市
+type operationInCityList struct {
+	OperationIn
+	lists map[string][]interface{}
+}
+
+func (t *operationInCityList) Name() string {
+	return "in_city_list"
+}
+
+func (t *operationInCityList) Expect(value interface{}) (interface{}, error) {
+	list, ok := t.lists[fmt.Sprint(value)]
+	if !ok {
+		return nil, fmt.Errorf("unknown city list %v", value)
+	}
+	return list, nil
+}
+
+// variableHeader req.header.xxx 读取请求头
+type variableHeader struct {
+	name string
+	key  string
+}
+
+func (t *variableHeader) Name() string {
+	return t.name
+}
+
+func (t *variableHeader) Value(ctx *Context) interface{} {
+	if req, ok := ctx.Get("req"); ok {
+		return req.(*http.Request).Header.Get(t.key)
+	}
+	return nil
+}
+
+func TestEngine(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterOperation(&operationInCityList{lists: map[string][]interface{}{
+		"east": {"shanghai", "hangzhou"},
+	}})
+	engine.RegisterVariable("req.", func(name string) Variable {
+		if !strings.HasPrefix(name, "req.header.") {
+			return nil
+		}
+		return &variableHeader{name: name, key: strings.TrimPrefix(name, "req.header.")}
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	req.Header.Set("X-City", "hangzhou")
+	ctx := NewContext()
+	ctx.Set("req", req)
+	ctx.Set("uid", 7)
+
+	rule := `[["req.header.x-city", "in_city_list", "east"], ["ctx.uid", ">", 0]]`
+	assert.Nil(t, engine.Assert(ctx, rule))
+	req.Header.Set("X-City", "beijing")
+	assert.NotNil(t, engine.Assert(ctx, rule))
+	assert.NotNil(t, engine.Assert(ctx, `[["req.header.x-city", "in_city_list", "west"]]`))
+	assert.NotNil(t, engine.Assert(ctx, `[["req.body", "=", 1]]`))
+
+	// 默认引擎不受影响
+	assert.NotNil(t, Assert(ctx, rule))
+	_, err := NewOperation("in_city_list")
+	assert.NotNil(t, err)
+	_, err = NewVariable("req.header.x-city")
+	assert.NotNil(t, err)
+	assert.True(t, Default() != engine)
+
+	compiled, err := engine.Compile(rule)
+	assert.Nil(t, err)
+	assert.False(t, compiled.Match(ctx))
+
+	res, err := engine.Filter(ctx, []map[string]interface{}{
+		{"id": 1, "filter": []interface{}{[]interface{}{"req.header.x-city", "=", "beijing"}}},
+		{"id": 2, "filter": []interface{}{[]interface{}{"req.header.x-city", "=", "shanghai"}}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, 1, res[0]["id"])
+}
+
+func TestEngineRegisterPurge(t *testing.T) {
+	engine := NewEngine()
+	ctx := NewContext()
+	ctx.Set("uid", 7)
+	rule := `[["ctx.uid", "=", 7]]`
+	assert.Nil(t, engine.Assert(ctx, rule))
+
+	// 覆盖内置操作符后, 已缓存的规则重新编译
+	engine.RegisterOperation(&operationAlwaysFalse{})
+	assert.NotNil(t, engine.Assert(ctx, rule))
+	assert.Nil(t, Assert(ctx, rule))
+}
+
+type operationAlwaysFalse struct {
+	OperationEqual
+}
+
+func (t *operationAlwaysFalse) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	return false
+}
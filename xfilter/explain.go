@@ -0,0 +1,198 @@
+package xfilter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/falcolee/xutils/xcli/xtable"
+	"github.com/falcolee/xutils/xjson"
+)
+
+// Explanation 规则求值树的节点, 记录每个条件的实际值、预期值与结果
+type Explanation struct {
+	Name      string         `json:"name"`                // 条件描述
+	Logic     Logic          `json:"logic,omitempty"`     // 条件组逻辑
+	Threshold int            `json:"threshold,omitempty"` // atleast 的数量
+	Variable  string         `json:"variable,omitempty"`  // 变量名称
+	Operation string         `json:"operation,omitempty"` // 操作符
+	Value     interface{}    `json:"value"`               // 变量实际值
+	Expect    interface{}    `json:"expect"`              // 预期值
+	Result    bool           `json:"result"`              // 断言结果
+	Skipped   bool           `json:"skipped,omitempty"`   // 是否被短路跳过
+	Children  []*Explanation `json:"children,omitempty"`  // 条件组子节点
+}
+
+// Explain 使用默认 Engine 解释规则求值过程
+func Explain(ctx *Context, rule string) (*Explanation, error) {
+	return _defaultEngine.Explain(ctx, rule)
+}
+
+// Explain 解释规则求值过程, 仅在规则编译失败时返回错误
+func (e *Engine) Explain(ctx *Context, rule string) (*Explanation, error) {
+	r, err := e.cache.Compile(rule)
+	if err != nil {
+		return nil, err
+	}
+	return r.Explain(ctx), nil
+}
+
+// Explain 解释规则求值过程
+func (t *Rule) Explain(ctx *Context) *Explanation {
+	return explainCondition(ctx.withEngine(t.engine), t.condition, false)
+}
+
+// errExplainFail 求值树中子条件失败的占位错误
+var errExplainFail = errors.New("explain: condition fail")
+
+// explainer 可输出求值树的条件
+type explainer interface {
+	explain(ctx *Context, skipped bool) *Explanation
+}
+
+// explainCondition 自定义条件没有实现 explainer 时仅记录结果
+func explainCondition(ctx *Context, condition Condition, skipped bool) *Explanation {
+	if c, ok := condition.(explainer); ok {
+		return c.explain(ctx, skipped)
+	}
+	res := &Explanation{
+		Name:    condition.Name(),
+		Skipped: skipped,
+	}
+	if !skipped {
+		res.Result = condition.Assert(ctx) == nil
+	}
+	return res
+}
+
+// explain ...
+func (t *ConditionSingle) explain(ctx *Context, skipped bool) *Explanation {
+	res := &Explanation{
+		Name:      t.Name(),
+		Variable:  t.Variable.Name(),
+		Operation: t.Operation.Name(),
+		Expect:    explainValue(t.Expect),
+		Skipped:   skipped,
+	}
+	if !skipped {
+		// 变量只求值一次, 保证记录的值与断言使用的值一致
+		value := t.Variable.Value(ctx)
+		res.Value = explainValue(value)
+		res.Result = t.Operation.Assert(ctx, &variableResolved{name: t.Variable.Name(), value: value}, t.Expect)
+	}
+	return res
+}
+
+// explain 与 Assert 相同的短路规则, 被短路的条件标记为 Skipped
+func (t *ConditionGroup) explain(ctx *Context, skipped bool) *Explanation {
+	res := &Explanation{
+		Name:      t.logicName(),
+		Logic:     t.Logic,
+		Threshold: t.Threshold,
+		Skipped:   skipped,
+		Children:  make([]*Explanation, 0, len(t.Conditions)),
+	}
+	if !skipped {
+		res.Result, _ = t.evaluate(func(condition Condition) error {
+			child := explainCondition(ctx, condition, false)
+			res.Children = append(res.Children, child)
+			if !child.Result {
+				return errExplainFail
+			}
+			return nil
+		})
+	}
+	for _, condition := range t.Conditions[len(res.Children):] {
+		res.Children = append(res.Children, explainCondition(ctx, condition, true))
+	}
+	return res
+}
+
+// explainValue 正则等预期值以文本输出
+func explainValue(v interface{}) interface{} {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return v
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// JSON 输出格式化后的 JSON
+func (t *Explanation) JSON() string {
+	// go-json 直接 MarshalIndent 递归结构体时无法结束, 先编码再格式化
+	return xjson.Pretty(xjson.Encode(t))
+}
+
+// String 终端树形输出
+//
+//	[FAIL] and
+//	├── [PASS] ctx.uid > 0 (value: 7)
+//	└── [FAIL] or
+//	    ├── [FAIL] ctx.user.age between [18 60] (value: 70)
+//	    └── [FAIL] ctx.user.vip = true (value: false)
+func (t *Explanation) String() string {
+	b := &strings.Builder{}
+	t.writeTree(b, "", "")
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeTree ...
+func (t *Explanation) writeTree(b *strings.Builder, prefix, childPrefix string) {
+	b.WriteString(prefix + "[" + t.status() + "] " + t.Name)
+	if !t.Skipped && t.Variable != "" {
+		b.WriteString(fmt.Sprintf(" (value: %v)", t.Value))
+	}
+	b.WriteString("\n")
+	for i, child := range t.Children {
+		if i == len(t.Children)-1 {
+			child.writeTree(b, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			child.writeTree(b, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
+
+// Table 以表格形式输出, 层级使用缩进表示
+//
+//	explanation.Table().Style(xtable.Dashed).Render()
+func (t *Explanation) Table() xtable.Table {
+	rows := make([][]string, 0)
+	t.writeRows(&rows, 0)
+	return xtable.New(rows).Header([]string{"condition", "value", "expect", "result"})
+}
+
+// writeRows ...
+func (t *Explanation) writeRows(rows *[][]string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if t.Variable == "" {
+		*rows = append(*rows, []string{indent + t.Name, "", "", t.status()})
+	} else {
+		value := ""
+		if !t.Skipped {
+			value = fmt.Sprintf("%v", t.Value)
+		}
+		*rows = append(*rows, []string{
+			indent + t.Variable + " " + t.Operation,
+			value,
+			fmt.Sprintf("%v", t.Expect),
+			t.status(),
+		})
+	}
+	for _, child := range t.Children {
+		child.writeRows(rows, depth+1)
+	}
+}
+
+// status ...
+func (t *Explanation) status() string {
+	if t.Skipped {
+		return "SKIP"
+	}
+	if t.Result {
+		return "PASS"
+	}
+	return "FAIL"
+}
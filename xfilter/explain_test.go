@@ -0,0 +1,88 @@
+package xfilter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	ctx := newBenchContext()
+	ctx.Set("user", map[string]interface{}{
+		"name": "hello",
+		"city": "shanghai",
+		"age":  70,
+		"vip":  false,
+	})
+	res, err := Explain(ctx, benchRule)
+	assert.Nil(t, err)
+	assert.False(t, res.Result)
+	assert.Equal(t, LogicAnd, res.Logic)
+	assert.Equal(t, 4, len(res.Children))
+
+	uid := res.Children[0]
+	assert.True(t, uid.Result)
+	assert.Equal(t, "ctx.uid", uid.Variable)
+	assert.Equal(t, ">", uid.Operation)
+	assert.Equal(t, 7, uid.Value)
+	assert.Equal(t, "(?i)^h.*o$", res.Children[1].Expect)
+
+	or := res.Children[3]
+	assert.False(t, or.Result)
+	assert.False(t, or.Skipped)
+	assert.Equal(t, 70, or.Children[0].Value)
+	assert.Equal(t, false, or.Children[1].Value)
+
+	// and 遇到失败后短路
+	ctx.Set("uid", 0)
+	res, _ = Explain(ctx, benchRule)
+	assert.False(t, res.Result)
+	assert.False(t, res.Children[0].Result)
+	for _, child := range res.Children[1:] {
+		assert.True(t, child.Skipped)
+		assert.Nil(t, child.Value)
+	}
+	assert.True(t, res.Children[3].Children[0].Skipped)
+
+	// or 遇到成功后短路
+	res, _ = Explain(ctx, `[["ctx.uid", "=", 0], ["ctx.uid", "=", 1], "or"]`)
+	assert.True(t, res.Result)
+	assert.False(t, res.Children[0].Skipped)
+	assert.True(t, res.Children[1].Skipped)
+
+	_, err = Explain(ctx, `[["ctx.uid", "~", 0]]`)
+	assert.NotNil(t, err)
+}
+
+func TestExplainRender(t *testing.T) {
+	ctx := newBenchContext()
+	rule := MustCompile(`[["ctx.uid", ">", 0], [["ctx.user.age", ">", 30], ["ctx.user.vip", "=", true], "or"]]`)
+	res := rule.Explain(ctx)
+	assert.Equal(t, rule.Match(ctx), res.Result)
+
+	tree := res.String()
+	assert.Equal(t, strings.Join([]string{
+		"[FAIL] and",
+		"├── [PASS] ctx.uid > 0 (value: 7)",
+		"└── [FAIL] or",
+		"    ├── [FAIL] ctx.user.age > 30 (value: 20)",
+		"    └── [FAIL] ctx.user.vip = true (value: false)",
+	}, "\n"), tree)
+
+	m := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal([]byte(res.JSON()), &m))
+	assert.Equal(t, "and", m["logic"])
+	assert.Equal(t, false, m["result"])
+	assert.Equal(t, 2, len(m["children"].([]interface{})))
+
+	table := res.Table().Text()
+	assert.Contains(t, table, "ctx.user.vip =")
+	assert.NotContains(t, table, "SKIP")
+
+	ctx.Set("uid", 0)
+	res = rule.Explain(ctx)
+	assert.Contains(t, res.Table().Text(), "SKIP")
+	assert.Contains(t, res.String(), "└── [SKIP] or")
+}
@@ -0,0 +1,764 @@
+package xfilter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 表达式语法, 编译后与 JSON 规则得到相同的条件树
+//
+//	ctx.uid in (1, 2, 3) and (year >= 2023 or ctx.user.name match /l{2}/)
+//	not (ctx.age between 18 and 60) or ctx.vip = true
+//
+// and 优先级高于 or, 括号可以改变优先级, 字符串使用单引号或双引号, /.../ 为正则
+
+// ExprError 表达式解析错误, Pos 为出错位置 (从 1 开始的字符序号)
+type ExprError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+// Error ...
+func (t *ExprError) Error() string {
+	return fmt.Sprintf("表达式第 %d 个字符: %s", t.Pos, t.Msg)
+}
+
+// ParseExpr 将表达式解析为 JSON 规则结构
+func ParseExpr(expr string) ([]interface{}, error) {
+	node, err := parseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	if node.filter != nil {
+		return []interface{}{node.filter, LogicAnd.String()}, nil
+	}
+	return node.filters(), nil
+}
+
+// CompileExpr 使用默认 Engine 编译表达式
+func CompileExpr(expr string) (*Rule, error) {
+	return _defaultEngine.CompileExpr(expr)
+}
+
+// CompileExpr 编译表达式, 变量或操作符无效时同样返回带位置的错误
+func (e *Engine) CompileExpr(expr string) (*Rule, error) {
+	node, err := parseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	condition, err := e.exprCondition(expr, node)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{
+		text:      expr,
+		condition: condition,
+		engine:    e,
+	}, nil
+}
+
+// exprCondition ...
+func (e *Engine) exprCondition(expr string, node *exprNode) (Condition, error) {
+	if node.filter != nil {
+		condition, err := e.NewConditionSingle(node.filter)
+		if err != nil {
+			return nil, &ExprError{Expr: expr, Pos: node.pos, Msg: err.Error()}
+		}
+		return condition, nil
+	}
+	group := &ConditionGroup{
+		Logic:      node.logic,
+		Threshold:  node.threshold,
+		Conditions: make([]Condition, 0, len(node.children)),
+	}
+	for _, child := range node.children {
+		condition, err := e.exprCondition(expr, child)
+		if err != nil {
+			return nil, err
+		}
+		group.Conditions = append(group.Conditions, condition)
+	}
+	return group, nil
+}
+
+// isExpr 非 [ 开头的规则按表达式处理
+func isExpr(rule string) bool {
+	return !strings.HasPrefix(strings.TrimSpace(rule), "[")
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// FormatExpr 将 JSON 规则输出为表达式
+func FormatExpr(rule string) (string, error) {
+	filters, err := ParseFilter(rule)
+	if err != nil {
+		return "", err
+	}
+	return formatFilter(filters, false)
+}
+
+// formatFilter 嵌套的条件组使用括号包裹
+func formatFilter(filter []interface{}, nested bool) (string, error) {
+	if len(filter) == 0 {
+		return "", fmt.Errorf("条件组至少要有 1 个条件")
+	}
+	if _, ok := filter[0].(string); ok {
+		return formatSingle(filter)
+	}
+	logic, threshold := LogicAnd, 0
+	if s, ok := filter[len(filter)-1].(string); ok {
+		logic = ToLogic(s)
+		filter = filter[:len(filter)-1]
+	} else if n, ok := parseAtLeast(filter[len(filter)-1]); ok {
+		logic, threshold = LogicAtLeast, n
+		filter = filter[:len(filter)-1]
+	}
+	if len(filter) == 0 {
+		return "", fmt.Errorf("条件组至少要有 1 个条件")
+	}
+	parts := make([]string, 0, len(filter))
+	for _, v := range filter {
+		sub, ok := v.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("条件组的元素必须是一个数组: %v", v)
+		}
+		s, err := formatFilter(sub, true)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, s)
+	}
+	switch logic {
+	case LogicNot, LogicNone:
+		if len(parts) == 1 {
+			return "not " + parts[0], nil
+		}
+		join := LogicAnd
+		if logic == LogicNone {
+			join = LogicOr
+		}
+		return "not (" + strings.Join(parts, " "+join.String()+" ") + ")", nil
+	case LogicAtLeast:
+		return fmt.Sprintf("atleast(%d, %s)", threshold, strings.Join(parts, ", ")), nil
+	}
+	s := strings.Join(parts, " "+logic.String()+" ")
+	if nested && len(parts) > 1 {
+		s = "(" + s + ")"
+	}
+	return s, nil
+}
+
+// formatSingle ...
+func formatSingle(filter []interface{}) (string, error) {
+	if len(filter) != 3 {
+		return "", fmt.Errorf("条件必须有 3 个元素: %v", filter)
+	}
+	name, _ := filter[0].(string)
+	if !isExprIdent(name) {
+		return "", fmt.Errorf("变量 [%s] 无法转换为表达式", name)
+	}
+	op, ok := filter[1].(string)
+	if !ok || op == "" {
+		return "", fmt.Errorf("条件的第 2 个元素必须是字符串: %v", filter[1])
+	}
+	operand, err := formatOperand(op, filter[2])
+	if err != nil {
+		return "", err
+	}
+	return name + " " + operand, nil
+}
+
+// formatOperand ...
+func formatOperand(op string, v interface{}) (string, error) {
+	if s, ok := v.(string); ok && (op == "match" || op == "not match") && isExprRegex(s) {
+		return op + " " + s, nil
+	}
+	if list, ok := v.([]interface{}); ok && len(list) == 2 {
+		switch op {
+		case "between":
+			lo, err := formatValue(list[0])
+			if err != nil {
+				return "", err
+			}
+			hi, err := formatValue(list[1])
+			if err != nil {
+				return "", err
+			}
+			return op + " " + lo + " and " + hi, nil
+		case "any", "all":
+			if sub, ok := list[0].(string); ok {
+				operand, err := formatOperand(sub, list[1])
+				if err != nil {
+					return "", err
+				}
+				return op + " " + operand, nil
+			}
+		}
+	}
+	value, err := formatValue(v)
+	if err != nil {
+		return "", err
+	}
+	return op + " " + value, nil
+}
+
+// formatValue ...
+func formatValue(v interface{}) (string, error) {
+	switch vv := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(vv), nil
+	case string:
+		return strconv.Quote(vv), nil
+	case float64:
+		return strconv.FormatFloat(vv, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(vv), 'f', -1, 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", vv), nil
+	case []interface{}:
+		parts := make([]string, 0, len(vv))
+		for _, item := range vv {
+			s, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return "(" + strings.Join(parts, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("不支持的值类型: %T", v)
+}
+
+// isExprIdent ...
+func isExprIdent(s string) bool {
+	for i, r := range []rune(s) {
+		if i == 0 && !isIdentStart(r) || i > 0 && !isIdentPart(r) {
+			return false
+		}
+	}
+	return s != "" && !isExprKeyword(s)
+}
+
+// isExprRegex /xxx/ 且中间没有未转义的 /
+func isExprRegex(s string) bool {
+	if len(s) < 3 || s[0] != '/' || s[len(s)-1] != '/' {
+		return false
+	}
+	body := s[1 : len(s)-1]
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+			if i == len(body) {
+				return false
+			}
+		case '/':
+			return false
+		}
+	}
+	return true
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// exprNode 语法树节点, filter 不为空时为单个条件
+type exprNode struct {
+	pos       int
+	filter    []interface{}
+	logic     Logic
+	threshold int
+	children  []*exprNode
+}
+
+// filters 转换为 JSON 规则结构
+func (t *exprNode) filters() []interface{} {
+	if t.filter != nil {
+		return t.filter
+	}
+	res := make([]interface{}, 0, len(t.children)+1)
+	for _, child := range t.children {
+		res = append(res, child.filters())
+	}
+	if t.logic == LogicAtLeast {
+		return append(res, []interface{}{t.logic.String(), float64(t.threshold)})
+	}
+	return append(res, t.logic.String())
+}
+
+// negatedLogics not 作用于条件组时对应的逻辑
+var negatedLogics = map[Logic]Logic{
+	LogicAnd:  LogicNot,
+	LogicOr:   LogicNone,
+	LogicNot:  LogicAnd,
+	LogicNone: LogicOr,
+}
+
+// negate 条件组使用对应的逻辑, 单个条件使用 not 条件组, 双重否定还原为原条件
+// 变量不存在或类型不符时条件的正反两面都不成立, 不能改为相反的操作符
+func (t *exprNode) negate() *exprNode {
+	if t.logic == LogicNot && t.filter == nil && len(t.children) == 1 {
+		return t.children[0]
+	}
+	if negated, ok := negatedLogics[t.logic]; ok && t.filter == nil {
+		return &exprNode{pos: t.pos, logic: negated, children: t.children}
+	}
+	return &exprNode{pos: t.pos, logic: LogicNot, children: []*exprNode{t}}
+}
+
+// ----------------------------------------------------------------
+
+// exprParser 递归下降解析
+//
+//	or         = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | primary
+//	primary    = "(" or ")" | atleast | comparison
+//	atleast    = "atleast" "(" number { "," or } ")"
+//	comparison = ident operation value
+type exprParser struct {
+	expr   string
+	tokens []exprToken
+	i      int
+}
+
+// parseExpr ...
+func parseExpr(expr string) (*exprNode, error) {
+	tokens, err := lexExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{expr: expr, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "多余的内容 %s", tok)
+	}
+	return node, nil
+}
+
+// peek ...
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.i]
+}
+
+// next ...
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.i]
+	if tok.kind != tokenEOF {
+		p.i++
+	}
+	return tok
+}
+
+// errorf ...
+func (p *exprParser) errorf(tok exprToken, format string, args ...interface{}) error {
+	return &ExprError{Expr: p.expr, Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseOr ...
+func (p *exprParser) parseOr() (*exprNode, error) {
+	return p.parseLogic(LogicOr, p.parseAnd)
+}
+
+// parseAnd ...
+func (p *exprParser) parseAnd() (*exprNode, error) {
+	return p.parseLogic(LogicAnd, p.parseUnary)
+}
+
+// parseLogic 同级的连续条件合并为一个条件组
+func (p *exprParser) parseLogic(logic Logic, operand func() (*exprNode, error)) (*exprNode, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek().is(logic.String()) {
+		return first, nil
+	}
+	node := &exprNode{pos: first.pos, logic: logic, children: []*exprNode{first}}
+	for p.peek().is(logic.String()) {
+		p.next()
+		child, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, child)
+	}
+	return node, nil
+}
+
+// parseUnary ...
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	if tok := p.peek(); tok.is("not") {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return node.negate(), nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary ...
+func (p *exprParser) parsePrimary() (*exprNode, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != tokenRParen {
+			return nil, p.errorf(end, "缺少右括号, 得到 %s", end)
+		}
+		return node, nil
+	case tok.is("atleast") && p.peek().kind == tokenLParen:
+		return p.parseAtLeast(tok)
+	case tok.kind == tokenIdent && !isExprKeyword(tok.text):
+		return p.parseComparison(tok)
+	}
+	return nil, p.errorf(tok, "需要变量或左括号, 得到 %s", tok)
+}
+
+// parseAtLeast atleast(2, a = 1, b = 2, c = 3)
+func (p *exprParser) parseAtLeast(start exprToken) (*exprNode, error) {
+	p.next()
+	num := p.next()
+	n, ok := num.value.(float64)
+	if !ok || n != float64(int(n)) {
+		return nil, p.errorf(num, "atleast 需要整数, 得到 %s", num)
+	}
+	node := &exprNode{pos: start.pos, logic: LogicAtLeast, threshold: int(n)}
+	for {
+		sep := p.next()
+		if sep.kind == tokenRParen {
+			break
+		}
+		if sep.kind != tokenComma {
+			return nil, p.errorf(sep, "atleast 中需要逗号或右括号, 得到 %s", sep)
+		}
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, child)
+	}
+	if node.threshold < 1 || node.threshold > len(node.children) {
+		return nil, p.errorf(num, "atleast 的数量必须在 1 到 %d 之间", len(node.children))
+	}
+	return node, nil
+}
+
+// parseComparison ...
+func (p *exprParser) parseComparison(variable exprToken) (*exprNode, error) {
+	op, err := p.parseOperation()
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.parseOperand(op)
+	if err != nil {
+		return nil, err
+	}
+	return &exprNode{
+		pos:    variable.pos,
+		filter: []interface{}{variable.text, op, value},
+	}, nil
+}
+
+// parseOperation 多个单词组成的操作符合并为一个, 如 not in、starts with、len >
+func (p *exprParser) parseOperation() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenOperation:
+		return tok.text, nil
+	case tokenIdent:
+	default:
+		return "", p.errorf(tok, "需要操作符, 得到 %s", tok)
+	}
+	word := strings.ToLower(tok.text)
+	switch word {
+	case "not":
+		if sub := p.peek(); sub.is("in") || sub.is("match") || sub.is("cidr") {
+			p.next()
+			return "not " + strings.ToLower(sub.text), nil
+		}
+		return "", p.errorf(p.peek(), "not 之后需要 in、match 或 cidr, 得到 %s", p.peek())
+	case "starts", "ends":
+		if sub := p.peek(); sub.is("with") {
+			p.next()
+			return word + " with", nil
+		}
+		return "", p.errorf(p.peek(), "%s 之后需要 with, 得到 %s", word, p.peek())
+	case "len", "semver", "datetime":
+		if sub := p.peek(); sub.kind == tokenOperation {
+			p.next()
+			return word + " " + sub.text, nil
+		}
+		return "", p.errorf(p.peek(), "%s 之后需要比较符号, 得到 %s", word, p.peek())
+	case "in", "match", "between", "has", "exists", "empty", "cidr", "any", "all":
+		return word, nil
+	}
+	if isExprKeyword(tok.text) {
+		return "", p.errorf(tok, "需要操作符, 得到 %s", tok)
+	}
+	return tok.text, nil
+}
+
+// parseOperand 解析操作符的预期值, any/all 的预期值为 [操作符, 预期值]
+func (p *exprParser) parseOperand(op string) (interface{}, error) {
+	if op == "any" || op == "all" {
+		sub, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.parseOperand(sub)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{sub, value}, nil
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	// between 1 and 10
+	if op == "between" && p.peek().is("and") {
+		if _, ok := value.([]interface{}); !ok {
+			p.next()
+			hi, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			value = []interface{}{value, hi}
+		}
+	}
+	return value, nil
+}
+
+// parseValue ...
+func (p *exprParser) parseValue() (interface{}, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber, tokenString, tokenRegex:
+		return tok.value, nil
+	case tokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, p.errorf(tok, "字符串需要使用引号: %s", tok)
+	case tokenLParen, tokenLBrack:
+		end := tokenRParen
+		if tok.kind == tokenLBrack {
+			end = tokenRBrack
+		}
+		list := make([]interface{}, 0)
+		if p.peek().kind == end {
+			p.next()
+			return list, nil
+		}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			sep := p.next()
+			if sep.kind == end {
+				return list, nil
+			}
+			if sep.kind != tokenComma {
+				return nil, p.errorf(sep, "列表中需要逗号或结束括号, 得到 %s", sep)
+			}
+		}
+	}
+	return nil, p.errorf(tok, "需要值, 得到 %s", tok)
+}
+
+// isExprKeyword ...
+func isExprKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "in", "match", "between", "has", "true", "false", "null":
+		return true
+	}
+	return false
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// exprTokenKind ...
+type exprTokenKind int
+
+// ...
+const (
+	tokenEOF exprTokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenRegex
+	tokenOperation
+	tokenLParen
+	tokenRParen
+	tokenLBrack
+	tokenRBrack
+	tokenComma
+)
+
+// exprToken ...
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// is 关键字判断, 不区分大小写
+func (t exprToken) is(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+// String ...
+func (t exprToken) String() string {
+	if t.kind == tokenEOF {
+		return "结尾"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lexExpr ...
+func lexExpr(expr string) ([]exprToken, error) {
+	rs := []rune(expr)
+	tokens := make([]exprToken, 0)
+	errorf := func(pos int, format string, args ...interface{}) error {
+		return &ExprError{Expr: expr, Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+	}
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, exprToken{kind: tokenLParen, text: "(", pos: i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, exprToken{kind: tokenRParen, text: ")", pos: i + 1})
+			i++
+		case r == '[':
+			tokens = append(tokens, exprToken{kind: tokenLBrack, text: "[", pos: i + 1})
+			i++
+		case r == ']':
+			tokens = append(tokens, exprToken{kind: tokenRBrack, text: "]", pos: i + 1})
+			i++
+		case r == ',':
+			tokens = append(tokens, exprToken{kind: tokenComma, text: ",", pos: i + 1})
+			i++
+		case r == '=' || r == '!' || r == '<' || r == '>':
+			i++
+			if i < len(rs) && rs[i] == '=' {
+				i++
+			}
+			op := string(rs[start:i])
+			if op == "!" {
+				return nil, errorf(start, "无效的操作符 !, 是否应为 !=")
+			}
+			if op == "==" {
+				op = "="
+			}
+			tokens = append(tokens, exprToken{kind: tokenOperation, text: op, pos: start + 1})
+		case r == '"' || r == '\'':
+			i++
+			for i < len(rs) && rs[i] != r {
+				if rs[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(rs) {
+				return nil, errorf(start, "字符串缺少结束引号")
+			}
+			i++
+			text := string(rs[start:i])
+			body := text
+			if r == '\'' {
+				// 单引号字符串转换为双引号后解析转义
+				inner := string(rs[start+1 : i-1])
+				inner = strings.ReplaceAll(inner, `\'`, `'`)
+				inner = strings.ReplaceAll(inner, `"`, `\"`)
+				body = `"` + inner + `"`
+			}
+			s, err := strconv.Unquote(body)
+			if err != nil {
+				return nil, errorf(start, "无效的字符串 %s", text)
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: text, value: s, pos: start + 1})
+		case r == '/':
+			i++
+			for i < len(rs) && rs[i] != '/' {
+				if rs[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(rs) {
+				return nil, errorf(start, "正则缺少结束的 /")
+			}
+			i++
+			text := string(rs[start:i])
+			if text == "//" {
+				return nil, errorf(start, "正则不能为空")
+			}
+			tokens = append(tokens, exprToken{kind: tokenRegex, text: text, value: text, pos: start + 1})
+		case r == '-' || unicode.IsDigit(r):
+			i++
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.' || rs[i] == 'e' || rs[i] == 'E' ||
+				(rs[i] == '-' || rs[i] == '+') && (rs[i-1] == 'e' || rs[i-1] == 'E')) {
+				i++
+			}
+			text := string(rs[start:i])
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, errorf(start, "无效的数字 %s", text)
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: text, value: f, pos: start + 1})
+		case isIdentStart(r):
+			i++
+			for i < len(rs) && isIdentPart(rs[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: string(rs[start:i]), pos: start + 1})
+		default:
+			return nil, errorf(start, "无效的字符 %q", r)
+		}
+	}
+	tokens = append(tokens, exprToken{kind: tokenEOF, pos: len(rs) + 1})
+	return tokens, nil
+}
+
+// isIdentStart ...
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// isIdentPart 变量名可以包含 . - +, 例如 ctx.user.nums.1 req.header.x-city now+1h
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '+'
+}
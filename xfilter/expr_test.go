@@ -0,0 +1,232 @@
+package xfilter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpr(t *testing.T) {
+	filters, err := ParseExpr(`ctx.uid in (1,2,3) and (year >= 2023 or ctx.user.name match /l{2}/)`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{"ctx.uid", "in", []interface{}{float64(1), float64(2), float64(3)}},
+		[]interface{}{
+			[]interface{}{"year", ">=", float64(2023)},
+			[]interface{}{"ctx.user.name", "match", "/l{2}/"},
+			"or",
+		},
+		"and",
+	}, filters)
+
+	// and 优先级高于 or
+	filters, err = ParseExpr(`a = 1 or b = 'x' and c != "y\n"`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{"a", "=", float64(1)},
+		[]interface{}{
+			[]interface{}{"b", "=", "x"},
+			[]interface{}{"c", "!=", "y\n"},
+			"and",
+		},
+		"or",
+	}, filters)
+
+	filters, err = ParseExpr(`ctx.age between 18 and 60 AND ctx.tags has ["a", 'b'] and ctx.x NOT IN (-1.5, true, null) and ctx.n == 1`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{"ctx.age", "between", []interface{}{float64(18), float64(60)}},
+		[]interface{}{"ctx.tags", "has", []interface{}{"a", "b"}},
+		[]interface{}{"ctx.x", "not in", []interface{}{-1.5, true, nil}},
+		[]interface{}{"ctx.n", "=", float64(1)},
+		"and",
+	}, filters)
+
+	filters, err = ParseExpr(`req.header.x-city in_city_list "east"`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{"req.header.x-city", "in_city_list", "east"}, "and"}, filters)
+}
+
+func TestParseExprNot(t *testing.T) {
+	filters, err := ParseExpr(`not (a = 1 and b in (1, 2)) or not c match "x" or not (d = 1 or e = 2)`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{
+			[]interface{}{"a", "=", float64(1)},
+			[]interface{}{"b", "in", []interface{}{float64(1), float64(2)}},
+			"not",
+		},
+		[]interface{}{[]interface{}{"c", "match", "x"}, "not"},
+		[]interface{}{
+			[]interface{}{"d", "=", float64(1)},
+			[]interface{}{"e", "=", float64(2)},
+			"none",
+		},
+		"or",
+	}, filters)
+
+	filters, err = ParseExpr(`not not a > 1 and not a between 1 and 5 and not not (a = 1 or b = 1)`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{"a", ">", float64(1)},
+		[]interface{}{
+			[]interface{}{"a", "between", []interface{}{float64(1), float64(5)}},
+			"not",
+		},
+		[]interface{}{
+			[]interface{}{"a", "=", float64(1)},
+			[]interface{}{"b", "=", float64(1)},
+			"or",
+		},
+		"and",
+	}, filters)
+
+	filters, err = ParseExpr(`atleast(2, a = 1, b = 2 or c = 3, d has (1)) and not atleast(1, e = 1)`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{
+			[]interface{}{"a", "=", float64(1)},
+			[]interface{}{
+				[]interface{}{"b", "=", float64(2)},
+				[]interface{}{"c", "=", float64(3)},
+				"or",
+			},
+			[]interface{}{"d", "has", []interface{}{float64(1)}},
+			[]interface{}{"atleast", float64(2)},
+		},
+		[]interface{}{
+			[]interface{}{
+				[]interface{}{"e", "=", float64(1)},
+				[]interface{}{"atleast", float64(1)},
+			},
+			"not",
+		},
+		"and",
+	}, filters)
+
+	// 变量不存在或类型不符时与 JSON 规则的 not 结果一致
+	ctx := newBenchContext()
+	for expr, rule := range map[string]string{
+		`not ctx.user.missing > 18`:                            `[[["ctx.user.missing", ">", 18], "not"]]`,
+		`not ctx.user.name > 18`:                               `[[["ctx.user.name", ">", 18], "not"]]`,
+		`not ctx.user.name in (1, 2)`:                          `[[["ctx.user.name", "in", [1, 2]], "not"]]`,
+		`not (ctx.user.missing = 1 and ctx.uid > 0)`:           `[[[["ctx.user.missing", "=", 1], ["ctx.uid", ">", 0], "and"], "not"]]`,
+		`not (ctx.user.missing = 1 or ctx.user.name < 1)`:      `[[[["ctx.user.missing", "=", 1], ["ctx.user.name", "<", 1], "or"], "not"]]`,
+		`not not (ctx.user.missing = 1 or ctx.uid > 0)`:        `[[[[["ctx.user.missing", "=", 1], ["ctx.uid", ">", 0], "or"], "not"], "not"]]`,
+		`not not (ctx.user.missing != 1 and ctx.user.age > 0)`: `[[[[["ctx.user.missing", "!=", 1], ["ctx.user.age", ">", 0], "and"], "not"], "not"]]`,
+	} {
+		assert.Equal(t, MustCompile(rule).Match(ctx), MustCompile(expr).Match(ctx), expr)
+		assert.True(t, MustCompile(expr).Match(ctx) != MustCompile(strings.TrimPrefix(expr, "not ")).Match(ctx), expr)
+	}
+
+	for expr, pos := range map[string]int{
+		`atleast(3, a = 1, b = 1)`: 9,
+		`atleast(a = 1)`:           9,
+		`atleast(1.5, a = 1)`:      9,
+		`atleast(1, a = 1 b = 1)`:  18,
+	} {
+		_, err = ParseExpr(expr)
+		e := &ExprError{}
+		if assert.True(t, errors.As(err, &e), expr) {
+			assert.Equal(t, pos, e.Pos, expr)
+		}
+	}
+}
+
+func TestParseExprError(t *testing.T) {
+	cases := map[string]int{
+		``:                     1,
+		`a`:                    2,
+		`a = `:                 5,
+		`(a = 1`:               7,
+		`a = 1)`:               6,
+		`a = 1 and`:            10,
+		`a = hello`:            5,
+		`a ! 1`:                3,
+		`a = "x`:               5,
+		`a = /x`:               5,
+		`a in (1 2)`:           9,
+		`a not like "x"`:       7,
+		`a = 1 or and b = 1`:   10,
+		`中文 = 1 and b = 1.2.3`: 16,
+		`a = 1 # b`:            7,
+	}
+	for expr, pos := range cases {
+		_, err := ParseExpr(expr)
+		e := &ExprError{}
+		if assert.True(t, errors.As(err, &e), expr) {
+			assert.Equal(t, pos, e.Pos, expr)
+			assert.Contains(t, err.Error(), "表达式第")
+		}
+	}
+
+	// 变量与操作符在编译时校验
+	_, err := CompileExpr(`ctx.uid = 1 and unknown = 2`)
+	e := &ExprError{}
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, 17, e.Pos)
+	_, err = CompileExpr(`ctx.uid = 1 or ctx.uid ~= 2`)
+	assert.NotNil(t, err)
+	_, err = CompileExpr(`ctx.uid in_city_list "east"`)
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, 1, e.Pos)
+}
+
+func TestCompileExpr(t *testing.T) {
+	ctx := newBenchContext()
+	rule, err := CompileExpr(`ctx.uid in (1, 7) and (year >= 2000 or ctx.user.name match /L{2}/) and not ctx.user.vip = true`)
+	assert.Nil(t, err)
+	assert.True(t, rule.Match(ctx))
+	ctx.Set("uid", 8)
+	assert.False(t, rule.Match(ctx))
+
+	// Assert 与 Filter 同样支持表达式
+	assert.Nil(t, Assert(ctx, `ctx.user.city = "shanghai" and ctx.user.age between 18 and 60`))
+	assert.NotNil(t, Assert(ctx, `ctx.user.city = "beijing"`))
+	res, err := Filter(ctx, []map[string]interface{}{
+		{"id": 1, "filter": `ctx.uid = 8`},
+		{"id": 2, "filter": `ctx.uid != 8`},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, 1, res[0]["id"])
+
+	// 与 JSON 规则结果一致
+	expr := `ctx.uid > 0 and ctx.user.name match /^h.*o$/ and ctx.user.city in ("shanghai", "beijing") and (ctx.user.age between 18 and 60 or ctx.user.vip = true)`
+	rule = MustCompile(expr)
+	assert.Equal(t, expr, rule.String())
+	assert.Equal(t, MustCompile(benchRule).Match(ctx), rule.Match(ctx))
+}
+
+func TestFormatExpr(t *testing.T) {
+	s, err := FormatExpr(benchRule)
+	assert.Nil(t, err)
+	assert.Equal(t, `ctx.uid > 0 and ctx.user.name match /^h.*o$/ and ctx.user.city in ("shanghai", "beijing") and (ctx.user.age between 18 and 60 or ctx.user.vip = true)`, s)
+
+	s, err = FormatExpr(`[[["a", "=", 1], ["b", "=", 2], "none"], [["c", "has", [1]], "not"], [["a", "=", 1], ["b", "=", 2], ["atleast", 1]], "or"]`)
+	assert.Nil(t, err)
+	assert.Equal(t, `not (a = 1 or b = 2) or not c has (1) or atleast(1, a = 1, b = 2)`, s)
+
+	s, err = FormatExpr(`[["ctx.uid", "between", "5, 10"], ["ctx.name", "not match", "a/b"], ["ctx.x", "=", null], "or"]`)
+	assert.Nil(t, err)
+	assert.Equal(t, `ctx.uid between "5, 10" or ctx.name not match "a/b" or ctx.x = null`, s)
+
+	// 往返转换结构不变
+	for _, rule := range []string{benchRule, `[[["a", "=", 1], [["b", "<", 2], ["c", "in", [1, "x"]], "and"], "or"], ["d", "has", [1]], "and"]`,
+		`[[["a", "=", 1], [["b", "<", 2], ["c", "=", 1], "or"], "not"], [["d", "has", [1]], "not"], [["a", "=", 1], ["b", "=", 2], ["atleast", 2]], "and"]`,
+	} {
+		s, err := FormatExpr(rule)
+		assert.Nil(t, err)
+		filters, err := ParseExpr(s)
+		assert.Nil(t, err)
+		expect, _ := ParseFilter(rule)
+		assert.Equal(t, expect, filters)
+	}
+
+	for _, rule := range []string{`[]`, `[["a b", "=", 1]]`, `[["a", "=", {"x": 1}]]`, `[["a", "="]]`, `[[1, 2], "and"]`, `["and"]`} {
+		_, err := FormatExpr(rule)
+		assert.NotNil(t, err, rule)
+	}
+}
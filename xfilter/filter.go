@@ -1,12 +1,9 @@
 package xfilter
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/goccy/go-json"
-
-	"github.com/falcolee/xutils/xjson"
 )
 
 // ParseFilter 解析 filter 规则
@@ -21,35 +18,12 @@ func ParseFilter(rule string) ([]interface{}, error) {
 	return res, nil
 }
 
-// Assert filter 规则断言
+// Assert 使用默认 Engine 断言, 编译结果按规则文本缓存
 func Assert(ctx *Context, rule string) error {
-	filters, err := ParseFilter(rule)
-	if err != nil {
-		return err
-	}
-	condition, err := NewCondition(filters)
-	if err != nil {
-		return err
-	}
-	return condition.Assert(ctx)
+	return _defaultEngine.Assert(ctx, rule)
 }
 
-// Filter 列表数据过滤
+// Filter 使用默认 Engine 过滤列表数据
 func Filter(ctx *Context, list []map[string]interface{}) ([]map[string]interface{}, error) {
-	if len(list) == 0 {
-		return nil, errors.New("empty filter list")
-	}
-	res := make([]map[string]interface{}, 0)
-	for _, v := range list {
-		if _, ok := v["filter"]; !ok {
-			res = append(res, v)
-			continue
-		}
-		err := Assert(ctx, xjson.Encode(v["filter"]))
-		if err != nil {
-			continue
-		}
-		res = append(res, v)
-	}
-	return res, nil
+	return _defaultEngine.Filter(ctx, list)
 }
@@ -3,6 +3,7 @@ package xfilter
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/goccy/go-json"
 
@@ -90,6 +91,9 @@ func TestAssert(t *testing.T) {
 	c = context.WithValue(c, "trace_id", "123456")
 
 	ctx := NewContext(c)
+	ctx.SetClock(func() time.Time {
+		return time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC)
+	})
 	ctx.Set("uid", 7)
 	ctx.Set("user", map[string]interface{}{
 		"name": "hello",
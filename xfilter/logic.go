@@ -1,6 +1,7 @@
 package xfilter
 
 import (
+	"strconv"
 	"strings"
 )
 
@@ -9,8 +10,11 @@ type Logic string
 
 // ...
 const (
-	LogicAnd Logic = "and"
-	LogicOr  Logic = "or"
+	LogicAnd     Logic = "and"     // 全部通过
+	LogicOr      Logic = "or"      // 任一通过
+	LogicNot     Logic = "not"     // 不是全部通过, 即 not (a and b)
+	LogicNone    Logic = "none"    // 全部不通过, 即 not (a or b)
+	LogicAtLeast Logic = "atleast" // 至少 N 个通过, JSON 中写作 ["atleast", N]
 )
 
 // String ...
@@ -23,7 +27,35 @@ func ToLogic(s string) Logic {
 	switch strings.ToLower(s) {
 	case LogicOr.String():
 		return LogicOr
+	case LogicNot.String():
+		return LogicNot
+	case LogicNone.String():
+		return LogicNone
 	default:
 		return LogicAnd
 	}
 }
+
+// parseAtLeast 解析 ["atleast", N]
+func parseAtLeast(v interface{}) (int, bool) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) != 2 {
+		return 0, false
+	}
+	if s, ok := list[0].(string); !ok || strings.ToLower(s) != LogicAtLeast.String() {
+		return 0, false
+	}
+	switch n := list[1].(type) {
+	case float64:
+		if n == float64(int(n)) {
+			return int(n), true
+		}
+	case int:
+		return n, true
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -2,11 +2,19 @@ package xfilter
 
 import (
 	"fmt"
+	"net"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/falcolee/xutils/xip"
+	"github.com/falcolee/xutils/xtime"
 	"github.com/falcolee/xutils/xtype"
 	"github.com/falcolee/xutils/xutil"
+	"github.com/falcolee/xutils/xversion"
 )
 
 // Operation 操作接口
@@ -22,16 +30,54 @@ type Operation interface {
 
 // OperationFactory 操作实例工厂
 type OperationFactory struct {
+	mu         sync.RWMutex
 	operations map[string]Operation
 }
 
-// Register 注册操作实例
+// NewOperationFactory 初始化包含内置操作符的工厂
+func NewOperationFactory() *OperationFactory {
+	t := &OperationFactory{
+		operations: make(map[string]Operation),
+	}
+	t.Register(&OperationEqual{})      // =
+	t.Register(&OperationEqualNot{})   // !=
+	t.Register(&OperationEqualGT{})    // >
+	t.Register(&OperationEqualGTE{})   // >=
+	t.Register(&OperationEqualLT{})    // <
+	t.Register(&OperationEqualLTE{})   // <=
+	t.Register(&OperationBetween{})    // between
+	t.Register(&OperationIn{})         // in
+	t.Register(&OperationNotIn{})      // not in
+	t.Register(&OperationMatch{})      // match
+	t.Register(&OperationNotMatch{})   // not match
+	t.Register(&OperationHas{})        // has
+	t.Register(&OperationStartsWith{}) // starts with
+	t.Register(&OperationEndsWith{})   // ends with
+	t.Register(&OperationExists{})     // exists
+	t.Register(&OperationEmpty{})      // empty
+	t.Register(&OperationCIDR{})       // cidr
+	t.Register(&OperationNotCIDR{})    // not cidr
+	for _, symbol := range compareSymbols {
+		t.Register(&OperationLength{symbol: symbol})   // len >
+		t.Register(&OperationSemver{symbol: symbol})   // semver >
+		t.Register(&OperationDatetime{symbol: symbol}) // datetime >
+	}
+	t.Register(&OperationAny{factory: t})               // any
+	t.Register(&OperationAll{OperationAny{factory: t}}) // all
+	return t
+}
+
+// Register 注册操作实例, 同名的操作会被覆盖
 func (t *OperationFactory) Register(operation Operation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.operations[operation.Name()] = operation
 }
 
 // Discovery 发现操作实例
 func (t *OperationFactory) Discovery(name string) Operation {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 	if operation, ok := t.operations[name]; ok {
 		return operation
 	}
@@ -334,35 +380,423 @@ func (t *OperationNotMatch) Assert(ctx *Context, variable Variable, expect inter
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 
-// NewOperation ...
-func NewOperation(operationName string) (Operation, error) {
-	operation := _operationFactory.Discovery(operationName)
-	if operation == nil {
-		return nil, fmt.Errorf("无效的操作符 [%s]", operationName)
+// OperationStartsWith ...
+type OperationStartsWith struct{}
+
+// Name ...
+func (t *OperationStartsWith) Name() string {
+	return "starts with"
+}
+
+// Expect ...
+func (t *OperationStartsWith) Expect(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return nil, fmt.Errorf("操作符 [%s] 的值必须是字符串", t.Name())
 	}
-	return operation, nil
+	return s, nil
+}
+
+// Assert ...
+func (t *OperationStartsWith) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	value, ok := variable.Value(ctx).(string)
+	return ok && strings.HasPrefix(value, expect.(string))
+}
+
+// ----------------------------------------------------------------
+
+// OperationEndsWith ...
+type OperationEndsWith struct {
+	OperationStartsWith
+}
+
+// Name ...
+func (t *OperationEndsWith) Name() string {
+	return "ends with"
+}
+
+// Assert ...
+func (t *OperationEndsWith) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	value, ok := variable.Value(ctx).(string)
+	return ok && strings.HasSuffix(value, expect.(string))
 }
 
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 
-var _operationFactory *OperationFactory
+// OperationExists 变量是否存在, ["ctx.uid", "exists", true]
+type OperationExists struct{}
 
-func init() {
-	_operationFactory = &OperationFactory{
-		operations: make(map[string]Operation),
+// Name ...
+func (t *OperationExists) Name() string {
+	return "exists"
+}
+
+// Expect ...
+func (t *OperationExists) Expect(value interface{}) (interface{}, error) {
+	b, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("操作符 [%s] 的值必须是 true 或 false", t.Name())
+	}
+	return b, nil
+}
+
+// Assert ...
+func (t *OperationExists) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	return (variable.Value(ctx) != nil) == expect.(bool)
+}
+
+// ----------------------------------------------------------------
+
+// OperationEmpty 变量是否为空, nil、空字符串、长度为 0 的数组与 map 视为空
+type OperationEmpty struct {
+	OperationExists
+}
+
+// Name ...
+func (t *OperationEmpty) Name() string {
+	return "empty"
+}
+
+// Assert ...
+func (t *OperationEmpty) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	value := variable.Value(ctx)
+	empty := value == nil
+	if !empty {
+		if n, ok := length(value); ok {
+			empty = n == 0
+		}
+	}
+	return empty == expect.(bool)
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// compareSymbols 比较类操作符支持的比较方式
+var compareSymbols = []string{"=", "!=", ">", ">=", "<", "<="}
+
+// compareResult 比较结果是否满足比较方式
+func compareResult(symbol string, res int) bool {
+	switch symbol {
+	case "=":
+		return res == 0
+	case "!=":
+		return res != 0
+	case ">":
+		return res > 0
+	case ">=":
+		return res >= 0
+	case "<":
+		return res < 0
+	case "<=":
+		return res <= 0
+	}
+	return false
+}
+
+// length 字符串按字符数, 数组与 map 按元素数
+func length(value interface{}) (int, bool) {
+	if s, ok := value.(string); ok {
+		return utf8.RuneCountInString(s), true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// ----------------------------------------------------------------
+
+// OperationLength 长度比较, ["ctx.tags", "len >", 2]
+type OperationLength struct {
+	symbol string
+}
+
+// Name ...
+func (t *OperationLength) Name() string {
+	return "len " + t.symbol
+}
+
+// Expect ...
+func (t *OperationLength) Expect(value interface{}) (interface{}, error) {
+	if !xtype.IsNumeric(value) {
+		return nil, fmt.Errorf("操作符 [%s] 的值必须是数字", t.Name())
+	}
+	return xtype.ToInt(value), nil
+}
+
+// Assert ...
+func (t *OperationLength) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	n, ok := length(variable.Value(ctx))
+	if !ok {
+		return false
+	}
+	return compareResult(t.symbol, xutil.CompareNumber(n, expect))
+}
+
+// ----------------------------------------------------------------
+
+// OperationSemver 版本比较, ["ctx.app_version", "semver >=", "v1.2.0"]
+type OperationSemver struct {
+	symbol string
+}
+
+// Name ...
+func (t *OperationSemver) Name() string {
+	return "semver " + t.symbol
+}
+
+// Expect ...
+func (t *OperationSemver) Expect(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || xversion.Format(s) == "" {
+		return nil, fmt.Errorf("操作符 [%s] 的值必须是版本号字符串", t.Name())
+	}
+	return s, nil
+}
+
+// Assert ...
+func (t *OperationSemver) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	value, ok := variable.Value(ctx).(string)
+	if !ok || value == "" {
+		return false
+	}
+	return compareResult(t.symbol, xversion.Compare(value, expect.(string)))
+}
+
+// ----------------------------------------------------------------
+
+// OperationDatetime 时间比较, 字符串按 Context 的时区解析, 数字视为秒级时间戳
+//
+//	["ctx.created_at", "datetime >=", "2023-10-01 00:00:00"]
+type OperationDatetime struct {
+	symbol string
+}
+
+// Name ...
+func (t *OperationDatetime) Name() string {
+	return "datetime " + t.symbol
+}
+
+// Expect 只校验格式, 断言时再按时区解析
+func (t *OperationDatetime) Expect(value interface{}) (interface{}, error) {
+	if _, ok := toDatetime(value, time.Local); !ok {
+		return nil, fmt.Errorf("操作符 [%s] 的值必须是时间, 如 2006-01-02 15:04:05", t.Name())
 	}
-	_operationFactory.Register(&OperationEqual{})    // =
-	_operationFactory.Register(&OperationEqualNot{}) // !=
-	_operationFactory.Register(&OperationEqualGT{})  // >
-	_operationFactory.Register(&OperationEqualGTE{}) // >=
-	_operationFactory.Register(&OperationEqualLT{})  // <
-	_operationFactory.Register(&OperationEqualLTE{}) // <=
-	_operationFactory.Register(&OperationBetween{})  // between
-	_operationFactory.Register(&OperationIn{})       // in
-	_operationFactory.Register(&OperationNotIn{})    // not in
-	_operationFactory.Register(&OperationMatch{})    // match
-	_operationFactory.Register(&OperationNotMatch{}) // not match
-	_operationFactory.Register(&OperationHas{})      // has
+	return value, nil
+}
+
+// Assert ...
+func (t *OperationDatetime) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	location := ctx.Location()
+	value, ok := toDatetime(variable.Value(ctx), location)
+	if !ok {
+		return false
+	}
+	e, _ := toDatetime(expect, location)
+	res := 0
+	if value.Before(e) {
+		res = -1
+	} else if value.After(e) {
+		res = 1
+	}
+	return compareResult(t.symbol, res)
+}
+
+// datetimeLayouts 依次尝试的时间格式
+var datetimeLayouts = []string{xtime.FormatTime, xtime.FormatDateBar}
+
+// toDatetime 字符串使用 xtime 的格式在指定时区解析
+func toDatetime(value interface{}, location *time.Location) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v != nil {
+			return *v, !v.IsZero()
+		}
+	case string:
+		for _, layout := range datetimeLayouts {
+			if tm, err := time.ParseInLocation(xtime.FormatLayout(layout), v, location); err == nil {
+				return tm, true
+			}
+		}
+		if tm, err := time.Parse(time.RFC3339, v); err == nil {
+			return tm, true
+		}
+	default:
+		if xtype.IsNumeric(value) {
+			return time.Unix(xtype.ToInt64(value), 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// OperationCIDR IP 是否在网段内, 值为网段或网段数组, ["ctx.ip", "cidr", ["10.0.0.0/8", "192.168.0.0/16"]]
+type OperationCIDR struct{}
+
+// Name ...
+func (t *OperationCIDR) Name() string {
+	return "cidr"
+}
+
+// Expect ...
+func (t *OperationCIDR) Expect(value interface{}) (interface{}, error) {
+	list := xtype.ToSlice(value)
+	if len(list) == 0 {
+		return nil, fmt.Errorf("操作符 [%s] 的值必须是网段", t.Name())
+	}
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("操作符 [%s] 的值必须是网段: %v", t.Name(), v)
+		}
+		if _, _, err := net.ParseCIDR(s); err != nil {
+			return nil, fmt.Errorf("操作符 [%s] 的值不是有效的网段: %s", t.Name(), s)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// Assert ...
+func (t *OperationCIDR) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	ip, ok := variable.Value(ctx).(string)
+	if !ok {
+		return false
+	}
+	for _, cidr := range expect.([]string) {
+		if xip.IsContains(cidr, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------
+
+// OperationNotCIDR ...
+type OperationNotCIDR struct {
+	OperationCIDR
+}
+
+// Name ...
+func (t *OperationNotCIDR) Name() string {
+	return "not cidr"
+}
+
+// Assert 无效的 IP 不在任何网段内
+func (t *OperationNotCIDR) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	return !t.OperationCIDR.Assert(ctx, variable, expect)
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// quantifierExpect any/all 编译后的预期值
+type quantifierExpect struct {
+	operation Operation
+	expect    interface{}
+}
+
+// String ...
+func (t *quantifierExpect) String() string {
+	return fmt.Sprintf("%s %v", t.operation.Name(), explainValue(t.expect))
+}
+
+// OperationAny 数组中任一元素满足条件, 值为 [操作符, 预期值]
+//
+//	["ctx.tags", "any", ["starts with", "vip_"]]
+type OperationAny struct {
+	factory *OperationFactory
+}
+
+// Name ...
+func (t *OperationAny) Name() string {
+	return "any"
+}
+
+// Expect ...
+func (t *OperationAny) Expect(value interface{}) (interface{}, error) {
+	return t.quantifierExpect(t.Name(), value)
+}
+
+// quantifierExpect 通过工厂查找元素使用的操作符, 自定义的操作符同样可用
+func (t *OperationAny) quantifierExpect(name string, value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) != 2 {
+		return nil, fmt.Errorf("操作符 [%s] 的值必须是 [操作符, 预期值]", name)
+	}
+	operationName, _ := list[0].(string)
+	operation := t.factory.Discovery(operationName)
+	if operation == nil {
+		return nil, fmt.Errorf("操作符 [%s] 中无效的操作符 [%v]", name, list[0])
+	}
+	expect, err := operation.Expect(list[1])
+	if err != nil {
+		return nil, err
+	}
+	return &quantifierExpect{operation: operation, expect: expect}, nil
+}
+
+// Assert ...
+func (t *OperationAny) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	return quantify(ctx, variable, expect.(*quantifierExpect), true)
+}
+
+// ----------------------------------------------------------------
+
+// OperationAll 数组中全部元素满足条件, 空数组不满足
+type OperationAll struct {
+	OperationAny
+}
+
+// Name ...
+func (t *OperationAll) Name() string {
+	return "all"
+}
+
+// Expect ...
+func (t *OperationAll) Expect(value interface{}) (interface{}, error) {
+	return t.quantifierExpect(t.Name(), value)
+}
+
+// Assert ...
+func (t *OperationAll) Assert(ctx *Context, variable Variable, expect interface{}) bool {
+	return quantify(ctx, variable, expect.(*quantifierExpect), false)
+}
+
+// quantify 逐个元素断言, any 遇到成功、all 遇到失败时返回
+func quantify(ctx *Context, variable Variable, expect *quantifierExpect, anyOf bool) bool {
+	v := reflect.ValueOf(variable.Value(ctx))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array || v.Len() == 0 {
+		return false
+	}
+	for i := 0; i < v.Len(); i++ {
+		elem := &variableResolved{name: fmt.Sprintf("%s.%d", variable.Name(), i), value: v.Index(i).Interface()}
+		if expect.operation.Assert(ctx, elem, expect.expect) == anyOf {
+			return anyOf
+		}
+	}
+	return !anyOf
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// NewOperation 从默认 Engine 中查找操作符
+func NewOperation(operationName string) (Operation, error) {
+	return _defaultEngine.NewOperation(operationName)
 }
@@ -0,0 +1,133 @@
+package xfilter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newOperationContext() *Context {
+	ctx := NewContext()
+	ctx.SetLocation(time.FixedZone("CST", 8*3600))
+	ctx.Set("name", "vip_hello")
+	ctx.Set("blank", "")
+	ctx.Set("tags", []string{"vip_gold", "vip_new", "beta"})
+	ctx.Set("none", []int{})
+	ctx.Set("scores", []interface{}{80, 95, 60})
+	ctx.Set("meta", map[string]interface{}{"k": 1})
+	ctx.Set("version", "v1.10.2")
+	ctx.Set("ip", "192.168.1.20")
+	ctx.Set("created_at", "2023-10-01 12:00:00")
+	ctx.Set("paid_at", time.Date(2023, 10, 1, 4, 0, 0, 0, time.UTC))
+	ctx.Set("unix", 1696132800) // 2023-10-01 12:00:00 +08:00
+	return ctx
+}
+
+func TestOperationExtended(t *testing.T) {
+	ctx := newOperationContext()
+	cases := map[string]bool{
+		`[["ctx.name", "starts with", "vip_"]]`:                     true,
+		`[["ctx.name", "starts with", "VIP_"]]`:                     false,
+		`[["ctx.name", "ends with", "llo"]]`:                        true,
+		`[["ctx.scores", "ends with", "0"]]`:                        false,
+		`[["ctx.name", "exists", true]]`:                            true,
+		`[["ctx.missing", "exists", true]]`:                         false,
+		`[["ctx.missing", "exists", false]]`:                        true,
+		`[["ctx.blank", "empty", true]]`:                            true,
+		`[["ctx.none", "empty", true]]`:                             true,
+		`[["ctx.missing", "empty", true]]`:                          true,
+		`[["ctx.tags", "empty", false]]`:                            true,
+		`[["ctx.meta", "empty", true]]`:                             false,
+		`[["ctx.tags", "len =", 3]]`:                                true,
+		`[["ctx.name", "len >", 8]]`:                                true,
+		`[["ctx.meta", "len <=", 1]]`:                               true,
+		`[["ctx.none", "len !=", 0]]`:                               false,
+		`[["ctx.unix", "len >=", 0]]`:                               false,
+		`[["ctx.version", "semver >", "1.9"]]`:                      true,
+		`[["ctx.version", "semver =", "1.10.2"]]`:                   true,
+		`[["ctx.version", "semver <", "v1.10.3"]]`:                  true,
+		`[["ctx.missing", "semver <", "1.0"]]`:                      false,
+		`[["ctx.ip", "cidr", "192.168.0.0/16"]]`:                    true,
+		`[["ctx.ip", "cidr", ["10.0.0.0/8", "172.16.0.0/12"]]]`:     false,
+		`[["ctx.ip", "not cidr", ["10.0.0.0/8", "172.16.0.0/12"]]]`: true,
+		`[["ctx.name", "cidr", "192.168.0.0/16"]]`:                  false,
+		`[["ctx.created_at", "datetime >=", "2023-10-01"]]`:         true,
+		`[["ctx.created_at", "datetime <", "2023-10-01 11:59:59"]]`: false,
+		`[["ctx.paid_at", "datetime =", "2023-10-01 12:00:00"]]`:    true,
+		`[["ctx.unix", "datetime =", "2023-10-01T12:00:00+08:00"]]`: true,
+		`[["ctx.name", "datetime >", "2023-10-01"]]`:                false,
+		`[["ctx.tags", "any", ["starts with", "beta"]]]`:            true,
+		`[["ctx.tags", "all", ["starts with", "vip_"]]]`:            false,
+		`[["ctx.scores", "all", [">=", 60]]]`:                       true,
+		`[["ctx.scores", "any", ["between", [90, 100]]]]`:           true,
+		`[["ctx.none", "all", [">=", 60]]]`:                         false,
+		`[["ctx.name", "any", ["=", "vip_hello"]]]`:                 false,
+		`[["ctx.tags", "any", ["all", ["=", 1]]]]`:                  false,
+	}
+	for rule, ok := range cases {
+		assert.Equal(t, ok, Assert(ctx, rule) == nil, rule)
+	}
+}
+
+func TestOperationExtendedExpect(t *testing.T) {
+	for _, rule := range []string{
+		`[["ctx.name", "starts with", ""]]`,
+		`[["ctx.name", "ends with", 1]]`,
+		`[["ctx.name", "exists", "yes"]]`,
+		`[["ctx.name", "empty", 1]]`,
+		`[["ctx.name", "len >", "a"]]`,
+		`[["ctx.name", "len ~", 1]]`,
+		`[["ctx.version", "semver >", "v"]]`,
+		`[["ctx.version", "semver >", 1]]`,
+		`[["ctx.ip", "cidr", "192.168.0.0"]]`,
+		`[["ctx.ip", "cidr", [1]]]`,
+		`[["ctx.ip", "cidr", []]]`,
+		`[["ctx.created_at", "datetime >", "yesterday"]]`,
+		`[["ctx.tags", "any", "vip_"]]`,
+		`[["ctx.tags", "any", ["~", 1]]]`,
+		`[["ctx.tags", "all", ["in", []]]]`,
+	} {
+		_, err := Compile(rule)
+		assert.NotNil(t, err, rule)
+	}
+
+	// any/all 可以使用引擎中注册的自定义操作符
+	engine := NewEngine()
+	engine.RegisterOperation(&operationInCityList{lists: map[string][]interface{}{"east": {"shanghai"}}})
+	ctx := NewContext()
+	ctx.Set("cities", []string{"beijing", "shanghai"})
+	assert.Nil(t, engine.Assert(ctx, `[["ctx.cities", "any", ["in_city_list", "east"]]]`))
+	assert.NotNil(t, engine.Assert(ctx, `[["ctx.cities", "all", ["in_city_list", "east"]]]`))
+	_, err := Compile(`[["ctx.cities", "any", ["in_city_list", "east"]]]`)
+	assert.NotNil(t, err)
+}
+
+func TestOperationExtendedExpr(t *testing.T) {
+	ctx := newOperationContext()
+	expr := `ctx.name starts with "vip_" and ctx.name ends with "llo" and ctx.name exists true and ctx.blank empty true` +
+		` and ctx.tags len >= 3 and ctx.version semver >= "1.10" and ctx.ip cidr ("192.168.0.0/16") and ctx.ip not cidr "10.0.0.0/8"` +
+		` and ctx.created_at datetime < "2023-10-02" and ctx.tags any match /^beta$/ and ctx.scores all between 60 and 100`
+	rule, err := CompileExpr(expr)
+	assert.Nil(t, err)
+	assert.True(t, rule.Match(ctx))
+
+	filters, err := ParseExpr(expr)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"ctx.scores", "all", []interface{}{"between", []interface{}{float64(60), float64(100)}}}, filters[10])
+	s, err := FormatExpr(`[["ctx.tags", "any", ["match", "/^beta$/"]], ["ctx.scores", "all", ["between", [60, 100]]], ["ctx.tags", "len >=", 3]]`)
+	assert.Nil(t, err)
+	assert.Equal(t, `ctx.tags any match /^beta$/ and ctx.scores all between 60 and 100 and ctx.tags len >= 3`, s)
+	back, err := ParseExpr(s)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"ctx.tags", "any", []interface{}{"match", "/^beta$/"}}, back[0])
+
+	for _, expr := range []string{`ctx.name starts "x"`, `ctx.tags len 3`, `ctx.ip not like "x"`, `ctx.tags any`} {
+		_, err := ParseExpr(expr)
+		assert.NotNil(t, err, expr)
+	}
+
+	res, err := Explain(ctx, `[["ctx.tags", "any", ["starts with", "beta"]]]`)
+	assert.Nil(t, err)
+	assert.Equal(t, "starts with beta", res.Children[0].Expect)
+}
@@ -0,0 +1,131 @@
+package xfilter
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Rule 编译后的规则, 创建后不可修改, 可以在多个 goroutine 中复用
+type Rule struct {
+	text      string
+	condition Condition
+	engine    *Engine
+}
+
+// Compile 使用默认 Engine 编译规则
+func Compile(rule string) (*Rule, error) {
+	return _defaultEngine.Compile(rule)
+}
+
+// MustCompile 解析失败时 panic, 用于初始化固定的规则
+func MustCompile(rule string) *Rule {
+	r, err := Compile(rule)
+	if err != nil {
+		panic("xfilter: Compile(" + rule + "): " + err.Error())
+	}
+	return r
+}
+
+// String 原始规则
+func (t *Rule) String() string {
+	return t.text
+}
+
+// Condition 条件树
+func (t *Rule) Condition() Condition {
+	return t.condition
+}
+
+// Assert 规则断言
+func (t *Rule) Assert(ctx *Context) error {
+	return t.condition.Assert(ctx.withEngine(t.engine))
+}
+
+// Match 规则是否通过
+func (t *Rule) Match(ctx *Context) bool {
+	return t.Assert(ctx) == nil
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// RuleCache 按规则文本缓存编译结果的 LRU, 并发安全
+type RuleCache struct {
+	mu      sync.Mutex
+	size    int
+	items   map[string]*list.Element
+	order   *list.List // 最近使用的在前
+	compile func(string) (*Rule, error)
+}
+
+// NewRuleCache 使用默认 Engine 编译, size <= 0 时不限制数量
+func NewRuleCache(size int) *RuleCache {
+	return newRuleCache(size, Compile)
+}
+
+// newRuleCache ...
+func newRuleCache(size int, compile func(string) (*Rule, error)) *RuleCache {
+	return &RuleCache{
+		size:    size,
+		items:   make(map[string]*list.Element),
+		order:   list.New(),
+		compile: compile,
+	}
+}
+
+// Compile 优先读取缓存, 编译失败的规则不缓存
+func (t *RuleCache) Compile(rule string) (*Rule, error) {
+	if r, ok := t.Get(rule); ok {
+		return r, nil
+	}
+	r, err := t.compile(rule)
+	if err != nil {
+		return nil, err
+	}
+	t.Add(r)
+	return r, nil
+}
+
+// Get ...
+func (t *RuleCache) Get(rule string) (*Rule, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.items[rule]; ok {
+		t.order.MoveToFront(e)
+		return e.Value.(*Rule), true
+	}
+	return nil, false
+}
+
+// Add 写入缓存, 超出容量时淘汰最久未使用的规则
+func (t *RuleCache) Add(r *Rule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.items[r.text]; ok {
+		e.Value = r
+		t.order.MoveToFront(e)
+		return
+	}
+	t.items[r.text] = t.order.PushFront(r)
+	if t.size > 0 && t.order.Len() > t.size {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.items, oldest.Value.(*Rule).text)
+	}
+}
+
+// Len ...
+func (t *RuleCache) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.order.Len()
+}
+
+// Purge 清空缓存
+func (t *RuleCache) Purge() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = make(map[string]*list.Element)
+	t.order.Init()
+}
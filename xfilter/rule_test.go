@@ -0,0 +1,149 @@
+package xfilter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const benchRule = `[
+	["ctx.uid", ">", 0],
+	["ctx.user.name", "match", "/^h.*o$/"],
+	["ctx.user.city", "in", ["shanghai", "beijing"]],
+	[
+		["ctx.user.age", "between", [18, 60]],
+		["ctx.user.vip", "=", true],
+		"or"
+	],
+	"and"
+]`
+
+func newBenchContext() *Context {
+	ctx := NewContext()
+	ctx.Set("uid", 7)
+	ctx.Set("user", map[string]interface{}{
+		"name": "hello",
+		"city": "shanghai",
+		"age":  20,
+		"vip":  false,
+	})
+	return ctx
+}
+
+func TestCompile(t *testing.T) {
+	rule, err := Compile(benchRule)
+	assert.Nil(t, err)
+	assert.Equal(t, benchRule, rule.String())
+	assert.NotNil(t, rule.Condition())
+
+	ctx := newBenchContext()
+	assert.Nil(t, rule.Assert(ctx))
+	assert.True(t, rule.Match(ctx))
+
+	other := NewContext()
+	other.Set("uid", 0)
+	assert.NotNil(t, rule.Assert(other))
+	assert.False(t, rule.Match(other))
+
+	_, err = Compile(`[["ctx.uid", "~", 1]]`)
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { MustCompile("[]") })
+}
+
+func TestCompileConcurrent(t *testing.T) {
+	rule := MustCompile(benchRule)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := newBenchContext()
+			ctx.Set("uid", i%2)
+			for j := 0; j < 100; j++ {
+				assert.Equal(t, i%2 == 1, rule.Match(ctx))
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRuleCache(t *testing.T) {
+	cache := NewRuleCache(2)
+	rules := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		rules = append(rules, fmt.Sprintf(`[["ctx.uid", "=", %d]]`, i))
+	}
+	r0, err := cache.Compile(rules[0])
+	assert.Nil(t, err)
+	r0Again, _ := cache.Compile(rules[0])
+	assert.True(t, r0 == r0Again)
+
+	_, _ = cache.Compile(rules[1])
+	// 访问 0 后 1 成为最久未使用
+	_, _ = cache.Compile(rules[0])
+	_, _ = cache.Compile(rules[2])
+	assert.Equal(t, 2, cache.Len())
+	_, ok := cache.Get(rules[1])
+	assert.False(t, ok)
+	_, ok = cache.Get(rules[0])
+	assert.True(t, ok)
+
+	// 编译失败的不缓存
+	_, err = cache.Compile("[]")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, cache.Len())
+
+	cache.Purge()
+	assert.Equal(t, 0, cache.Len())
+}
+
+// BenchmarkAssertParse 每次解析规则, 即缓存之前的 Assert
+func BenchmarkAssertParse(b *testing.B) {
+	ctx := newBenchContext()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		filters, _ := ParseFilter(benchRule)
+		condition, _ := NewCondition(filters)
+		if condition.Assert(ctx) != nil {
+			b.Fatal("assert fail")
+		}
+	}
+}
+
+// BenchmarkAssertCached 通过 LRU 读取编译结果
+func BenchmarkAssertCached(b *testing.B) {
+	ctx := newBenchContext()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if Assert(ctx, benchRule) != nil {
+			b.Fatal("assert fail")
+		}
+	}
+}
+
+// BenchmarkRuleAssert 直接复用编译后的规则
+func BenchmarkRuleAssert(b *testing.B) {
+	ctx := newBenchContext()
+	rule := MustCompile(benchRule)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if rule.Assert(ctx) != nil {
+			b.Fatal("assert fail")
+		}
+	}
+}
+
+func BenchmarkRuleAssertParallel(b *testing.B) {
+	rule := MustCompile(benchRule)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := newBenchContext()
+		for pb.Next() {
+			if rule.Assert(ctx) != nil {
+				b.Fatal("assert fail")
+			}
+		}
+	})
+}
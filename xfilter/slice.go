@@ -0,0 +1,139 @@
+package xfilter
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/falcolee/xutils/xutil"
+)
+
+// FilterSlice 使用默认 Engine 过滤任意类型的列表, 规则中通过 item.xxx 读取元素字段
+//
+//	users, err := xfilter.FilterSlice(ctx, users, `item.age >= 18 and item.profile.city = "shanghai"`)
+func FilterSlice[T any](ctx *Context, items []T, rule string) ([]T, error) {
+	return FilterSliceWith(_defaultEngine, ctx, items, rule)
+}
+
+// FilterSliceWith 使用指定 Engine 过滤列表, 规则只编译一次
+func FilterSliceWith[T any](e *Engine, ctx *Context, items []T, rule string) ([]T, error) {
+	r, err := e.cache.Compile(rule)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	res := make([]T, 0)
+	for _, item := range items {
+		if r.Match(ctx.withItem(item)) {
+			res = append(res, item)
+		}
+	}
+	return res, nil
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
+// resolvePath 按 . 分割的路径读取数据, 结构体按 json 标签或字段名匹配, 指针自动解引用
+func resolvePath(obj interface{}, path string) interface{} {
+	if path == "" {
+		return obj
+	}
+	for _, key := range strings.Split(path, ".") {
+		if obj = resolveKey(obj, key); obj == nil {
+			return nil
+		}
+	}
+	return obj
+}
+
+// resolveKey ...
+func resolveKey(obj interface{}, key string) interface{} {
+	if _, ok := obj.(map[string]interface{}); ok {
+		return xutil.Parse(obj, key)
+	}
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		index, ok := structFields(v.Type())[key]
+		if !ok {
+			return nil
+		}
+		field, err := v.FieldByIndexErr(index)
+		if err != nil || !field.CanInterface() {
+			// 嵌入的结构体指针为 nil
+			return nil
+		}
+		return interfaceOf(field)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		value := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		if !value.IsValid() {
+			return nil
+		}
+		return interfaceOf(value)
+	case reflect.Slice, reflect.Array:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= v.Len() {
+			return nil
+		}
+		return interfaceOf(v.Index(index))
+	}
+	return nil
+}
+
+// interfaceOf 为 nil 的指针视为不存在
+func interfaceOf(v reflect.Value) interface{} {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// _structFields 按类型缓存字段路径, 避免重复反射
+var _structFields sync.Map
+
+// structFields 可导出字段的 json 标签与字段名, 嵌入结构体的字段层级浅的优先
+func structFields(t reflect.Type) map[string][]int {
+	if fields, ok := _structFields.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	tagged := make(map[string]bool)
+	set := func(name string, index []int, tag bool) {
+		if old, ok := fields[name]; ok {
+			if len(old) < len(index) || len(old) == len(index) && (tagged[name] || !tag) {
+				return
+			}
+		}
+		fields[name] = index
+		tagged[name] = tag
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			set(tag, f.Index, true)
+		}
+		set(f.Name, f.Index, false)
+	}
+	actual, _ := _structFields.LoadOrStore(t, fields)
+	return actual.(map[string][]int)
+}
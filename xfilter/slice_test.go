@@ -0,0 +1,142 @@
+package xfilter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sliceBase struct {
+	ID int `json:"id"`
+}
+
+type sliceProfile struct {
+	City string `json:"city"`
+	Tags []string
+}
+
+type sliceUser struct {
+	sliceBase
+	Name    string            `json:"name"`
+	Age     int               `json:"age,omitempty"`
+	Secret  string            `json:"-"`
+	Profile *sliceProfile     `json:"profile"`
+	Scores  []int             `json:"scores"`
+	Extra   map[string]string `json:"extra"`
+	private string
+}
+
+func newSliceUsers() []sliceUser {
+	return []sliceUser{
+		{sliceBase: sliceBase{ID: 1}, Name: "alice", Age: 20, Secret: "x", Profile: &sliceProfile{City: "shanghai", Tags: []string{"vip"}}, Scores: []int{90, 80}},
+		{sliceBase: sliceBase{ID: 2}, Name: "bob", Age: 17, Profile: &sliceProfile{City: "beijing"}, Extra: map[string]string{"level": "3"}},
+		{sliceBase: sliceBase{ID: 3}, Name: "carol", Age: 35, private: "p"},
+	}
+}
+
+func ids(users []sliceUser) []int {
+	res := make([]int, 0)
+	for _, u := range users {
+		res = append(res, u.ID)
+	}
+	return res
+}
+
+func TestFilterSlice(t *testing.T) {
+	users := newSliceUsers()
+	ctx := NewContext()
+	ctx.Set("min_age", 18)
+
+	cases := map[string][]int{
+		`item.age >= 18`: {1, 3},
+		`item.Age >= 18 and item.profile.city = "shanghai"`: {1},
+		`item.profile.Tags has ("vip")`:                     {1},
+		`item.profile exists false`:                         {3},
+		`item.scores.0 > 85 or item.extra.level = "3"`:      {1, 2},
+		`item.scores any < 85`:                              {1},
+		`item.id in (2, 3)`:                                 {2, 3},
+		`item.ID = 1 and item.Secret exists false`:          {1},
+		`item.private exists true`:                          {},
+		`item.age >= ctx_min and item.name exists true`:     nil,
+		`[["item.age", "<", 18]]`:                           {2},
+		`[["item", "exists", true]]`:                        {1, 2, 3},
+	}
+	for rule, expect := range cases {
+		res, err := FilterSlice(ctx, users, rule)
+		if expect == nil {
+			assert.NotNil(t, err, rule)
+			continue
+		}
+		assert.Nil(t, err, rule)
+		assert.Equal(t, expect, ids(res), rule)
+	}
+
+	// 指针元素与上下文变量
+	ptrs := []*sliceUser{&users[0], &users[1], nil}
+	res, err := FilterSlice(ctx, ptrs, `item.age >= 17 and ctx.min_age = 18`)
+	assert.Nil(t, err)
+	assert.Equal(t, []*sliceUser{&users[0], &users[1]}, res)
+
+	maps := []map[string]interface{}{{"id": 1, "n": map[string]interface{}{"v": 1}}, {"id": 2}}
+	mres, err := FilterSlice(nil, maps, `item.n.v = 1`)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(mres))
+
+	nums, err := FilterSlice(nil, []int{1, 5, 10}, `item between 2 and 10`)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 10}, nums)
+
+	empty, err := FilterSlice[int](nil, nil, `item > 1`)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(empty))
+}
+
+func TestFilterSliceWith(t *testing.T) {
+	engine := NewEngine()
+	engine.RegisterOperation(&operationInCityList{lists: map[string][]interface{}{"east": {"shanghai"}}})
+	res, err := FilterSliceWith(engine, NewContext(), newSliceUsers(), `item.profile.city in_city_list "east"`)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1}, ids(res))
+	_, err = FilterSlice(NewContext(), newSliceUsers(), `item.profile.city in_city_list "east"`)
+	assert.NotNil(t, err)
+}
+
+func TestResolvePath(t *testing.T) {
+	user := newSliceUsers()[0]
+	assert.Equal(t, "alice", resolvePath(user, "name"))
+	assert.Equal(t, "alice", resolvePath(&user, "Name"))
+	assert.Equal(t, "vip", resolvePath(user, "profile.Tags.0"))
+	assert.Nil(t, resolvePath(user, "profile.Tags.1"))
+	assert.Nil(t, resolvePath(user, "profile.missing"))
+	assert.Nil(t, resolvePath(newSliceUsers()[2], "profile.city"))
+	assert.Nil(t, resolvePath(map[int]int{1: 1}, "1"))
+
+	// ctx 中的结构体同样可以按字段读取
+	ctx := NewContext()
+	ctx.Set("user", &user)
+	assert.Nil(t, Assert(ctx, `ctx.user.profile.city = "shanghai" and ctx.user.id = 1`))
+
+	fields := structFields(reflect.TypeOf(user))
+	assert.Equal(t, []int{0, 0}, fields["id"])
+	assert.Equal(t, []int{0, 0}, fields["ID"])
+	_, ok := fields["Secret"]
+	assert.False(t, ok)
+	_, ok = fields["private"]
+	assert.False(t, ok)
+	// 同一类型复用缓存
+	again := structFields(reflect.TypeOf(user))
+	assert.Equal(t, reflect.ValueOf(fields).Pointer(), reflect.ValueOf(again).Pointer())
+}
+
+func BenchmarkFilterSlice(b *testing.B) {
+	users := newSliceUsers()
+	ctx := NewContext()
+	rule := `item.age >= 18 and item.profile.city = "shanghai"`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if res, _ := FilterSlice(ctx, users, rule); len(res) != 1 {
+			b.Fatal("filter fail")
+		}
+	}
+}
@@ -1,11 +1,10 @@
 package xfilter
 
 import (
-	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/falcolee/xutils/xutil"
 )
 
 // Variable 变量接口
@@ -23,23 +22,92 @@ type VariableCreator = func(string) Variable
 
 // VariableFactory 变量实例工厂
 type VariableFactory struct {
+	mu       sync.RWMutex
 	creators map[string]VariableCreator
 }
 
-// Register 注册变量实例
+// NewVariableFactory 初始化包含内置变量的工厂
+func NewVariableFactory() *VariableFactory {
+	t := &VariableFactory{
+		creators: make(map[string]VariableCreator),
+	}
+	t.Register("ctx.", func(name string) Variable {
+		key := strings.TrimPrefix(name, "ctx.")
+		if key == "" {
+			return nil
+		}
+		return &VariableCtx{
+			name: name,
+			key:  key,
+		}
+	})
+	// item 与 item.xxx 读取 FilterSlice 中的当前元素
+	itemCreator := func(name string) Variable {
+		return &VariableItem{
+			name: name,
+			path: strings.TrimPrefix(strings.TrimPrefix(name, "item"), "."),
+		}
+	}
+	t.Register("item", itemCreator)
+	t.Register("item.", itemCreator)
+	variableTimes := []string{
+		"year",
+		"month",
+		"day",
+		"hour",
+		"minute",
+		"second",
+		"wday",
+		"date",
+		"time",
+		"unixtime",
+		"datetime",
+		"ts",
+		"weekofyear",
+		"quarter",
+		"now",
+		"today",
+		"yesterday",
+		"tomorrow",
+		"week_start",
+		"month_start",
+		"month_end",
+		"year_start",
+	}
+	for _, name := range variableTimes {
+		t.Register(name, func(name string) Variable {
+			return &VariableTime{
+				name: name,
+				key:  name,
+			}
+		})
+	}
+	// now-7d today+1M 相对时间
+	for _, prefix := range []string{"now-", "now+", "today-", "today+"} {
+		t.Register(prefix, newVariableRelative)
+	}
+	return t
+}
+
+// Register 注册变量实例, name 以 . - + 结尾时匹配该前缀的全部变量, 如 ctx. now-
 func (t *VariableFactory) Register(name string, creator VariableCreator) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.creators[name] = creator
 }
 
 // Discovery 发现变量实例
 func (t *VariableFactory) Discovery(name string) Variable {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 	if creator, ok := t.creators[name]; ok {
 		return creator(name)
 	}
-	segments := strings.Split(name, ".")
-	if len(segments) > 1 {
-		if creator, ok := t.creators[segments[0]+"."]; ok {
-			return creator(name)
+	for _, sep := range []string{".", "-", "+"} {
+		if i := strings.Index(name, sep); i > 0 {
+			if creator, ok := t.creators[name[:i+1]]; ok {
+				return creator(name)
+			}
 		}
 	}
 	return nil
@@ -76,7 +144,7 @@ func (t *VariableCtx) Value(ctx *Context) interface{} {
 		if v, ok := ctx.Get(segments[0]); ok {
 			// 解析数据结构
 			path := strings.TrimPrefix(t.key, segments[0]+".")
-			return xutil.Parse(v, path)
+			return resolvePath(v, path)
 		}
 	}
 	return nil
@@ -86,6 +154,26 @@ func (t *VariableCtx) Value(ctx *Context) interface{} {
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 
+// VariableItem FilterSlice 中的当前元素, 结构体字段按 json 标签或字段名读取
+type VariableItem struct {
+	name string
+	path string
+}
+
+// Name ...
+func (t *VariableItem) Name() string {
+	return t.name
+}
+
+// Value ...
+func (t *VariableItem) Value(ctx *Context) interface{} {
+	return resolvePath(ctx.item, t.path)
+}
+
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+// ----------------------------------------------------------------
+
 // VariableTime ...
 type VariableTime struct {
 	name string
@@ -97,9 +185,9 @@ func (t *VariableTime) Name() string {
 	return t.name
 }
 
-// Value ...
+// Value 使用 Context 的时钟与时区
 func (t *VariableTime) Value(ctx *Context) interface{} {
-	now := time.Now()
+	now := ctx.Now()
 	switch t.key {
 	case "year":
 		return now.Year()
@@ -119,71 +207,142 @@ func (t *VariableTime) Value(ctx *Context) interface{} {
 			wday = 7
 		}
 		return wday
-	case "date":
-		return now.Format("2006-01-02")
+	case "date", "today":
+		return now.Format(layoutDate)
 	case "time":
 		return now.Format("15:04:05")
 	case "unixtime":
 		return now.Unix()
-	case "datetime":
+	case "ts":
+		return now.UnixNano() / int64(time.Millisecond)
+	case "weekofyear":
+		_, week := now.ISOWeek()
+		return week
+	case "quarter":
+		return (int(now.Month())-1)/3 + 1
+	case "yesterday":
+		return now.AddDate(0, 0, -1).Format(layoutDate)
+	case "tomorrow":
+		return now.AddDate(0, 0, 1).Format(layoutDate)
+	case "week_start":
+		// 以周一为一周的开始
+		return now.AddDate(0, 0, -(int(now.Weekday())+6)%7).Format(layoutDate)
+	case "month_start":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format(layoutDate)
+	case "month_end":
+		return time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()).Format(layoutDate)
+	case "year_start":
+		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()).Format(layoutDate)
+	case "datetime", "now":
 		fallthrough
 	default:
-		return now.Format("2006-01-02 15:04:05")
+		return now.Format(layoutDatetime)
+	}
+}
+
+// ...
+const (
+	layoutDate     = "2006-01-02"
+	layoutDatetime = "2006-01-02 15:04:05"
+)
+
+// ----------------------------------------------------------------
+
+// VariableRelative 相对时间, now 返回日期时间, today 返回日期
+//
+//	now-7d now+1h today-1M today+1y
+//
+// 单位: s 秒, m 分, h 时, d 天, w 周, M 月, y 年
+type VariableRelative struct {
+	name   string
+	date   bool
+	offset int
+	unit   byte
+}
+
+// newVariableRelative 格式不正确时返回 nil
+func newVariableRelative(name string) Variable {
+	base, rest := "now", strings.TrimPrefix(name, "now")
+	if strings.HasPrefix(name, "today") {
+		base, rest = "today", strings.TrimPrefix(name, "today")
+	}
+	if len(rest) < 3 {
+		return nil
+	}
+	unit := rest[len(rest)-1]
+	if !strings.ContainsRune("smhdwMy", rune(unit)) {
+		return nil
 	}
+	offset, err := strconv.Atoi(rest[1 : len(rest)-1])
+	if err != nil || offset < 0 {
+		return nil
+	}
+	if rest[0] == '-' {
+		offset = -offset
+	}
+	return &VariableRelative{
+		name:   name,
+		date:   base == "today",
+		offset: offset,
+		unit:   unit,
+	}
+}
+
+// Name ...
+func (t *VariableRelative) Name() string {
+	return t.name
+}
+
+// Value ...
+func (t *VariableRelative) Value(ctx *Context) interface{} {
+	now := ctx.Now()
+	switch t.unit {
+	case 's':
+		now = now.Add(time.Duration(t.offset) * time.Second)
+	case 'm':
+		now = now.Add(time.Duration(t.offset) * time.Minute)
+	case 'h':
+		now = now.Add(time.Duration(t.offset) * time.Hour)
+	case 'd':
+		now = now.AddDate(0, 0, t.offset)
+	case 'w':
+		now = now.AddDate(0, 0, t.offset*7)
+	case 'M':
+		now = now.AddDate(0, t.offset, 0)
+	case 'y':
+		now = now.AddDate(t.offset, 0, 0)
+	}
+	if t.date {
+		return now.Format(layoutDate)
+	}
+	return now.Format(layoutDatetime)
 }
 
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 
-// NewVariable ...
+// NewVariable 从默认 Engine 中查找变量
 func NewVariable(name string) (Variable, error) {
-	variable := _variableFactory.Discovery(name)
-	if variable == nil {
-		return nil, fmt.Errorf("无效的变量 [%s]", name)
-	}
-	return variable, nil
+	return _defaultEngine.NewVariable(name)
 }
 
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 // ----------------------------------------------------------------
 
-var _variableFactory *VariableFactory
+// variableResolved 已求值的变量, 用于 explain 与 any/all 中的数组元素
+type variableResolved struct {
+	name  string
+	value interface{}
+}
 
-func init() {
-	_variableFactory = &VariableFactory{
-		creators: make(map[string]VariableCreator),
-	}
-	_variableFactory.Register("ctx.", func(name string) Variable {
-		key := strings.TrimPrefix(name, "ctx.")
-		if key == "" {
-			return nil
-		}
-		return &VariableCtx{
-			name: name,
-			key:  key,
-		}
-	})
-	variableTimes := []string{
-		"year",
-		"month",
-		"day",
-		"hour",
-		"minute",
-		"second",
-		"wday",
-		"date",
-		"time",
-		"unixtime",
-		"datetime",
-	}
-	for _, name := range variableTimes {
-		_variableFactory.Register(name, func(name string) Variable {
-			return &VariableTime{
-				name: name,
-				key:  name,
-			}
-		})
-	}
+// Name ...
+func (t *variableResolved) Name() string {
+	return t.name
+}
+
+// Value ...
+func (t *variableResolved) Value(ctx *Context) interface{} {
+	return t.value
 }
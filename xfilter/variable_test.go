@@ -0,0 +1,101 @@
+package xfilter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 2023-10-01 01:30:00 UTC, 即北京时间 2023-10-01 09:30:00, 纽约时间 2023-09-30 21:30:00
+var fixedNow = time.Date(2023, 10, 1, 1, 30, 0, 0, time.UTC)
+
+func fixedClock() time.Time {
+	return fixedNow
+}
+
+func TestVariableTime(t *testing.T) {
+	ctx := NewContext()
+	ctx.SetClock(fixedClock)
+	ctx.SetLocation(time.FixedZone("CST", 8*3600))
+
+	cases := map[string]interface{}{
+		"year":        2023,
+		"month":       10,
+		"day":         1,
+		"hour":        9,
+		"minute":      30,
+		"second":      0,
+		"wday":        7,
+		"date":        "2023-10-01",
+		"time":        "09:30:00",
+		"datetime":    "2023-10-01 09:30:00",
+		"unixtime":    fixedNow.Unix(),
+		"ts":          fixedNow.UnixNano() / int64(time.Millisecond),
+		"weekofyear":  39,
+		"quarter":     4,
+		"now":         "2023-10-01 09:30:00",
+		"today":       "2023-10-01",
+		"yesterday":   "2023-09-30",
+		"tomorrow":    "2023-10-02",
+		"week_start":  "2023-09-25",
+		"month_start": "2023-10-01",
+		"month_end":   "2023-10-31",
+		"year_start":  "2023-01-01",
+		"now-7d":      "2023-09-24 09:30:00",
+		"now+90m":     "2023-10-01 11:00:00",
+		"now-1h":      "2023-10-01 08:30:00",
+		"now+30s":     "2023-10-01 09:30:30",
+		"now-2w":      "2023-09-17 09:30:00",
+		"today-1M":    "2023-09-01",
+		"today+1y":    "2024-10-01",
+	}
+	for name, expect := range cases {
+		variable, err := NewVariable(name)
+		if assert.Nil(t, err, name) {
+			assert.Equal(t, expect, variable.Value(ctx), name)
+		}
+	}
+
+	for _, name := range []string{"now-", "now-7", "now-7x", "now--7d", "now-1.5d", "today+d", "now_7d"} {
+		_, err := NewVariable(name)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestVariableTimeLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+
+	// 未设置时使用 Engine 的配置
+	engine := NewEngine(WithClock(fixedClock), WithLocation(newYork))
+	ctx := NewContext()
+	assert.Nil(t, engine.Assert(ctx, `hour = 21 and date = "2023-09-30" and month_end = "2023-09-30" and quarter = 3`))
+	assert.NotNil(t, Assert(ctx, `date = "2023-09-30" and year = 2023 and hour = 21`))
+	rule, err := engine.Compile(`weekofyear = 39 and wday = 6`)
+	assert.Nil(t, err)
+	assert.True(t, rule.Match(ctx))
+	res := rule.Explain(ctx)
+	assert.Equal(t, 39, res.Children[0].Value)
+
+	// Context 的配置优先
+	ctx.SetLocation(time.UTC)
+	assert.Nil(t, engine.Assert(ctx, `hour = 1 and date = "2023-10-01"`))
+	ctx.SetClock(func() time.Time {
+		return fixedNow.Add(24 * time.Hour)
+	})
+	assert.Nil(t, engine.Assert(ctx, `date = "2023-10-02"`))
+
+	users, err := FilterSliceWith(engine, NewContext(), []string{"2023-09-30 10:00:00", "2023-09-22 10:00:00"}, `item datetime >= "2023-09-24"`)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(users))
+}
+
+func TestVariableTimeDefault(t *testing.T) {
+	ctx := NewContext()
+	assert.Equal(t, time.Local, ctx.Location())
+	assert.WithinDuration(t, time.Now(), ctx.Now(), time.Second)
+	assert.Nil(t, Assert(ctx, `year >= 2023 and now-1d datetime < "2999-01-01" and today+1d datetime > "2023-01-01"`))
+}
@@ -0,0 +1,212 @@
+package xflag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/falcolee/xutils/xcrypto"
+	"github.com/falcolee/xutils/xfilter"
+	"github.com/falcolee/xutils/xjson"
+)
+
+// Reason 开关求值结果的原因
+type Reason string
+
+const (
+	ReasonNotFound    Reason = "NOT_FOUND"    // 开关不存在
+	ReasonDisabled    Reason = "DISABLED"     // 开关关闭, 返回默认变体
+	ReasonTargetMatch Reason = "TARGET_MATCH" // 命中目标规则
+	ReasonRollout     Reason = "ROLLOUT"      // 按变体权重分配
+	ReasonDefault     Reason = "DEFAULT"      // 没有命中规则且没有配置权重
+	ReasonError       Reason = "ERROR"        // 缺少分桶变量等, 返回默认变体
+)
+
+// _buckets 分桶数量, 百分比精确到 0.01%
+const _buckets = 10000
+
+// Variant 开关变体
+type Variant struct {
+	Name   string          `json:"name"`
+	Weight int             `json:"weight"`          // 放量权重, 按同一开关内的总和计算比例
+	Value  json.RawMessage `json:"value,omitempty"` // 变体携带的配置, 由业务自行解析
+}
+
+// Rule 目标规则, 按顺序匹配, 第一个命中的规则生效
+type Rule struct {
+	Filter     interface{}    `json:"filter"`               // xfilter 规则, JSON 数组或表达式, 为空时匹配全部
+	Variant    string         `json:"variant,omitempty"`    // 命中后返回的变体
+	Weights    map[string]int `json:"weights,omitempty"`    // 未指定 Variant 时按权重分配
+	Percentage *float64       `json:"percentage,omitempty"` // 只对命中用户中的百分比生效, 为空时为 100
+
+	rule *xfilter.Rule
+}
+
+// Flag 功能开关
+type Flag struct {
+	Key      string     `json:"key"`
+	Enabled  bool       `json:"enabled"`
+	Variants []*Variant `json:"variants"`
+	Rules    []*Rule    `json:"rules,omitempty"`
+	Default  string     `json:"default"`             // 关闭或没有命中时返回的变体
+	BucketBy string     `json:"bucket_by,omitempty"` // 分桶使用的变量, 默认 ctx.uid
+	Salt     string     `json:"salt,omitempty"`      // 分桶的盐, 默认为 Key, 修改后用户重新分桶
+
+	bucketBy xfilter.Variable
+}
+
+// Variant 按名称查找变体
+func (t *Flag) Variant(name string) (*Variant, bool) {
+	for _, v := range t.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Compile 校验配置并编译目标规则
+func (t *Flag) Compile(engine *xfilter.Engine) error {
+	names := make(map[string]bool, len(t.Variants))
+	for _, v := range t.Variants {
+		if v.Name == "" {
+			return fmt.Errorf("xflag: flag [%s] variant name is empty", t.Key)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("xflag: flag [%s] duplicate variant [%s]", t.Key, v.Name)
+		}
+		if v.Weight < 0 {
+			return fmt.Errorf("xflag: flag [%s] variant [%s] weight is negative", t.Key, v.Name)
+		}
+		names[v.Name] = true
+	}
+	if t.Default != "" && !names[t.Default] {
+		return fmt.Errorf("xflag: flag [%s] unknown default variant [%s]", t.Key, t.Default)
+	}
+	for i, r := range t.Rules {
+		if r.Variant == "" && len(r.Weights) == 0 {
+			return fmt.Errorf("xflag: flag [%s] rule %d has no variant or weights", t.Key, i)
+		}
+		if r.Variant != "" && !names[r.Variant] {
+			return fmt.Errorf("xflag: flag [%s] rule %d unknown variant [%s]", t.Key, i, r.Variant)
+		}
+		for name, weight := range r.Weights {
+			if !names[name] || weight < 0 {
+				return fmt.Errorf("xflag: flag [%s] rule %d invalid weight [%s]", t.Key, i, name)
+			}
+		}
+		if r.Percentage != nil && (*r.Percentage < 0 || *r.Percentage > 100) {
+			return fmt.Errorf("xflag: flag [%s] rule %d percentage out of range", t.Key, i)
+		}
+		r.rule = nil
+		if r.Filter == nil {
+			continue
+		}
+		text, ok := r.Filter.(string)
+		if !ok {
+			text = xjson.Encode(r.Filter)
+		}
+		compiled, err := engine.Compile(text)
+		if err != nil {
+			return fmt.Errorf("xflag: flag [%s] rule %d: %w", t.Key, i, err)
+		}
+		r.rule = compiled
+	}
+	bucketBy := t.BucketBy
+	if bucketBy == "" {
+		bucketBy = "ctx.uid"
+	}
+	variable, err := engine.NewVariable(bucketBy)
+	if err != nil {
+		return fmt.Errorf("xflag: flag [%s]: %w", t.Key, err)
+	}
+	t.bucketBy = variable
+	return nil
+}
+
+// Evaluate 求值, 返回变体名称与原因, 需要先 Compile
+func (t *Flag) Evaluate(ctx *xfilter.Context) (string, Reason) {
+	if !t.Enabled {
+		return t.Default, ReasonDisabled
+	}
+	key, ok := t.bucketKey(ctx)
+	for i, r := range t.Rules {
+		if r.rule != nil && !r.rule.Match(ctx) {
+			continue
+		}
+		if r.Percentage != nil {
+			// 百分比与变体分配使用不同的盐, 避免放量范围内的用户集中在第一个变体
+			if !ok || float64(t.bucket(key, "rule."+strconv.Itoa(i))) >= *r.Percentage*_buckets/100 {
+				continue
+			}
+		}
+		if r.Variant != "" {
+			return r.Variant, ReasonTargetMatch
+		}
+		if !ok {
+			return t.Default, ReasonError
+		}
+		if name := t.pick(key, r.Weights); name != "" {
+			return name, ReasonTargetMatch
+		}
+	}
+	weights := make(map[string]int, len(t.Variants))
+	for _, v := range t.Variants {
+		weights[v.Name] = v.Weight
+	}
+	if total(weights) == 0 {
+		return t.Default, ReasonDefault
+	}
+	if !ok {
+		return t.Default, ReasonError
+	}
+	return t.pick(key, weights), ReasonRollout
+}
+
+// bucketKey 分桶变量的值, 变量不存在时无法分桶
+func (t *Flag) bucketKey(ctx *xfilter.Context) (string, bool) {
+	if t.bucketBy == nil {
+		return "", false
+	}
+	value := t.bucketBy.Value(ctx)
+	if value == nil {
+		return "", false
+	}
+	key := fmt.Sprint(value)
+	return key, key != ""
+}
+
+// bucket 同一用户在同一开关下的桶号固定, 范围 [0, _buckets)
+func (t *Flag) bucket(key, scope string) int {
+	salt := t.Salt
+	if salt == "" {
+		salt = t.Key
+	}
+	return int(xcrypto.Hash(salt+"."+scope+":"+key, 0) % _buckets)
+}
+
+// pick 按变体顺序累计权重, 权重调整时只有边界附近的用户改变变体
+func (t *Flag) pick(key string, weights map[string]int) string {
+	sum := total(weights)
+	if sum == 0 {
+		return ""
+	}
+	n := t.bucket(key, "variant") * sum / _buckets
+	for _, v := range t.Variants {
+		w := weights[v.Name]
+		if n < w {
+			return v.Name
+		}
+		n -= w
+	}
+	return ""
+}
+
+// total ...
+func total(weights map[string]int) int {
+	sum := 0
+	for _, w := range weights {
+		sum += w
+	}
+	return sum
+}
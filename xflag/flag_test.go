@@ -0,0 +1,135 @@
+package xflag
+
+import (
+	"testing"
+
+	"github.com/falcolee/xutils/xfilter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFlag(t *testing.T, data string) *Flag {
+	flags, err := Parse([]byte(data), xfilter.Default())
+	assert.Nil(t, err)
+	return flags["checkout"]
+}
+
+func userContext(uid interface{}, vip bool) *xfilter.Context {
+	ctx := xfilter.NewContext()
+	if uid != nil {
+		ctx.Set("uid", uid)
+	}
+	ctx.Set("user", map[string]interface{}{"vip": vip})
+	return ctx
+}
+
+func TestFlagEvaluate(t *testing.T) {
+	flag := newFlag(t, `{"checkout": {
+		"enabled": true,
+		"variants": [{"name": "on", "weight": 30}, {"name": "off", "weight": 70}],
+		"rules": [
+			{"filter": "ctx.user.vip = true", "variant": "on"},
+			{"filter": [["ctx.uid", "in", [1, 2, 3]]], "variant": "off"}
+		],
+		"default": "off"
+	}}`)
+
+	variant, reason := flag.Evaluate(userContext(100, true))
+	assert.Equal(t, "on", variant)
+	assert.Equal(t, ReasonTargetMatch, reason)
+	variant, reason = flag.Evaluate(userContext(2, false))
+	assert.Equal(t, "off", variant)
+	assert.Equal(t, ReasonTargetMatch, reason)
+
+	// 同一用户多次求值结果一致
+	variant, reason = flag.Evaluate(userContext(100, false))
+	assert.Equal(t, ReasonRollout, reason)
+	for i := 0; i < 10; i++ {
+		v, _ := flag.Evaluate(userContext(100, false))
+		assert.Equal(t, variant, v)
+	}
+
+	// 缺少分桶变量
+	variant, reason = flag.Evaluate(userContext(nil, false))
+	assert.Equal(t, "off", variant)
+	assert.Equal(t, ReasonError, reason)
+
+	flag.Enabled = false
+	variant, reason = flag.Evaluate(userContext(100, true))
+	assert.Equal(t, "off", variant)
+	assert.Equal(t, ReasonDisabled, reason)
+}
+
+func TestFlagRolloutDistribution(t *testing.T) {
+	flag := newFlag(t, `{"checkout": {
+		"enabled": true,
+		"variants": [{"name": "a", "weight": 20}, {"name": "b", "weight": 30}, {"name": "c", "weight": 50}]
+	}}`)
+	counts := map[string]int{}
+	for uid := 0; uid < 20000; uid++ {
+		v, _ := flag.Evaluate(userContext(uid, false))
+		counts[v]++
+	}
+	assert.InDelta(t, 4000, counts["a"], 400)
+	assert.InDelta(t, 6000, counts["b"], 400)
+	assert.InDelta(t, 10000, counts["c"], 400)
+
+	// 修改盐后重新分桶
+	changed := 0
+	salted := newFlag(t, `{"checkout": {
+		"enabled": true, "salt": "v2",
+		"variants": [{"name": "a", "weight": 20}, {"name": "b", "weight": 30}, {"name": "c", "weight": 50}]
+	}}`)
+	for uid := 0; uid < 1000; uid++ {
+		v1, _ := flag.Evaluate(userContext(uid, false))
+		v2, _ := salted.Evaluate(userContext(uid, false))
+		if v1 != v2 {
+			changed++
+		}
+	}
+	assert.Greater(t, changed, 300)
+}
+
+func TestFlagRulePercentage(t *testing.T) {
+	flag := newFlag(t, `{"checkout": {
+		"enabled": true,
+		"bucket_by": "ctx.device",
+		"variants": [{"name": "a"}, {"name": "b"}, {"name": "off"}],
+		"rules": [{"filter": "ctx.user.vip = true", "weights": {"a": 1, "b": 1}, "percentage": 10}],
+		"default": "off"
+	}}`)
+	counts := map[string]int{}
+	for i := 0; i < 20000; i++ {
+		ctx := userContext(nil, true)
+		ctx.Set("device", i)
+		v, reason := flag.Evaluate(ctx)
+		counts[v]++
+		if v != "off" {
+			assert.Equal(t, ReasonTargetMatch, reason)
+		} else {
+			assert.Equal(t, ReasonDefault, reason)
+		}
+	}
+	// 放量范围内的用户在两个变体之间均匀分配
+	assert.InDelta(t, 1000, counts["a"], 200)
+	assert.InDelta(t, 1000, counts["b"], 200)
+}
+
+func TestFlagCompile(t *testing.T) {
+	engine := xfilter.Default()
+	for _, data := range []string{
+		`{"f": {"variants": [{"name": "a"}, {"name": "a"}]}}`,
+		`{"f": {"variants": [{"name": "a", "weight": -1}]}}`,
+		`{"f": {"variants": [{"name": "a"}], "default": "b"}}`,
+		`{"f": {"variants": [{"name": "a"}], "rules": [{"filter": "ctx.uid = 1"}]}}`,
+		`{"f": {"variants": [{"name": "a"}], "rules": [{"variant": "b"}]}}`,
+		`{"f": {"variants": [{"name": "a"}], "rules": [{"weights": {"b": 1}}]}}`,
+		`{"f": {"variants": [{"name": "a"}], "rules": [{"variant": "a", "percentage": 101}]}}`,
+		`{"f": {"variants": [{"name": "a"}], "rules": [{"filter": "ctx.uid ~ 1", "variant": "a"}]}}`,
+		`{"f": {"variants": [{"name": "a"}], "bucket_by": "unknown"}}`,
+		`{"f": null}`,
+		``,
+	} {
+		_, err := Parse([]byte(data), engine)
+		assert.NotNil(t, err, data)
+	}
+}
@@ -0,0 +1,149 @@
+package xflag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/falcolee/xutils/xfilter"
+)
+
+// ErrEmptyConfig 配置内容为空
+var ErrEmptyConfig = errors.New("xflag: empty config")
+
+// Manager 功能开关管理, 并发安全
+//
+//	m, err := xflag.New(xflag.FileSource("flags.json"), xflag.WithInterval(10*time.Second))
+//	defer m.Close()
+//	variant, reason := m.Evaluate(ctx, "new_checkout")
+type Manager struct {
+	source Source
+	option *Option
+
+	mu      sync.RWMutex
+	flags   map[string]*Flag
+	content []byte
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// New 首次加载失败时返回错误, 之后热加载失败保留原有配置
+func New(source Source, options ...OptionFn) (*Manager, error) {
+	m := &Manager{
+		source: source,
+		option: newOption(options...),
+		stop:   make(chan struct{}),
+	}
+	if err := m.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	if m.option.Interval > 0 {
+		go m.watch()
+	}
+	return m, nil
+}
+
+// Parse 解析并编译配置, 配置为开关名称到开关的映射
+func Parse(data []byte, engine *xfilter.Engine) (map[string]*Flag, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ErrEmptyConfig
+	}
+	flags := make(map[string]*Flag)
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("xflag: %w", err)
+	}
+	for key, flag := range flags {
+		if flag == nil {
+			return nil, fmt.Errorf("xflag: flag [%s] is null", key)
+		}
+		flag.Key = key
+		if err := flag.Compile(engine); err != nil {
+			return nil, err
+		}
+	}
+	return flags, nil
+}
+
+// Reload 重新加载, 内容没有变化时不重新编译, 失败时保留原有配置
+func (m *Manager) Reload(ctx context.Context) error {
+	data, err := m.source.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("xflag: load: %w", err)
+	}
+	m.mu.RLock()
+	same := m.flags != nil && bytes.Equal(m.content, data)
+	m.mu.RUnlock()
+	if same {
+		return nil
+	}
+	flags, err := Parse(data, m.option.Engine)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.flags, m.content = flags, data
+	m.mu.Unlock()
+	return nil
+}
+
+// Evaluate 开关求值, 返回变体名称与原因
+func (m *Manager) Evaluate(ctx *xfilter.Context, key string) (string, Reason) {
+	flag, ok := m.Flag(key)
+	if !ok {
+		return "", ReasonNotFound
+	}
+	return flag.Evaluate(ctx)
+}
+
+// Enabled 是否命中指定的变体
+func (m *Manager) Enabled(ctx *xfilter.Context, key, variant string) bool {
+	v, _ := m.Evaluate(ctx, key)
+	return v == variant
+}
+
+// Flag 编译后的开关, 不应修改
+func (m *Manager) Flag(key string) (*Flag, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	flag, ok := m.flags[key]
+	return flag, ok
+}
+
+// Flags 当前全部开关
+func (m *Manager) Flags() map[string]*Flag {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make(map[string]*Flag, len(m.flags))
+	for k, v := range m.flags {
+		res[k] = v
+	}
+	return res
+}
+
+// Close 停止热加载
+func (m *Manager) Close() {
+	m.once.Do(func() {
+		close(m.stop)
+	})
+}
+
+// watch ...
+func (m *Manager) watch() {
+	ticker := time.NewTicker(m.option.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			if err := m.Reload(context.Background()); err != nil {
+				m.option.Logger.WithError(err).Warn("xflag: reload failed, keep previous flags")
+			}
+		}
+	}
+}
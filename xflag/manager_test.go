@@ -0,0 +1,73 @@
+package xflag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/falcolee/xutils/xcache/store/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFlags = `{"checkout": {
+	"enabled": true,
+	"variants": [{"name": "on", "value": {"color": "red"}}, {"name": "off"}],
+	"rules": [{"filter": "ctx.user.vip = true", "variant": "on"}],
+	"default": "off"
+}}`
+
+func TestManagerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	assert.Nil(t, os.WriteFile(path, []byte(testFlags), 0644))
+
+	m, err := New(FileSource(path), WithInterval(10*time.Millisecond))
+	assert.Nil(t, err)
+	defer m.Close()
+
+	variant, reason := m.Evaluate(userContext(1, true), "checkout")
+	assert.Equal(t, "on", variant)
+	assert.Equal(t, ReasonTargetMatch, reason)
+	assert.True(t, m.Enabled(userContext(1, true), "checkout", "on"))
+	_, reason = m.Evaluate(userContext(1, true), "unknown")
+	assert.Equal(t, ReasonNotFound, reason)
+
+	flag, ok := m.Flag("checkout")
+	assert.True(t, ok)
+	v, _ := flag.Variant("on")
+	assert.JSONEq(t, `{"color": "red"}`, string(v.Value))
+	assert.Equal(t, 1, len(m.Flags()))
+
+	// 配置错误时保留原有配置
+	assert.Nil(t, os.WriteFile(path, []byte(`{"checkout": {`), 0644))
+	time.Sleep(50 * time.Millisecond)
+	variant, _ = m.Evaluate(userContext(1, true), "checkout")
+	assert.Equal(t, "on", variant)
+
+	assert.Nil(t, os.WriteFile(path, []byte(`{"checkout": {"enabled": false, "variants": [{"name": "off"}], "default": "off"}}`), 0644))
+	assert.Eventually(t, func() bool {
+		_, reason := m.Evaluate(userContext(1, true), "checkout")
+		return reason == ReasonDisabled
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestManagerStore(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New(1024 * 1024)
+
+	_, err := New(StoreSource(store, "flags"))
+	assert.NotNil(t, err)
+
+	assert.Nil(t, store.Set(ctx, "flags", testFlags, 0))
+	m, err := New(StoreSource(store, "flags"))
+	assert.Nil(t, err)
+	defer m.Close()
+	variant, _ := m.Evaluate(userContext(1, false), "checkout")
+	assert.Equal(t, "off", variant)
+
+	assert.Nil(t, store.Set(ctx, "flags", `{"checkout": {"enabled": true, "variants": [{"name": "on"}], "rules": [{"variant": "on"}]}}`, 0))
+	assert.Nil(t, m.Reload(ctx))
+	variant, _ = m.Evaluate(userContext(1, false), "checkout")
+	assert.Equal(t, "on", variant)
+}
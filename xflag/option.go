@@ -0,0 +1,59 @@
+package xflag
+
+import (
+	"time"
+
+	"github.com/falcolee/xutils/xfilter"
+	"github.com/sirupsen/logrus"
+)
+
+// Option ...
+type Option struct {
+	Engine   *xfilter.Engine // 编译目标规则的引擎
+	Interval time.Duration   // 热加载的检查间隔, 0 表示不自动加载
+	Logger   *logrus.Logger  // 日志
+}
+
+// ----------------------------------------------------------------
+
+// OptionFn ...
+type OptionFn func(*Option)
+
+// WithEngine 使用自定义操作符或变量时传入对应的引擎
+func WithEngine(v *xfilter.Engine) OptionFn {
+	return func(o *Option) {
+		if v != nil {
+			o.Engine = v
+		}
+	}
+}
+
+// WithInterval 热加载的检查间隔, 内容变化时才重新编译
+func WithInterval(v time.Duration) OptionFn {
+	return func(o *Option) {
+		o.Interval = v
+	}
+}
+
+// WithLogger ...
+func WithLogger(v *logrus.Logger) OptionFn {
+	return func(o *Option) {
+		if v != nil {
+			o.Logger = v
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+
+// newOption ...
+func newOption(options ...OptionFn) *Option {
+	o := &Option{
+		Engine: xfilter.Default(),
+		Logger: logrus.StandardLogger(),
+	}
+	for _, fn := range options {
+		fn(o)
+	}
+	return o
+}
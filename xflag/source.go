@@ -0,0 +1,44 @@
+package xflag
+
+import (
+	"context"
+	"os"
+
+	"github.com/falcolee/xutils/xcache"
+)
+
+// Source 开关配置来源, 返回 JSON 内容
+//
+//	{
+//	    "new_checkout": {
+//	        "enabled": true,
+//	        "variants": [{"name": "on", "weight": 20}, {"name": "off", "weight": 80}],
+//	        "rules": [{"filter": "ctx.user.vip = true", "variant": "on"}],
+//	        "default": "off"
+//	    }
+//	}
+type Source interface {
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// SourceFunc ...
+type SourceFunc func(ctx context.Context) ([]byte, error)
+
+// Load ...
+func (f SourceFunc) Load(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// FileSource 从 JSON 文件读取
+func FileSource(path string) Source {
+	return SourceFunc(func(ctx context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	})
+}
+
+// StoreSource 从缓存中读取, 多个实例共享同一份配置
+func StoreSource(store xcache.Store, key string) Source {
+	return SourceFunc(func(ctx context.Context) ([]byte, error) {
+		return store.Get(ctx, key)
+	})
+}
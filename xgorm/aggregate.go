@@ -0,0 +1,241 @@
+/*
+ * @Date: 2026-10-19 18:03:26
+ * @LastEditTime: 2026-10-19 18:03:26
+ * @Description:
+ */
+package xgorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidAggregate aggregate function, bucket or alias is invalid
+var ErrInvalidAggregate = errors.New("xgorm: invalid aggregate")
+
+// AggregateFunc 聚合函数
+type AggregateFunc string
+
+const (
+	AggregateSum           AggregateFunc = "SUM"
+	AggregateAvg           AggregateFunc = "AVG"
+	AggregateMin           AggregateFunc = "MIN"
+	AggregateMax           AggregateFunc = "MAX"
+	AggregateCount         AggregateFunc = "COUNT"
+	AggregateCountDistinct AggregateFunc = "COUNT DISTINCT"
+)
+
+// DateBucket 日期分组粒度, 分组结果统一为字符串
+type DateBucket string
+
+const (
+	// BucketDay 2023-10-01
+	BucketDay DateBucket = "day"
+	// BucketWeek 所在周的周一, 2023-09-25
+	BucketWeek DateBucket = "week"
+	// BucketMonth 2023-10
+	BucketMonth DateBucket = "month"
+)
+
+// Aggregate 聚合字段
+type Aggregate struct {
+	Func  AggregateFunc
+	Field string // COUNT 时为空表示 COUNT(*)
+	As    string // 结果字段名
+}
+
+// Sum ...
+func Sum(field, as string) Aggregate {
+	return Aggregate{Func: AggregateSum, Field: field, As: as}
+}
+
+// Avg ...
+func Avg(field, as string) Aggregate {
+	return Aggregate{Func: AggregateAvg, Field: field, As: as}
+}
+
+// Min ...
+func Min(field, as string) Aggregate {
+	return Aggregate{Func: AggregateMin, Field: field, As: as}
+}
+
+// Max ...
+func Max(field, as string) Aggregate {
+	return Aggregate{Func: AggregateMax, Field: field, As: as}
+}
+
+// Count COUNT(*)
+func Count(as string) Aggregate {
+	return Aggregate{Func: AggregateCount, As: as}
+}
+
+// CountDistinct ...
+func CountDistinct(field, as string) Aggregate {
+	return Aggregate{Func: AggregateCountDistinct, Field: field, As: as}
+}
+
+// GroupBy 分组字段, Bucket 不为空时按日期粒度分组
+type GroupBy struct {
+	Field  string
+	Bucket DateBucket
+	As     string // 为空时使用数据库字段名
+}
+
+// Group ...
+func Group(field string) GroupBy {
+	return GroupBy{Field: field}
+}
+
+// GroupDate 按日期粒度分组
+func GroupDate(field string, bucket DateBucket, as string) GroupBy {
+	return GroupBy{Field: field, Bucket: bucket, As: as}
+}
+
+// AggregateQuery 聚合查询, Filters 与 FindWhere 相同
+//
+//	rows := make([]map[string]interface{}, 0)
+//	err := repo.Aggregate(&Order{}, &rows, xgorm.AggregateQuery{
+//		Filters:    map[string]interface{}{"status": 1},
+//		GroupBy:    []xgorm.GroupBy{xgorm.GroupDate("created_at", xgorm.BucketDay, "day")},
+//		Aggregates: []xgorm.Aggregate{xgorm.Sum("amount", "total"), xgorm.Count("orders")},
+//		OrderBy:    "day",
+//	})
+type AggregateQuery struct {
+	Filters    map[string]interface{}
+	GroupBy    []GroupBy
+	Aggregates []Aggregate
+	OrderBy    string // 只能使用结果字段名
+	Limit      int
+}
+
+// Aggregate 执行聚合查询, dest 为结构体切片或 []map[string]interface{} 的指针
+func (r *gormRepository) Aggregate(model interface{}, dest interface{}, query AggregateQuery) error {
+	r.logger.Debugf("Executing Aggregate on %T with filters = %+v ", model, query.Filters)
+	if len(query.Aggregates) == 0 {
+		return fmt.Errorf("%w: no aggregate", ErrInvalidAggregate)
+	}
+	cond, vals, err := r.whereBuildChecked(model, query.Filters)
+	if err != nil {
+		return err
+	}
+	stmt := r.db.Statement
+	dialect := r.db.Dialector.Name()
+
+	aliases := make(map[string]struct{})
+	selects := make([]string, 0, len(query.GroupBy)+len(query.Aggregates))
+	groups := make([]clause.Column, 0, len(query.GroupBy))
+	for _, group := range query.GroupBy {
+		column, err := r.checkField(model, group.Field)
+		if err != nil {
+			return err
+		}
+		expr := stmt.Quote(column)
+		if group.Bucket != "" {
+			if expr, err = bucketExpr(dialect, group.Bucket, expr); err != nil {
+				return err
+			}
+		}
+		as := group.As
+		if as == "" {
+			as = column
+		}
+		if !isFieldName(as) {
+			return fmt.Errorf("%w: alias %q", ErrInvalidAggregate, as)
+		}
+		aliases[as] = struct{}{}
+		selects = append(selects, expr+" AS "+stmt.Quote(as))
+		groups = append(groups, clause.Column{Name: expr, Raw: true})
+	}
+	for _, aggregate := range query.Aggregates {
+		if !isFieldName(aggregate.As) {
+			return fmt.Errorf("%w: alias %q", ErrInvalidAggregate, aggregate.As)
+		}
+		column := "*"
+		if aggregate.Field != "" {
+			c, err := r.checkField(model, aggregate.Field)
+			if err != nil {
+				return err
+			}
+			column = stmt.Quote(c)
+		}
+		var expr string
+		switch aggregate.Func {
+		case AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+			if column == "*" {
+				return fmt.Errorf("%w: %s requires a field", ErrInvalidAggregate, aggregate.Func)
+			}
+			expr = fmt.Sprintf("%s(%s)", aggregate.Func, column)
+		case AggregateCount:
+			expr = fmt.Sprintf("COUNT(%s)", column)
+		case AggregateCountDistinct:
+			if column == "*" {
+				return fmt.Errorf("%w: %s requires a field", ErrInvalidAggregate, aggregate.Func)
+			}
+			expr = fmt.Sprintf("COUNT(DISTINCT %s)", column)
+		default:
+			return fmt.Errorf("%w: function %q", ErrInvalidAggregate, aggregate.Func)
+		}
+		aliases[aggregate.As] = struct{}{}
+		selects = append(selects, expr+" AS "+stmt.Quote(aggregate.As))
+	}
+
+	db := r.DBWithPreloads(nil).
+		Model(model).
+		Select(strings.Join(selects, ", ")).
+		Where(cond, vals...)
+	if len(groups) > 0 {
+		db = db.Clauses(clause.GroupBy{Columns: groups})
+	}
+	if query.OrderBy != "" {
+		sorts, err := ParseSort(query.OrderBy)
+		if err != nil {
+			return err
+		}
+		for _, sort := range sorts {
+			if _, ok := aliases[sort.Field]; !ok {
+				return &FieldError{Field: sort.Field, Err: ErrInvalidSort}
+			}
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: sort.Field}, Desc: sort.Desc})
+		}
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	res := db.Scan(dest)
+	return r.HandleError(res)
+}
+
+// bucketFormats 日期分组表达式, 不同数据库输出相同格式的字符串
+var bucketFormats = map[DateBucket]map[string]string{
+	BucketDay: {
+		"mysql":    "DATE_FORMAT(%[1]s, '%%Y-%%m-%%d')",
+		"postgres": "TO_CHAR(%[1]s, 'YYYY-MM-DD')",
+		"sqlite":   "strftime('%%Y-%%m-%%d', %[1]s)",
+	},
+	BucketWeek: {
+		"mysql":    "DATE_FORMAT(DATE_SUB(%[1]s, INTERVAL WEEKDAY(%[1]s) DAY), '%%Y-%%m-%%d')",
+		"postgres": "TO_CHAR(DATE_TRUNC('week', %[1]s), 'YYYY-MM-DD')",
+		"sqlite":   "strftime('%%Y-%%m-%%d', %[1]s, 'weekday 0', '-6 days')",
+	},
+	BucketMonth: {
+		"mysql":    "DATE_FORMAT(%[1]s, '%%Y-%%m')",
+		"postgres": "TO_CHAR(%[1]s, 'YYYY-MM')",
+		"sqlite":   "strftime('%%Y-%%m', %[1]s)",
+	},
+}
+
+// bucketExpr ...
+func bucketExpr(dialect string, bucket DateBucket, column string) (string, error) {
+	dialects, ok := bucketFormats[bucket]
+	if !ok {
+		return "", fmt.Errorf("%w: bucket %q", ErrInvalidAggregate, bucket)
+	}
+	format, ok := dialects[dialect]
+	if !ok {
+		return "", fmt.Errorf("%w: bucket is not supported by %s", ErrInvalidAggregate, dialect)
+	}
+	return fmt.Sprintf(format, column), nil
+}
@@ -0,0 +1,127 @@
+package xgorm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type reportOrder struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    int
+	Status    int
+	Amount    float64
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+type reportRow struct {
+	Day    string
+	Total  float64
+	Orders int64
+	Users  int64
+}
+
+func TestAggregate(t *testing.T) {
+	db := newTestDB(t, &reportOrder{})
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+
+	// 2023-09-24 周日, 2023-09-25 周一
+	day := func(d int, h int) time.Time {
+		return time.Date(2023, 9, d, h, 0, 0, 0, time.UTC)
+	}
+	orders := []*reportOrder{
+		{UserID: 1, Status: 1, Amount: 10, CreatedAt: day(24, 10)},
+		{UserID: 1, Status: 1, Amount: 20, CreatedAt: day(25, 9)},
+		{UserID: 2, Status: 1, Amount: 30, CreatedAt: day(25, 18)},
+		{UserID: 3, Status: 0, Amount: 40, CreatedAt: day(30, 12)},
+		{UserID: 3, Status: 1, Amount: 50, CreatedAt: time.Date(2023, 10, 1, 8, 0, 0, 0, time.UTC)},
+		{UserID: 4, Status: 1, Amount: 60, CreatedAt: time.Date(2023, 10, 2, 8, 0, 0, 0, time.UTC)},
+	}
+	assert.Nil(t, db.Create(&orders).Error)
+	// 软删除的数据不参与统计
+	assert.Nil(t, db.Delete(orders[5]).Error)
+
+	rows := make([]reportRow, 0)
+	assert.Nil(t, repo.Aggregate(&reportOrder{}, &rows, AggregateQuery{
+		Filters:    map[string]interface{}{"status": 1},
+		GroupBy:    []GroupBy{GroupDate("created_at", BucketDay, "day")},
+		Aggregates: []Aggregate{Sum("amount", "total"), Count("orders"), CountDistinct("user_id", "users")},
+		OrderBy:    "-day",
+	}))
+	assert.Equal(t, []reportRow{
+		{Day: "2023-10-01", Total: 50, Orders: 1, Users: 1},
+		{Day: "2023-09-25", Total: 50, Orders: 2, Users: 2},
+		{Day: "2023-09-24", Total: 10, Orders: 1, Users: 1},
+	}, rows)
+
+	// 按周分组, 以周一为一周的开始
+	maps := make([]map[string]interface{}, 0)
+	assert.Nil(t, repo.Aggregate(&reportOrder{}, &maps, AggregateQuery{
+		GroupBy:    []GroupBy{GroupDate("CreatedAt", BucketWeek, "week")},
+		Aggregates: []Aggregate{Sum("amount", "total")},
+		OrderBy:    "week",
+	}))
+	assert.Equal(t, 2, len(maps))
+	assert.Equal(t, "2023-09-18", maps[0]["week"])
+	assert.EqualValues(t, 10, maps[0]["total"])
+	assert.Equal(t, "2023-09-25", maps[1]["week"])
+	assert.EqualValues(t, 140, maps[1]["total"])
+
+	maps = make([]map[string]interface{}, 0)
+	assert.Nil(t, repo.Aggregate(&reportOrder{}, &maps, AggregateQuery{
+		GroupBy:    []GroupBy{GroupDate("created_at", BucketMonth, "month"), Group("status")},
+		Aggregates: []Aggregate{Min("amount", "min"), Max("amount", "max"), Avg("amount", "avg")},
+		OrderBy:    "month,-status",
+		Limit:      2,
+	}))
+	assert.Equal(t, 2, len(maps))
+	assert.Equal(t, "2023-09", maps[0]["month"])
+	assert.EqualValues(t, 1, maps[0]["status"])
+	assert.EqualValues(t, 10, maps[0]["min"])
+	assert.EqualValues(t, 30, maps[0]["max"])
+	assert.EqualValues(t, 20, maps[0]["avg"])
+	assert.EqualValues(t, 0, maps[1]["status"])
+
+	// 不分组时返回一行汇总
+	var total struct {
+		Total float64
+		Count int64
+	}
+	assert.Nil(t, repo.Aggregate(&reportOrder{}, &total, AggregateQuery{
+		Filters:    map[string]interface{}{"amount >": 15},
+		Aggregates: []Aggregate{Sum("amount", "total"), Count("count")},
+	}))
+	assert.Equal(t, float64(140), total.Total)
+	assert.Equal(t, int64(4), total.Count)
+
+	errs := []AggregateQuery{
+		{},
+		{Aggregates: []Aggregate{Sum("password", "total")}},
+		{Aggregates: []Aggregate{Sum("", "total")}},
+		{Aggregates: []Aggregate{{Func: "MEDIAN", Field: "amount", As: "m"}}},
+		{Aggregates: []Aggregate{Sum("amount", "a b")}},
+		{Aggregates: []Aggregate{Count("c")}, GroupBy: []GroupBy{GroupDate("created_at", "year", "y")}},
+		{Aggregates: []Aggregate{Count("c")}, OrderBy: "amount"},
+	}
+	for _, query := range errs {
+		assert.NotNil(t, repo.Aggregate(&reportOrder{}, &maps, query))
+	}
+	err := repo.Aggregate(&reportOrder{}, &maps, AggregateQuery{Aggregates: []Aggregate{Count("c")}, OrderBy: "amount"})
+	assert.True(t, errors.Is(err, ErrInvalidSort))
+}
+
+func TestBucketExpr(t *testing.T) {
+	expr, err := bucketExpr("mysql", BucketDay, "`created_at`")
+	assert.Nil(t, err)
+	assert.Equal(t, "DATE_FORMAT(`created_at`, '%Y-%m-%d')", expr)
+	expr, err = bucketExpr("postgres", BucketWeek, `"created_at"`)
+	assert.Nil(t, err)
+	assert.Equal(t, `TO_CHAR(DATE_TRUNC('week', "created_at"), 'YYYY-MM-DD')`, expr)
+	_, err = bucketExpr("sqlserver", BucketDay, "created_at")
+	assert.True(t, errors.Is(err, ErrInvalidAggregate))
+}
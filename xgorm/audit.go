@@ -0,0 +1,475 @@
+/*
+ * @Date: 2026-10-19 16:05:18
+ * @LastEditTime: 2026-10-19 16:05:18
+ * @Description:
+ */
+package xgorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/falcolee/xutils/xjson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// AuditCreate ...
+	AuditCreate = "create"
+	// AuditUpdate ...
+	AuditUpdate = "update"
+	// AuditDelete 物理删除
+	AuditDelete = "delete"
+	// AuditSoftDelete 软删除
+	AuditSoftDelete = "soft_delete"
+)
+
+// auditBeforeKey 更新或删除前的数据快照
+const auditBeforeKey = "xgorm:audit:before"
+
+type actorKey struct{}
+
+// NewActor 在 context 中设置操作人, 审计记录通过 db.WithContext(ctx) 获取
+func NewActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext ...
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditChange 字段变更前后的值
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditRecord 审计记录, 每行数据一条
+type AuditRecord struct {
+	ID         uint64                 `gorm:"primaryKey" json:"id"`
+	Actor      string                 `gorm:"size:255;index" json:"actor"`
+	Action     string                 `gorm:"size:16" json:"action"`
+	Table      string                 `gorm:"size:255;index:idx_audit_row" json:"table"`
+	PrimaryKey string                 `gorm:"size:255;index:idx_audit_row" json:"primary_key"`
+	Changes    string                 `gorm:"type:text" json:"-"`
+	Diff       map[string]AuditChange `gorm:"-" json:"changes"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AuditSink 审计记录的存储, tx 为触发审计的数据库会话, 可以在同一事务中写入
+type AuditSink interface {
+	Write(tx *gorm.DB, record *AuditRecord) error
+}
+
+// AuditSinkFunc ...
+type AuditSinkFunc func(tx *gorm.DB, record *AuditRecord) error
+
+// Write ...
+func (f AuditSinkFunc) Write(tx *gorm.DB, record *AuditRecord) error {
+	return f(tx, record)
+}
+
+// AuditTableSink 写入审计表, 与业务数据在同一事务中
+type AuditTableSink struct {
+	Table string
+}
+
+// Write ...
+func (s *AuditTableSink) Write(tx *gorm.DB, record *AuditRecord) error {
+	record.Changes = xjson.Encode(record.Diff)
+	return tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(s.Table).Create(record).Error
+}
+
+// ----------------------------------------------------------------
+
+// Audit 审计插件, 记录创建, 更新和删除的操作人与字段变更
+//
+//	db.Use(xgorm.NewAudit(xgorm.WithAuditTables("users", "orders")))
+//	db.WithContext(xgorm.NewActor(ctx, "brick")).Save(&user)
+type Audit struct {
+	sink    AuditSink
+	actor   func(ctx context.Context) string
+	tables  map[string]struct{}
+	ignores map[string]struct{}
+}
+
+// AuditOption ...
+type AuditOption func(*Audit)
+
+// WithAuditSink 自定义存储, 默认写入 audit_logs 表
+func WithAuditSink(sink AuditSink) AuditOption {
+	return func(a *Audit) {
+		if sink != nil {
+			a.sink = sink
+		}
+	}
+}
+
+// WithAuditTables 只审计指定的表, 默认审计全部表
+func WithAuditTables(tables ...string) AuditOption {
+	return func(a *Audit) {
+		for _, table := range tables {
+			a.tables[table] = struct{}{}
+		}
+	}
+}
+
+// WithAuditIgnoreColumns 不记录的字段, 如 updated_at
+func WithAuditIgnoreColumns(columns ...string) AuditOption {
+	return func(a *Audit) {
+		for _, column := range columns {
+			a.ignores[column] = struct{}{}
+		}
+	}
+}
+
+// WithAuditActor 自定义获取操作人的方式, 默认为 ActorFromContext
+func WithAuditActor(fn func(ctx context.Context) string) AuditOption {
+	return func(a *Audit) {
+		if fn != nil {
+			a.actor = fn
+		}
+	}
+}
+
+// NewAudit ...
+func NewAudit(opts ...AuditOption) *Audit {
+	a := &Audit{
+		sink:    &AuditTableSink{Table: "audit_logs"},
+		actor:   ActorFromContext,
+		tables:  make(map[string]struct{}),
+		ignores: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Name ...
+func (a *Audit) Name() string {
+	return "xgorm:audit"
+}
+
+// Initialize 注册回调, 使用默认存储时自动创建审计表
+func (a *Audit) Initialize(db *gorm.DB) error {
+	if sink, ok := a.sink.(*AuditTableSink); ok {
+		if err := db.Table(sink.Table).AutoMigrate(&AuditRecord{}); err != nil {
+			return err
+		}
+	}
+	callback := db.Callback()
+	if err := callback.Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").
+		Register("xgorm:audit_after_create", a.afterCreate); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:begin_transaction").Before("gorm:update").
+		Register("xgorm:audit_before_update", a.before); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").
+		Register("xgorm:audit_after_update", a.afterUpdate); err != nil {
+		return err
+	}
+	if err := callback.Delete().After("gorm:begin_transaction").Before("gorm:delete").
+		Register("xgorm:audit_before_delete", a.before); err != nil {
+		return err
+	}
+	return callback.Delete().After("gorm:delete").Before("gorm:commit_or_rollback_transaction").
+		Register("xgorm:audit_after_delete", a.afterDelete)
+}
+
+// ----------------------------------------------------------------
+
+// enabled 需要模型 schema 与主键才能定位数据
+func (a *Audit) enabled(db *gorm.DB) bool {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || len(stmt.Schema.PrimaryFields) == 0 {
+		return false
+	}
+	if sink, ok := a.sink.(*AuditTableSink); ok && stmt.Table == sink.Table {
+		return false
+	}
+	if len(a.tables) == 0 {
+		return true
+	}
+	_, ok := a.tables[stmt.Table]
+	return ok
+}
+
+// before 记录更新或删除前的数据
+func (a *Audit) before(db *gorm.DB) {
+	if !a.enabled(db) {
+		return
+	}
+	rows, err := a.snapshot(db, nil)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+func (a *Audit) afterCreate(db *gorm.DB) {
+	if !a.enabled(db) || db.RowsAffected == 0 {
+		return
+	}
+	keys := a.reflectKeys(db)
+	if len(keys) == 0 {
+		return
+	}
+	rows, err := a.snapshot(db, keys)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	for _, row := range rows {
+		a.write(db, AuditCreate, row, nil, row)
+	}
+}
+
+func (a *Audit) afterUpdate(db *gorm.DB) {
+	if !a.enabled(db) || db.RowsAffected == 0 {
+		return
+	}
+	a.writeChanges(db, AuditUpdate)
+}
+
+func (a *Audit) afterDelete(db *gorm.DB) {
+	if !a.enabled(db) || db.RowsAffected == 0 {
+		return
+	}
+	// 软删除是对 deleted_at 的更新, 记录字段变更
+	if !db.Statement.Unscoped && softDeleteField(db.Statement.Schema) != nil {
+		a.writeChanges(db, AuditSoftDelete)
+		return
+	}
+	value, ok := db.InstanceGet(auditBeforeKey)
+	if !ok {
+		return
+	}
+	for _, row := range value.([]map[string]interface{}) {
+		a.write(db, AuditDelete, row, row, nil)
+	}
+}
+
+// writeChanges 重新查询语句执行前的行, 记录前后差异
+func (a *Audit) writeChanges(db *gorm.DB, action string) {
+	value, ok := db.InstanceGet(auditBeforeKey)
+	if !ok {
+		return
+	}
+	before := value.([]map[string]interface{})
+	keys := make([][]interface{}, 0, len(before))
+	for _, row := range before {
+		keys = append(keys, a.rowKey(db, row))
+	}
+	after, err := a.snapshot(db, keys)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	afterByKey := make(map[string]map[string]interface{}, len(after))
+	for _, row := range after {
+		afterByKey[a.primaryKey(db, row)] = row
+	}
+	for _, row := range before {
+		if changed, ok := afterByKey[a.primaryKey(db, row)]; ok {
+			a.write(db, action, row, row, changed)
+		}
+	}
+}
+
+// write 计算变更字段并写入存储, 没有变更时不记录
+func (a *Audit) write(db *gorm.DB, action string, row, before, after map[string]interface{}) {
+	diff := make(map[string]AuditChange)
+	columns := make(map[string]struct{})
+	for column := range before {
+		columns[column] = struct{}{}
+	}
+	for column := range after {
+		columns[column] = struct{}{}
+	}
+	for column := range columns {
+		if _, ok := a.ignores[column]; ok {
+			continue
+		}
+		bv, av := before[column], after[column]
+		if before != nil && after != nil && fmt.Sprint(bv) == fmt.Sprint(av) {
+			continue
+		}
+		if bv == nil && av == nil {
+			continue
+		}
+		diff[column] = AuditChange{Before: bv, After: av}
+	}
+	if len(diff) == 0 {
+		return
+	}
+	record := &AuditRecord{
+		Actor:      a.actor(db.Statement.Context),
+		Action:     action,
+		Table:      db.Statement.Table,
+		PrimaryKey: a.primaryKey(db, row),
+		Diff:       diff,
+		CreatedAt:  time.Now(),
+	}
+	if err := a.sink.Write(db, record); err != nil {
+		db.AddError(fmt.Errorf("xgorm: audit: %w", err))
+	}
+}
+
+// snapshot 查询受影响的行, keys 为空时使用原语句的条件
+func (a *Audit) snapshot(db *gorm.DB, keys [][]interface{}) ([]map[string]interface{}, error) {
+	stmt := db.Statement
+	tx := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(stmt.Table)
+	columns := primaryColumns(stmt.Schema)
+	if keys != nil {
+		if len(keys) == 0 {
+			return nil, nil
+		}
+		tx = tx.Unscoped().Where(clause.IN{Column: columnsExpr(columns), Values: tuples(keys)})
+	} else {
+		conditions := 0
+		if c, ok := stmt.Clauses["WHERE"]; ok {
+			if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+				tx = tx.Where(clause.And(where.Exprs...))
+				conditions++
+			}
+		}
+		if pks := a.reflectKeys(db); len(pks) > 0 {
+			tx = tx.Where(clause.IN{Column: columnsExpr(columns), Values: tuples(pks)})
+			conditions++
+		}
+		if conditions == 0 && !stmt.AllowGlobalUpdate {
+			return nil, nil
+		}
+		if field := softDeleteField(stmt.Schema); field != nil && !stmt.Unscoped {
+			tx = tx.Where(clause.Eq{Column: clause.Column{Name: field.DBName}, Value: nil})
+		}
+	}
+	rows := make([]map[string]interface{}, 0)
+	if err := tx.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for k, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[k] = string(b)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// reflectKeys 语句中模型的非零主键
+func (a *Audit) reflectKeys(db *gorm.DB) [][]interface{} {
+	stmt := db.Statement
+	rv := reflect.Indirect(stmt.ReflectValue)
+	items := make([]reflect.Value, 0)
+	switch rv.Kind() {
+	case reflect.Struct:
+		items = append(items, rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, reflect.Indirect(rv.Index(i)))
+		}
+	}
+	res := make([][]interface{}, 0, len(items))
+	for _, item := range items {
+		if item.Kind() != reflect.Struct {
+			continue
+		}
+		key := make([]interface{}, 0, len(stmt.Schema.PrimaryFields))
+		for _, field := range stmt.Schema.PrimaryFields {
+			value, zero := field.ValueOf(stmt.Context, item)
+			if zero {
+				key = nil
+				break
+			}
+			key = append(key, value)
+		}
+		if key != nil {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// rowKey 查询结果中的主键
+func (a *Audit) rowKey(db *gorm.DB, row map[string]interface{}) []interface{} {
+	columns := primaryColumns(db.Statement.Schema)
+	key := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		key = append(key, row[column])
+	}
+	return key
+}
+
+// primaryKey 主键的字符串形式, 联合主键用逗号连接
+func (a *Audit) primaryKey(db *gorm.DB, row map[string]interface{}) string {
+	key := a.rowKey(db, row)
+	values := make([]string, 0, len(key))
+	for _, v := range key {
+		values = append(values, fmt.Sprint(v))
+	}
+	return strings.Join(values, ",")
+}
+
+// primaryColumns ...
+func primaryColumns(s *schema.Schema) []string {
+	columns := make([]string, 0, len(s.PrimaryFields))
+	for _, field := range s.PrimaryFields {
+		columns = append(columns, field.DBName)
+	}
+	return columns
+}
+
+// columnsExpr 单个字段或联合主键
+func columnsExpr(columns []string) interface{} {
+	if len(columns) == 1 {
+		return clause.Column{Name: columns[0]}
+	}
+	res := make([]clause.Column, 0, len(columns))
+	for _, column := range columns {
+		res = append(res, clause.Column{Name: column})
+	}
+	return res
+}
+
+// tuples 单个主键直接使用值, 联合主键使用元组
+func tuples(keys [][]interface{}) []interface{} {
+	res := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if len(key) == 1 {
+			res = append(res, key[0])
+		} else {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// softDeleteField 模型的 gorm.DeletedAt 字段
+func softDeleteField(s *schema.Schema) *schema.Field {
+	if s == nil {
+		return nil
+	}
+	deletedAt := reflect.TypeOf(gorm.DeletedAt{})
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAt {
+			return field
+		}
+	}
+	return nil
+}
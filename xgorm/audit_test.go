@@ -0,0 +1,123 @@
+package xgorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/falcolee/xutils/xjson"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type auditUser struct {
+	ID        uint `gorm:"primarykey"`
+	Name      string
+	Age       int
+	DeletedAt gorm.DeletedAt
+}
+
+type auditTag struct {
+	ID   uint `gorm:"primarykey"`
+	Name string
+}
+
+func TestActor(t *testing.T) {
+	assert.Equal(t, "", ActorFromContext(context.Background()))
+	assert.Equal(t, "brick", ActorFromContext(NewActor(context.Background(), "brick")))
+}
+
+func TestAudit(t *testing.T) {
+	db := newTestDB(t, &auditUser{}, &auditTag{})
+	assert.Nil(t, db.Use(NewAudit(WithAuditTables("audit_users"))))
+	ctx := NewActor(context.Background(), "brick")
+	tx := db.WithContext(ctx)
+
+	user := &auditUser{Name: "tom", Age: 18}
+	assert.Nil(t, tx.Create(user).Error)
+	assert.Nil(t, tx.Model(user).Update("age", 19).Error)
+	// 没有变化不记录
+	assert.Nil(t, tx.Model(user).Update("age", 19).Error)
+	assert.Nil(t, tx.Delete(user).Error)
+	assert.Nil(t, tx.Unscoped().Delete(user).Error)
+	// 不在审计范围内的表
+	assert.Nil(t, tx.Create(&auditTag{Name: "vip"}).Error)
+
+	records := make([]*AuditRecord, 0)
+	assert.Nil(t, db.Table("audit_logs").Order("id").Find(&records).Error)
+	assert.Equal(t, 4, len(records))
+	actions := make([]string, 0)
+	for _, record := range records {
+		assert.Equal(t, "brick", record.Actor)
+		assert.Equal(t, "audit_users", record.Table)
+		assert.Equal(t, "1", record.PrimaryKey)
+		actions = append(actions, record.Action)
+	}
+	assert.Equal(t, []string{AuditCreate, AuditUpdate, AuditSoftDelete, AuditDelete}, actions)
+
+	changes := make(map[string]AuditChange)
+	assert.Nil(t, xjson.Decode(records[0].Changes, &changes))
+	assert.Nil(t, changes["name"].Before)
+	assert.Equal(t, "tom", changes["name"].After)
+	_, ok := changes["deleted_at"]
+	assert.False(t, ok)
+
+	changes = make(map[string]AuditChange)
+	assert.Nil(t, xjson.Decode(records[1].Changes, &changes))
+	assert.Equal(t, 1, len(changes))
+	assert.EqualValues(t, 18, changes["age"].Before)
+	assert.EqualValues(t, 19, changes["age"].After)
+
+	changes = make(map[string]AuditChange)
+	assert.Nil(t, xjson.Decode(records[2].Changes, &changes))
+	assert.Equal(t, 1, len(changes))
+	assert.Nil(t, changes["deleted_at"].Before)
+	assert.NotNil(t, changes["deleted_at"].After)
+
+	changes = make(map[string]AuditChange)
+	assert.Nil(t, xjson.Decode(records[3].Changes, &changes))
+	assert.Equal(t, "tom", changes["name"].Before)
+	assert.Nil(t, changes["name"].After)
+}
+
+func TestAuditSink(t *testing.T) {
+	db := newTestDB(t, &auditUser{})
+	records := make([]*AuditRecord, 0)
+	errSink := errors.New("sink")
+	fail := false
+	sink := AuditSinkFunc(func(tx *gorm.DB, record *AuditRecord) error {
+		if fail {
+			return errSink
+		}
+		records = append(records, record)
+		return nil
+	})
+	assert.Nil(t, db.Use(NewAudit(
+		WithAuditSink(sink),
+		WithAuditIgnoreColumns("name"),
+		WithAuditActor(func(ctx context.Context) string { return "system" }),
+	)))
+	assert.False(t, db.Migrator().HasTable("audit_logs"))
+
+	users := []*auditUser{{Name: "a", Age: 1}, {Name: "b", Age: 2}}
+	assert.Nil(t, db.Create(&users).Error)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "2", records[1].PrimaryKey)
+
+	// 批量更新每行一条记录, 忽略的字段不记录
+	assert.Nil(t, db.Model(&auditUser{}).Where("age > ?", 0).
+		Updates(map[string]interface{}{"name": "c", "age": 3}).Error)
+	assert.Equal(t, 4, len(records))
+	assert.Equal(t, "system", records[3].Actor)
+	assert.Equal(t, 1, len(records[3].Diff))
+	_, ok := records[3].Diff["age"]
+	assert.True(t, ok)
+
+	// 存储失败时回滚
+	fail = true
+	err := db.Model(users[0]).Update("age", 10).Error
+	assert.True(t, errors.Is(err, errSink))
+	user := &auditUser{}
+	assert.Nil(t, db.First(user, users[0].ID).Error)
+	assert.Equal(t, 3, user.Age)
+}
@@ -0,0 +1,512 @@
+/*
+ * @Date: 2026-10-19 17:20:44
+ * @LastEditTime: 2026-10-19 17:20:44
+ * @Description:
+ */
+package xgorm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/falcolee/xutils/xcrypto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// EncryptedSerializer 加密字段的 serializer 名称, 如 `gorm:"serializer:encrypted"`
+const EncryptedSerializer = "encrypted"
+
+// BlindIndexTag 盲索引字段标签, 如 `gorm:"serializer:encrypted" xgorm:"blind_index:phone_index"`
+const BlindIndexTag = "blind_index"
+
+var (
+	// ErrUnknownKey key id not found in key ring
+	ErrUnknownKey = errors.New("xgorm: unknown encryption key")
+	// ErrInvalidCiphertext ciphertext is malformed or tampered
+	ErrInvalidCiphertext = errors.New("xgorm: invalid ciphertext")
+	// ErrNoBlindIndexKey blind index key is not configured
+	ErrNoBlindIndexKey = errors.New("xgorm: blind index key not configured")
+	// ErrNoKeyRing encryption plugin is not used on the db
+	ErrNoKeyRing = errors.New("xgorm: encryption key ring not found")
+)
+
+// KeyRingConfig 密钥配置, 密钥为 base64 编码的 16, 24 或 32 字节
+//
+//	{"primary": "2023", "keys": {"2022": "...", "2023": "..."}, "blind_index_key": "..."}
+type KeyRingConfig struct {
+	Primary       string            `json:"primary" yaml:"primary"`                 // 新数据使用的密钥
+	Keys          map[string]string `json:"keys" yaml:"keys"`                       // key id => 密钥, 旧密钥保留用于解密
+	BlindIndexKey string            `json:"blind_index_key" yaml:"blind_index_key"` // 盲索引 hmac 密钥, 不随加密密钥轮换
+}
+
+// KeyRing 密钥环, 使用 AES-GCM 加密, 密文格式为 key_id:base64(nonce+ciphertext)
+type KeyRing struct {
+	primary  string
+	aeads    map[string]cipher.AEAD
+	blindKey string
+}
+
+// NewKeyRing ...
+func NewKeyRing(config KeyRingConfig) (*KeyRing, error) {
+	if _, ok := config.Keys[config.Primary]; !ok {
+		return nil, fmt.Errorf("%w: primary %q", ErrUnknownKey, config.Primary)
+	}
+	ring := &KeyRing{
+		primary: config.Primary,
+		aeads:   make(map[string]cipher.AEAD, len(config.Keys)),
+	}
+	for id, encoded := range config.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("xgorm: invalid key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("xgorm: key %s: %w", id, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("xgorm: key %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("xgorm: key %s: %w", id, err)
+		}
+		ring.aeads[id] = aead
+	}
+	if config.BlindIndexKey != "" {
+		key, err := base64.StdEncoding.DecodeString(config.BlindIndexKey)
+		if err != nil {
+			return nil, fmt.Errorf("xgorm: blind index key: %w", err)
+		}
+		ring.blindKey = string(key)
+	}
+	return ring, nil
+}
+
+// Encrypt 使用主密钥加密
+func (k *KeyRing) Encrypt(plaintext []byte) (string, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 根据密文中的 key id 选择密钥解密
+func (k *KeyRing) Decrypt(ciphertext string) ([]byte, error) {
+	id, encoded, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return nil, ErrInvalidCiphertext
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+// KeyID 返回密文使用的 key id, 与 Primary 不同时说明需要重新加密
+func (k *KeyRing) KeyID(ciphertext string) string {
+	id, _, _ := strings.Cut(ciphertext, ":")
+	return id
+}
+
+// Primary 主密钥 id
+func (k *KeyRing) Primary() string {
+	return k.primary
+}
+
+// BlindIndex 计算确定性的盲索引, 相同明文得到相同结果, 用于等值查询
+func (k *KeyRing) BlindIndex(plaintext string) (string, error) {
+	if k.blindKey == "" {
+		return "", ErrNoBlindIndexKey
+	}
+	return xcrypto.HmacSha256(plaintext, k.blindKey), nil
+}
+
+// ----------------------------------------------------------------
+
+// keyRingKey 密钥环在 statement context 中的 key
+type keyRingKey struct{}
+
+// keyRingFromContext ...
+func keyRingFromContext(ctx context.Context) (*KeyRing, error) {
+	if ctx != nil {
+		if ring, ok := ctx.Value(keyRingKey{}).(*KeyRing); ok {
+			return ring, nil
+		}
+	}
+	return nil, ErrNoKeyRing
+}
+
+// encryptedSerializer 字符串与 []byte 直接加密, 其他类型编码为 json 后加密
+// 空值不加密, 保存为空字符串或 NULL
+// serializer 为全局注册, 密钥环由 Encryption 写入 context, 不同的 db 可以使用不同的密钥环
+type encryptedSerializer struct{}
+
+// Scan ...
+func (s *encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+	var ciphertext string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		ciphertext = string(v)
+	case string:
+		ciphertext = v
+	default:
+		return fmt.Errorf("xgorm: failed to decrypt value: %#v", dbValue)
+	}
+	if ciphertext != "" {
+		ring, err := keyRingFromContext(ctx)
+		if err != nil {
+			return err
+		}
+		plaintext, err := ring.Decrypt(ciphertext)
+		if err != nil {
+			return fmt.Errorf("xgorm: decrypt %s: %w", field.Name, err)
+		}
+		if err := decodePlaintext(fieldValue.Elem(), plaintext); err != nil {
+			return err
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// Value ...
+func (s *encryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, null, err := encodePlaintext(fieldValue)
+	if err != nil {
+		return nil, err
+	}
+	if null {
+		return nil, nil
+	}
+	if len(plaintext) == 0 {
+		return "", nil
+	}
+	ring, err := keyRingFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ring.Encrypt(plaintext)
+}
+
+// encodePlaintext 字段值转为明文, null 表示 nil 指针
+func encodePlaintext(value interface{}) (plaintext []byte, null bool, err error) {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, true, nil
+		}
+		rv = rv.Elem()
+	}
+	switch {
+	case !rv.IsValid():
+		return nil, true, nil
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), false, nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return rv.Bytes(), false, nil
+	}
+	plaintext, err = json.Marshal(rv.Interface())
+	return plaintext, false, err
+}
+
+// decodePlaintext 明文写入字段值
+func decodePlaintext(rv reflect.Value, plaintext []byte) error {
+	if rv.Kind() == reflect.Ptr {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	switch {
+	case rv.Kind() == reflect.String:
+		rv.SetString(string(plaintext))
+		return nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		rv.SetBytes(plaintext)
+		return nil
+	}
+	return json.Unmarshal(plaintext, rv.Addr().Interface())
+}
+
+// ----------------------------------------------------------------
+
+// Encryption 字段加密插件, 注册 encrypted serializer 并维护盲索引字段
+// 需要在解析模型之前调用 db.Use
+//
+//	type User struct {
+//		ID         uint
+//		Phone      string `gorm:"serializer:encrypted" xgorm:"blind_index:phone_index"`
+//		PhoneIndex string `gorm:"size:64;index"`
+//	}
+//	db.Use(xgorm.NewEncryption(ring))
+//	repo.FindWhere(&users, map[string]interface{}{"phone": "13800000000"})
+type Encryption struct {
+	ring *KeyRing
+}
+
+// NewEncryption ...
+func NewEncryption(ring *KeyRing) *Encryption {
+	return &Encryption{ring: ring}
+}
+
+// Name ...
+func (e *Encryption) Name() string {
+	return "xgorm:encryption"
+}
+
+// KeyRing ...
+func (e *Encryption) KeyRing() *KeyRing {
+	return e.ring
+}
+
+// Initialize serializer 为全局注册, 执行前把密钥环写入 statement context
+func (e *Encryption) Initialize(db *gorm.DB) error {
+	schema.RegisterSerializer(EncryptedSerializer, &encryptedSerializer{})
+	callback := db.Callback()
+	for _, register := range []func(string, func(*gorm.DB)) error{
+		callback.Create().Before("*").Register,
+		callback.Query().Before("*").Register,
+		callback.Update().Before("*").Register,
+		callback.Delete().Before("*").Register,
+		callback.Row().Before("*").Register,
+		callback.Raw().Before("*").Register,
+	} {
+		if err := register("xgorm:encryption_key_ring", e.withKeyRing); err != nil {
+			return err
+		}
+	}
+	if err := callback.Create().Before("gorm:create").Register("xgorm:encryption_before_create", e.before); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("xgorm:encryption_before_update", e.before); err != nil {
+		return err
+	}
+	return callback.Update().After("gorm:update").Register("xgorm:encryption_after_update", e.afterUpdate)
+}
+
+// withKeyRing 密钥环写入 statement context, 供 encrypted serializer 使用
+func (e *Encryption) withKeyRing(db *gorm.DB) {
+	db.Statement.Context = context.WithValue(db.Statement.Context, keyRingKey{}, e.ring)
+}
+
+// encryptedPlaintextKey map 更新时加密前的明文, 更新后写回模型
+const encryptedPlaintextKey = "xgorm:encryption:plaintext"
+
+// before 计算盲索引, map 更新时手动加密
+func (e *Encryption) before(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	fields := encryptedFields(stmt.Schema)
+	if len(fields) == 0 {
+		return
+	}
+	if dest, ok := stmt.Dest.(map[string]interface{}); ok {
+		// 复制后再写入密文, 调用方的 map 保持明文, 可以重复使用
+		updates := make(map[string]interface{}, len(dest))
+		for k, v := range dest {
+			updates[k] = v
+		}
+		stmt.Dest = updates
+		plaintexts := make(map[*schema.Field]interface{})
+		for _, field := range fields {
+			keys := []string{field.Name}
+			if field.DBName != field.Name {
+				keys = append(keys, field.DBName)
+			}
+			for _, key := range keys {
+				value, ok := updates[key]
+				if !ok {
+					continue
+				}
+				encrypted, err := field.Serializer.Value(stmt.Context, field, stmt.ReflectValue, value)
+				if err != nil {
+					db.AddError(err)
+					return
+				}
+				updates[key] = encrypted
+				plaintexts[field] = value
+				if blind := blindIndexField(stmt.Schema, field); blind != nil {
+					index, err := e.blindIndex(value)
+					if err != nil {
+						db.AddError(err)
+						return
+					}
+					updates[blind.DBName] = index
+				}
+			}
+		}
+		db.InstanceSet(encryptedPlaintextKey, plaintexts)
+		return
+	}
+	targets := []reflect.Value{stmt.ReflectValue}
+	if dest := reflect.Indirect(reflect.ValueOf(stmt.Dest)); stmt.Dest != stmt.Model && dest.Kind() == reflect.Struct && dest.CanAddr() {
+		targets = append(targets, dest)
+	}
+	for _, target := range targets {
+		if err := e.setBlindIndexes(stmt, fields, target); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+// afterUpdate 把 map 更新写入模型的密文替换为明文
+func (e *Encryption) afterUpdate(db *gorm.DB) {
+	value, ok := db.InstanceGet(encryptedPlaintextKey)
+	if !ok || !db.Statement.ReflectValue.CanAddr() {
+		return
+	}
+	stmt := db.Statement
+	for field, plaintext := range value.(map[*schema.Field]interface{}) {
+		switch stmt.ReflectValue.Kind() {
+		case reflect.Struct:
+			_ = field.Set(stmt.Context, stmt.ReflectValue, plaintext)
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < stmt.ReflectValue.Len(); i++ {
+				_ = field.Set(stmt.Context, stmt.ReflectValue.Index(i), plaintext)
+			}
+		}
+	}
+}
+
+// setBlindIndexes 根据加密字段的明文设置盲索引字段
+func (e *Encryption) setBlindIndexes(stmt *gorm.Statement, fields []*schema.Field, rv reflect.Value) error {
+	rv = reflect.Indirect(rv)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := e.setBlindIndexes(stmt, fields, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for _, field := range fields {
+			blind := blindIndexField(stmt.Schema, field)
+			if blind == nil {
+				continue
+			}
+			// serializer 字段的 ValueOf 返回包装后的值, 直接读取字段
+			index, err := e.blindIndex(field.ReflectValueOf(stmt.Context, rv).Interface())
+			if err != nil {
+				return err
+			}
+			if err := blind.Set(stmt.Context, rv, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// blindIndex 空值的盲索引为空字符串
+func (e *Encryption) blindIndex(value interface{}) (string, error) {
+	plaintext, null, err := encodePlaintext(value)
+	if err != nil || null || len(plaintext) == 0 {
+		return "", err
+	}
+	return e.ring.BlindIndex(string(plaintext))
+}
+
+// encryptedFields 使用 encrypted serializer 的字段
+func encryptedFields(s *schema.Schema) []*schema.Field {
+	res := make([]*schema.Field, 0)
+	for _, field := range s.Fields {
+		if strings.EqualFold(field.TagSettings["SERIALIZER"], EncryptedSerializer) && field.Serializer != nil {
+			res = append(res, field)
+		}
+	}
+	return res
+}
+
+// blindIndexField 加密字段对应的盲索引字段
+func blindIndexField(s *schema.Schema, field *schema.Field) *schema.Field {
+	for _, v := range strings.Split(field.Tag.Get("xgorm"), ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(v), ":")
+		if ok && name == BlindIndexTag {
+			return s.LookUpField(strings.TrimSpace(value))
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+
+// blindFilters 把加密字段的等值条件改写为盲索引字段, 其他操作符不支持
+func (r *gormRepository) blindFilters(target interface{}, filters map[string]interface{}) (map[string]interface{}, error) {
+	plugin, ok := r.db.Config.Plugins[(&Encryption{}).Name()].(*Encryption)
+	if !ok || len(filters) == 0 {
+		return filters, nil
+	}
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(target); err != nil {
+		return nil, err
+	}
+	if len(encryptedFields(stmt.Schema)) == 0 {
+		return filters, nil
+	}
+	res := make(map[string]interface{}, len(filters))
+	for k, v := range filters {
+		ks := strings.Split(k, " ")
+		field := stmt.Schema.LookUpField(ks[0])
+		if field == nil || !strings.EqualFold(field.TagSettings["SERIALIZER"], EncryptedSerializer) {
+			res[k] = v
+			continue
+		}
+		blind := blindIndexField(stmt.Schema, field)
+		op := "="
+		if len(ks) > 1 {
+			op = strings.ToLower(ks[1])
+		}
+		if blind == nil || (op != "=" && op != "in") {
+			return nil, &FieldError{Field: k, Err: ErrOperatorNotAllowed}
+		}
+		ks[0] = blind.DBName
+		if op == "in" {
+			values := reflect.ValueOf(v)
+			if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+				return nil, &FieldError{Field: k, Err: ErrOperatorNotAllowed}
+			}
+			indexes := make([]string, 0, values.Len())
+			for i := 0; i < values.Len(); i++ {
+				index, err := plugin.blindIndex(values.Index(i).Interface())
+				if err != nil {
+					return nil, err
+				}
+				indexes = append(indexes, index)
+			}
+			res[strings.Join(ks, " ")] = indexes
+			continue
+		}
+		index, err := plugin.blindIndex(v)
+		if err != nil {
+			return nil, err
+		}
+		res[strings.Join(ks, " ")] = index
+	}
+	return res, nil
+}
@@ -0,0 +1,254 @@
+package xgorm
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type secretAddress struct {
+	City   string `json:"city"`
+	Street string `json:"street"`
+}
+
+type secretUser struct {
+	ID         uint `gorm:"primarykey"`
+	Name       string
+	Phone      string         `gorm:"serializer:encrypted" xgorm:"blind_index:phone_index"`
+	PhoneIndex string         `gorm:"size:64;index"`
+	IDCard     *string        `gorm:"serializer:encrypted"`
+	Address    *secretAddress `gorm:"serializer:encrypted"`
+}
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func newTestKeyRing(t *testing.T, primary string) *KeyRing {
+	ring, err := NewKeyRing(KeyRingConfig{
+		Primary:       primary,
+		Keys:          map[string]string{"k1": testKey('a'), "k2": testKey('b')},
+		BlindIndexKey: testKey('c'),
+	})
+	assert.Nil(t, err)
+	return ring
+}
+
+// newEncryptedDB 加密插件需要在解析模型之前注册
+func newEncryptedDB(t *testing.T, ring *KeyRing) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Use(NewEncryption(ring)))
+	assert.Nil(t, db.AutoMigrate(&secretUser{}))
+	return db
+}
+
+func TestKeyRing(t *testing.T) {
+	_, err := NewKeyRing(KeyRingConfig{Primary: "k3", Keys: map[string]string{"k1": testKey('a')}})
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+	_, err = NewKeyRing(KeyRingConfig{Primary: "k1", Keys: map[string]string{"k1": "short"}})
+	assert.NotNil(t, err)
+
+	old := newTestKeyRing(t, "k1")
+	ciphertext, err := old.Encrypt([]byte("13800000000"))
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", old.KeyID(ciphertext))
+	// 随机 nonce, 相同明文密文不同
+	other, _ := old.Encrypt([]byte("13800000000"))
+	assert.NotEqual(t, ciphertext, other)
+
+	// 轮换后旧密文仍可解密
+	ring := newTestKeyRing(t, "k2")
+	plaintext, err := ring.Decrypt(ciphertext)
+	assert.Nil(t, err)
+	assert.Equal(t, "13800000000", string(plaintext))
+	rotated, _ := ring.Encrypt(plaintext)
+	assert.Equal(t, "k2", ring.KeyID(rotated))
+
+	_, err = ring.Decrypt("k9:" + strings.Split(ciphertext, ":")[1])
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+	_, err = ring.Decrypt(ciphertext[:len(ciphertext)-4] + "AAAA")
+	assert.True(t, errors.Is(err, ErrInvalidCiphertext))
+	_, err = ring.Decrypt("plain")
+	assert.True(t, errors.Is(err, ErrInvalidCiphertext))
+
+	a, _ := ring.BlindIndex("13800000000")
+	b, _ := old.BlindIndex("13800000000")
+	assert.Equal(t, a, b)
+	noBlind, _ := NewKeyRing(KeyRingConfig{Primary: "k1", Keys: map[string]string{"k1": testKey('a')}})
+	_, err = noBlind.BlindIndex("13800000000")
+	assert.True(t, errors.Is(err, ErrNoBlindIndexKey))
+}
+
+func TestEncryptedSerializer(t *testing.T) {
+	ring := newTestKeyRing(t, "k1")
+	db := newEncryptedDB(t, ring)
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+
+	card := "310000199001011234"
+	user := &secretUser{Name: "brick", Phone: "13800000000", IDCard: &card, Address: &secretAddress{City: "shanghai"}}
+	assert.Nil(t, repo.Create(user))
+	assert.Nil(t, repo.Create(&secretUser{Name: "tom", Phone: "13900000000"}))
+
+	// 数据库中为密文
+	raw := make(map[string]interface{})
+	assert.Nil(t, db.Table("secret_users").Where("id = ?", user.ID).Take(&raw).Error)
+	assert.True(t, strings.HasPrefix(raw["phone"].(string), "k1:"))
+	assert.NotContains(t, raw["phone"], "13800000000")
+	assert.NotContains(t, raw["address"], "shanghai")
+	index, _ := ring.BlindIndex("13800000000")
+	assert.Equal(t, index, raw["phone_index"])
+	assert.Equal(t, index, user.PhoneIndex)
+
+	found := &secretUser{}
+	assert.Nil(t, repo.GetOneByID(found, "1"))
+	assert.Equal(t, "13800000000", found.Phone)
+	assert.Equal(t, card, *found.IDCard)
+	assert.Equal(t, "shanghai", found.Address.City)
+
+	// nil 指针保存为 NULL
+	tom := &secretUser{}
+	assert.Nil(t, repo.GetOneByID(tom, "2"))
+	assert.Nil(t, tom.IDCard)
+	assert.Nil(t, tom.Address)
+
+	// 通过盲索引等值查询
+	users := make([]*secretUser, 0)
+	assert.Nil(t, repo.FindWhere(&users, map[string]interface{}{"phone": "13900000000"}))
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "tom", users[0].Name)
+	users = make([]*secretUser, 0)
+	assert.Nil(t, repo.FindWhere(&users, map[string]interface{}{"phone in": []string{"13800000000", "13900000000"}}))
+	assert.Equal(t, 2, len(users))
+	err := repo.FindWhere(&users, map[string]interface{}{"phone like": "138%"})
+	assert.True(t, errors.Is(err, ErrOperatorNotAllowed))
+	err = repo.FindWhere(&users, map[string]interface{}{"id_card": card})
+	assert.True(t, errors.Is(err, ErrOperatorNotAllowed))
+
+	// 按字段查询同样改写为盲索引
+	users = make([]*secretUser, 0)
+	assert.Nil(t, repo.GetByField(&users, "phone", "13900000000"))
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "tom", users[0].Name)
+	one := &secretUser{}
+	assert.Nil(t, repo.GetOneByField(one, "phone", "13800000000"))
+	assert.Equal(t, "brick", one.Name)
+	one = &secretUser{}
+	assert.Nil(t, repo.GetOneByFields(one, map[string]interface{}{"name": "tom", "phone": "13900000000"}))
+	assert.Equal(t, "tom", one.Name)
+	err = repo.GetByField(&users, "id_card", card)
+	assert.True(t, errors.Is(err, ErrOperatorNotAllowed))
+	assert.Nil(t, repo.UpdateWhere(&secretUser{}, map[string]interface{}{"phone": "13900000000"}, map[string]interface{}{"name": "jerry"}))
+	assert.Nil(t, repo.GetOneByID(tom, "2"))
+	assert.Equal(t, "jerry", tom.Name)
+
+	// map 更新同样加密并更新盲索引, 模型中保留明文
+	assert.Nil(t, db.Model(found).Update("phone", "13700000000").Error)
+	assert.Equal(t, "13700000000", found.Phone)
+	users = make([]*secretUser, 0)
+	assert.Nil(t, repo.FindWhere(&users, map[string]interface{}{"phone": "13700000000"}))
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "brick", users[0].Name)
+
+	// 结构体保存
+	found.Phone = "13600000000"
+	assert.Nil(t, repo.Save(found))
+	assert.Equal(t, 1, int(repo.FindWhereCount(&[]secretUser{}, map[string]interface{}{"phone": "13600000000"})))
+
+	// 调用方的 map 不被修改, 重复使用不会二次加密
+	updates := map[string]interface{}{"phone": "13500000000"}
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, db.Model(&secretUser{ID: tom.ID}).Updates(updates).Error)
+		assert.Equal(t, map[string]interface{}{"phone": "13500000000"}, updates)
+	}
+	var phone string
+	assert.Nil(t, db.Table("secret_users").Select("phone").Where("id = ?", tom.ID).Scan(&phone).Error)
+	plaintext, err := ring.Decrypt(phone)
+	assert.Nil(t, err)
+	assert.Equal(t, "13500000000", string(plaintext))
+	users = make([]*secretUser, 0)
+	assert.Nil(t, repo.FindWhere(&users, map[string]interface{}{"phone": "13500000000"}))
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, tom.ID, users[0].ID)
+}
+
+func TestEncryptedSerializerRotation(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "rotation.db")
+	open := func(ring *KeyRing) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+		assert.Nil(t, err)
+		assert.Nil(t, db.Use(NewEncryption(ring)))
+		assert.Nil(t, db.AutoMigrate(&secretUser{}))
+		return db
+	}
+	db := open(newTestKeyRing(t, "k1"))
+	assert.Nil(t, db.Create(&secretUser{Name: "brick", Phone: "13800000000"}).Error)
+
+	// 切换主密钥后可以读取旧数据, 保存时使用新密钥
+	ring := newTestKeyRing(t, "k2")
+	db = open(ring)
+	user := &secretUser{}
+	assert.Nil(t, db.First(user).Error)
+	assert.Equal(t, "13800000000", user.Phone)
+	var phone string
+	assert.Nil(t, db.Table("secret_users").Select("phone").Where("id = ?", user.ID).Scan(&phone).Error)
+	assert.Equal(t, "k1", ring.KeyID(phone))
+
+	assert.Nil(t, db.Save(user).Error)
+	assert.Nil(t, db.Table("secret_users").Select("phone").Where("id = ?", user.ID).Scan(&phone).Error)
+	assert.Equal(t, "k2", ring.KeyID(phone))
+}
+
+func TestEncryptionKeyRings(t *testing.T) {
+	ring1 := newTestKeyRing(t, "k1")
+	ring2, err := NewKeyRing(KeyRingConfig{
+		Primary:       "k1",
+		Keys:          map[string]string{"k1": testKey('d')},
+		BlindIndexKey: testKey('e'),
+	})
+	assert.Nil(t, err)
+
+	// db1 的模型在 db2 注册之后才解析, 仍然使用自己的密钥环
+	db1, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	assert.Nil(t, err)
+	assert.Nil(t, db1.Use(NewEncryption(ring1)))
+	db2 := newEncryptedDB(t, ring2)
+	assert.Nil(t, db1.AutoMigrate(&secretUser{}))
+
+	for _, v := range []struct {
+		db    *gorm.DB
+		ring  *KeyRing
+		other *KeyRing
+	}{{db1, ring1, ring2}, {db2, ring2, ring1}} {
+		assert.Nil(t, v.db.Create(&secretUser{Name: "brick", Phone: "13800000000"}).Error)
+		var phone, index string
+		assert.Nil(t, v.db.Table("secret_users").Select("phone").Scan(&phone).Error)
+		plaintext, err := v.ring.Decrypt(phone)
+		assert.Nil(t, err)
+		assert.Equal(t, "13800000000", string(plaintext))
+		_, err = v.other.Decrypt(phone)
+		assert.True(t, errors.Is(err, ErrInvalidCiphertext))
+		assert.Nil(t, v.db.Table("secret_users").Select("phone_index").Scan(&index).Error)
+		expected, _ := v.ring.BlindIndex("13800000000")
+		assert.Equal(t, expected, index)
+
+		user := &secretUser{}
+		assert.Nil(t, v.db.First(user).Error)
+		assert.Equal(t, "13800000000", user.Phone)
+	}
+
+	// 未使用插件的 db 无法读写加密字段
+	plain, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	assert.Nil(t, err)
+	assert.Nil(t, plain.AutoMigrate(&secretUser{}))
+	err = plain.Create(&secretUser{Name: "brick", Phone: "13800000000"}).Error
+	assert.True(t, errors.Is(err, ErrNoKeyRing))
+}
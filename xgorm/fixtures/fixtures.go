@@ -0,0 +1,363 @@
+// Package fixtures 测试数据加载, 每个文件对应一张表, 文件名即表名
+//
+//	# testdata/fixtures/users.yml
+//	brick:
+//	  name: brick
+//	  created_at: '{{ now "-24h" }}'
+//
+//	# testdata/fixtures/orders.yml
+//	first:
+//	  user_id: '{{ ref "users.brick" }}'
+//	  no: 'NO{{ seq }}'
+//
+//	loader, err := fixtures.New(db, os.DirFS("testdata/fixtures"))
+//	err = loader.Load()
+package fixtures
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/falcolee/xutils/xjson"
+	"github.com/falcolee/xutils/xtype"
+)
+
+var (
+	// ErrUnknownReference referenced fixture not found
+	ErrUnknownReference = errors.New("fixtures: unknown reference")
+	// ErrCircularDependency tables reference each other
+	ErrCircularDependency = errors.New("fixtures: circular dependency")
+)
+
+// refPattern 模板中引用的表, 用于计算加载顺序
+var refPattern = regexp.MustCompile(`ref\s+"([^".]+)\.`)
+
+// tokenPattern 模板函数返回值占位符
+var tokenPattern = regexp.MustCompile("\x00fixture:(\\d+)\x00")
+
+// row 一条数据
+type row struct {
+	label  string
+	values map[string]interface{}
+}
+
+// table 一张表的数据
+type table struct {
+	name string
+	rows []*row
+	deps map[string]struct{}
+}
+
+// Loader ...
+type Loader struct {
+	db     *gorm.DB
+	option *Option
+	tables []*table               // 按依赖顺序
+	ids    map[string]interface{} // table.label => 主键
+}
+
+// New 从 fsys 读取 .yml, .yaml, .json 文件
+func New(db *gorm.DB, fsys fs.FS, options ...OptionFn) (*Loader, error) {
+	option := newOption(options...)
+	entries, err := fs.ReadDir(fsys, option.Dir)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]*table, 0)
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(option.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseTable(strings.TrimSuffix(entry.Name(), ext), content)
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: %s: %w", entry.Name(), err)
+		}
+		tables = append(tables, t)
+	}
+	l := &Loader{
+		db:     db,
+		option: option,
+		ids:    make(map[string]interface{}),
+	}
+	if l.tables, err = sortTables(tables); err != nil {
+		return nil, err
+	}
+	if err := l.assignIDs(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Tables 按加载顺序返回表名
+func (l *Loader) Tables() []string {
+	res := make([]string, 0, len(l.tables))
+	for _, t := range l.tables {
+		res = append(res, t.name)
+	}
+	return res
+}
+
+// ID 返回 fixture 的主键, 如 users.brick
+func (l *Loader) ID(ref string) (interface{}, bool) {
+	id, ok := l.ids[ref]
+	return id, ok
+}
+
+// Load 清空 fixture 涉及的表并重新写入数据, 在同一个事务中执行
+func (l *Loader) Load() error {
+	return l.db.Transaction(func(tx *gorm.DB) error {
+		// 按依赖的逆序清空, 避免外键约束
+		for i := len(l.tables) - 1; i >= 0; i-- {
+			if err := tx.Exec("DELETE FROM ?", clause.Table{Name: l.tables[i].name}).Error; err != nil {
+				return fmt.Errorf("fixtures: truncate %s: %w", l.tables[i].name, err)
+			}
+		}
+		e := &evaluator{loader: l, seqs: make(map[string]int64)}
+		for _, t := range l.tables {
+			for _, r := range t.rows {
+				values := make(map[string]interface{}, len(r.values)+1)
+				for k, v := range r.values {
+					vv, err := e.value(t.name, v)
+					if err != nil {
+						return fmt.Errorf("fixtures: %s.%s.%s: %w", t.name, r.label, k, err)
+					}
+					values[k] = vv
+				}
+				values[l.option.PrimaryKey] = l.ids[t.name+"."+r.label]
+				if err := tx.Table(t.name).Create(values).Error; err != nil {
+					return fmt.Errorf("fixtures: insert %s.%s: %w", t.name, r.label, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ----------------------------------------------------------------
+
+// parseTable 解析 fixture 文件, json 是 yaml 的子集, 统一按 yaml 解析以保留顺序
+func parseTable(name string, content []byte) (*table, error) {
+	t := &table{
+		name: name,
+		rows: make([]*row, 0),
+		deps: make(map[string]struct{}),
+	}
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return t, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("fixtures must be a mapping of label to row")
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		values := make(map[string]interface{})
+		if err := root.Content[i+1].Decode(&values); err != nil {
+			return nil, fmt.Errorf("%s: %w", root.Content[i].Value, err)
+		}
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				for _, match := range refPattern.FindAllStringSubmatch(s, -1) {
+					if match[1] != name {
+						t.deps[match[1]] = struct{}{}
+					}
+				}
+			}
+		}
+		t.rows = append(t.rows, &row{label: root.Content[i].Value, values: values})
+	}
+	return t, nil
+}
+
+// sortTables 按引用关系排序, 被引用的表在前
+func sortTables(tables []*table) ([]*table, error) {
+	byName := make(map[string]*table, len(tables))
+	for _, t := range tables {
+		byName[t.name] = t
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].name < tables[j].name
+	})
+	res := make([]*table, 0, len(tables))
+	state := make(map[string]int) // 1: 访问中 2: 已完成
+	var visit func(t *table) error
+	visit = func(t *table) error {
+		switch state[t.name] {
+		case 1:
+			return fmt.Errorf("%w: %s", ErrCircularDependency, t.name)
+		case 2:
+			return nil
+		}
+		state[t.name] = 1
+		deps := make([]string, 0, len(t.deps))
+		for dep := range t.deps {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			d, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("%w: table %s referenced by %s", ErrUnknownReference, dep, t.name)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[t.name] = 2
+		res = append(res, t)
+		return nil
+	}
+	for _, t := range tables {
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// assignIDs 分配主键, 未填写主键的按顺序在最大主键之后递增
+func (l *Loader) assignIDs() error {
+	for _, t := range l.tables {
+		var next int64
+		for _, r := range t.rows {
+			if id, ok := r.values[l.option.PrimaryKey]; ok {
+				if s, ok := id.(string); ok && strings.Contains(s, "{{") {
+					return fmt.Errorf("fixtures: %s.%s: primary key must not be a template", t.name, r.label)
+				}
+				if xtype.IsNumeric(id) && xtype.ToInt64(id) > next {
+					next = xtype.ToInt64(id)
+				}
+			}
+		}
+		for _, r := range t.rows {
+			id, ok := r.values[l.option.PrimaryKey]
+			if !ok {
+				next++
+				id = next
+			}
+			l.ids[t.name+"."+r.label] = id
+		}
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------
+
+// evaluator 模板求值, 每次 Load 重新计算
+type evaluator struct {
+	loader *Loader
+	seqs   map[string]int64
+}
+
+// value 计算字段值, 嵌套结构编码为 json
+func (e *evaluator) value(tableName string, v interface{}) (interface{}, error) {
+	switch vv := v.(type) {
+	case string:
+		if !strings.Contains(vv, "{{") {
+			return vv, nil
+		}
+		return e.render(tableName, vv)
+	case map[string]interface{}, []interface{}:
+		return xjson.Encode(vv), nil
+	default:
+		return v, nil
+	}
+}
+
+// render 渲染模板, 整个值只有一个函数调用时保留函数返回值的类型
+func (e *evaluator) render(tableName, s string) (interface{}, error) {
+	values := make([]interface{}, 0)
+	token := func(v interface{}) string {
+		values = append(values, v)
+		return fmt.Sprintf("\x00fixture:%d\x00", len(values)-1)
+	}
+	tpl, err := template.New(tableName).Funcs(template.FuncMap{
+		"now": func(offsets ...string) (string, error) {
+			now := e.loader.option.Now()
+			for _, offset := range offsets {
+				d, err := parseOffset(offset)
+				if err != nil {
+					return "", err
+				}
+				now = now.Add(d)
+			}
+			return token(now), nil
+		},
+		"ref": func(ref string) (string, error) {
+			id, ok := e.loader.ids[ref]
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrUnknownReference, ref)
+			}
+			return token(id), nil
+		},
+		"seq": func(names ...string) string {
+			key := tableName + ":" + strings.Join(names, ":")
+			e.seqs[key]++
+			return token(e.seqs[key])
+		},
+	}).Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, nil); err != nil {
+		return nil, err
+	}
+	out := buf.String()
+	if match := tokenPattern.FindStringSubmatch(out); match != nil && match[0] == out {
+		index, _ := strconv.Atoi(match[1])
+		return values[index], nil
+	}
+	return tokenPattern.ReplaceAllStringFunc(out, func(s string) string {
+		index, _ := strconv.Atoi(tokenPattern.FindStringSubmatch(s)[1])
+		if t, ok := values[index].(time.Time); ok {
+			return t.Format("2006-01-02 15:04:05")
+		}
+		return fmt.Sprint(values[index])
+	}), nil
+}
+
+// parseOffset 解析时间偏移, 在 time.ParseDuration 的基础上支持天 d, 如 -7d, 1d12h
+func parseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "d"); i > 0 {
+		days, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		d := time.Duration(days) * 24 * time.Hour
+		if rest := s[i+1:]; rest != "" {
+			dd, err := time.ParseDuration(rest)
+			if err != nil {
+				return 0, fmt.Errorf("invalid offset %q", s)
+			}
+			if days < 0 || strings.HasPrefix(s, "-") {
+				dd = -dd
+			}
+			d += dd
+		}
+		return d, nil
+	}
+	return time.ParseDuration(s)
+}
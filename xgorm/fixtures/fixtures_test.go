@@ -0,0 +1,139 @@
+package fixtures
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type user struct {
+	ID        int64
+	Name      string
+	Email     string
+	Profile   string
+	CreatedAt time.Time
+}
+
+type order struct {
+	ID     int64
+	UserID int64
+	No     string
+	Amount int
+}
+
+var testNow = time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	assert.Nil(t, err)
+	// 内存数据库每个连接独立, 只保留一个连接
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	assert.Nil(t, db.AutoMigrate(&user{}, &order{}))
+	return db
+}
+
+func TestLoader(t *testing.T) {
+	db := newTestDB(t)
+	loader, err := New(db, os.DirFS("testdata/fixtures"), WithNow(func() time.Time { return testNow }))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"users", "orders"}, loader.Tables())
+
+	assert.Nil(t, loader.Load())
+
+	users := make([]user, 0)
+	assert.Nil(t, db.Order("id").Find(&users).Error)
+	assert.Equal(t, 3, len(users))
+	// 未指定主键的在最大主键之后递增
+	assert.Equal(t, int64(10), users[0].ID)
+	assert.Equal(t, "admin2@example.com", users[0].Email)
+	assert.True(t, testNow.Equal(users[0].CreatedAt))
+	assert.Equal(t, int64(11), users[1].ID)
+	assert.Equal(t, "brick", users[1].Name)
+	assert.Equal(t, "brick1@example.com", users[1].Email)
+	assert.Equal(t, `{"city":"shanghai"}`, users[1].Profile)
+	assert.True(t, testNow.Add(-24*time.Hour).Equal(users[1].CreatedAt))
+	assert.Equal(t, int64(12), users[2].ID)
+	assert.True(t, testNow.Add(-36*time.Hour).Equal(users[2].CreatedAt))
+
+	orders := make([]order, 0)
+	assert.Nil(t, db.Order("id").Find(&orders).Error)
+	assert.Equal(t, 2, len(orders))
+	assert.Equal(t, int64(11), orders[0].UserID)
+	assert.Equal(t, "NO-1-11", orders[0].No)
+	assert.Equal(t, int64(10), orders[1].UserID)
+	assert.Equal(t, "NO-2-10", orders[1].No)
+
+	id, ok := loader.ID("users.admin")
+	assert.True(t, ok)
+	assert.Equal(t, 10, id)
+
+	// 修改后重新加载
+	assert.Nil(t, db.Create(&user{ID: 100, Name: "dirty"}).Error)
+	assert.Nil(t, db.Model(&order{}).Where("id = ?", 1).Update("amount", 0).Error)
+	assert.Nil(t, loader.Load())
+	var total int64
+	assert.Nil(t, db.Model(&user{}).Count(&total).Error)
+	assert.Equal(t, int64(3), total)
+	first := order{}
+	assert.Nil(t, db.First(&first, 1).Error)
+	assert.Equal(t, 100, first.Amount)
+	assert.Equal(t, "NO-1-11", first.No)
+}
+
+func TestLoaderErrors(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := New(db, fstest.MapFS{
+		"orders.yml": {Data: []byte(`a: {user_id: '{{ ref "users.a" }}'}`)},
+	})
+	assert.True(t, errors.Is(err, ErrUnknownReference))
+
+	_, err = New(db, fstest.MapFS{
+		"users.yml":  {Data: []byte(`a: {name: '{{ ref "orders.a" }}'}`)},
+		"orders.yml": {Data: []byte(`a: {user_id: '{{ ref "users.a" }}'}`)},
+	})
+	assert.True(t, errors.Is(err, ErrCircularDependency))
+
+	_, err = New(db, fstest.MapFS{
+		"users.yml": {Data: []byte(`[1, 2]`)},
+	})
+	assert.NotNil(t, err)
+
+	loader, err := New(db, fstest.MapFS{
+		"users.yml": {Data: []byte(`a: {name: '{{ ref "users.b" }}'}`)},
+	})
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(loader.Load(), ErrUnknownReference))
+
+	loader, err = New(db, fstest.MapFS{
+		"users.yml": {Data: []byte(`a: {created_at: '{{ now "yesterday" }}'}`)},
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, loader.Load())
+}
+
+func TestParseOffset(t *testing.T) {
+	for s, d := range map[string]time.Duration{
+		"1h":     time.Hour,
+		"-30m":   -30 * time.Minute,
+		"7d":     7 * 24 * time.Hour,
+		"-7d":    -7 * 24 * time.Hour,
+		"1d12h":  36 * time.Hour,
+		"-1d12h": -36 * time.Hour,
+	} {
+		v, err := parseOffset(s)
+		assert.Nil(t, err)
+		assert.Equal(t, d, v, s)
+	}
+	_, err := parseOffset("xd")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,57 @@
+package fixtures
+
+import (
+	"time"
+)
+
+// Option ...
+type Option struct {
+	Dir        string           // fixture 文件目录
+	PrimaryKey string           // 主键字段, 未填写时自动分配
+	Now        func() time.Time // now 模板函数的时间来源
+}
+
+// ----------------------------------------------------------------
+
+// OptionFn ...
+type OptionFn func(*Option)
+
+// WithDir fixture 文件所在目录, 默认为 fs 根目录
+func WithDir(v string) OptionFn {
+	return func(o *Option) {
+		o.Dir = v
+	}
+}
+
+// WithPrimaryKey 主键字段, 默认为 id
+func WithPrimaryKey(v string) OptionFn {
+	return func(o *Option) {
+		if v != "" {
+			o.PrimaryKey = v
+		}
+	}
+}
+
+// WithNow 固定 now 模板函数的时间
+func WithNow(v func() time.Time) OptionFn {
+	return func(o *Option) {
+		if v != nil {
+			o.Now = v
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+
+// newOption ...
+func newOption(options ...OptionFn) *Option {
+	o := &Option{
+		Dir:        ".",
+		PrimaryKey: "id",
+		Now:        time.Now,
+	}
+	for _, fn := range options {
+		fn(o)
+	}
+	return o
+}
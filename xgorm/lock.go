@@ -0,0 +1,222 @@
+/*
+ * @Date: 2026-10-19 13:41:05
+ * @LastEditTime: 2026-10-19 13:41:05
+ * @Description:
+ */
+package xgorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	gormrepository "github.com/aklinkert/go-gorm-repository"
+	"github.com/falcolee/xutils/xtype"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// VersionTag 乐观锁版本字段标签, 如 `xgorm:"version"`
+const VersionTag = "version"
+
+// ErrStaleObject 乐观锁版本冲突, 数据已被其他请求修改
+var ErrStaleObject = errors.New("xgorm: stale object")
+
+// versionLock 乐观锁版本字段
+type versionLock struct {
+	db      *gorm.DB
+	field   *schema.Field   // 版本字段
+	primary []*schema.Field // 主键字段
+	value   reflect.Value   // 结构体值
+}
+
+// hasVersionTag ...
+func hasVersionTag(field *schema.Field) bool {
+	for _, v := range strings.Split(field.Tag.Get("xgorm"), ";") {
+		if strings.TrimSpace(v) == VersionTag {
+			return true
+		}
+	}
+	return false
+}
+
+// newVersionLock target 为结构体指针且包含版本字段时返回版本锁, 否则返回 nil
+func newVersionLock(db *gorm.DB, target interface{}) *versionLock {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(target); err != nil {
+		return nil
+	}
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName != "" && hasVersionTag(field) {
+			return &versionLock{
+				db:      db,
+				field:   field,
+				primary: stmt.Schema.PrimaryFields,
+				value:   value.Elem(),
+			}
+		}
+	}
+	return nil
+}
+
+// version 当前版本号
+func (l *versionLock) version() int64 {
+	v, _ := l.field.ValueOf(l.db.Statement.Context, l.value)
+	return xtype.ToInt64(v)
+}
+
+// set 设置版本号
+func (l *versionLock) set(version int64) {
+	_ = l.field.Set(l.db.Statement.Context, l.value, version)
+}
+
+// isNew 主键为空, 即未入库的数据
+func (l *versionLock) isNew() bool {
+	for _, field := range l.primary {
+		if _, zero := field.ValueOf(l.db.Statement.Context, l.value); !zero {
+			return false
+		}
+	}
+	return true
+}
+
+// condition 版本条件
+func (l *versionLock) condition(version int64) clause.Eq {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: l.field.DBName}, Value: version}
+}
+
+// primaryConditions 主键条件
+func (l *versionLock) primaryConditions() []clause.Expression {
+	res := make([]clause.Expression, 0, len(l.primary))
+	for _, field := range l.primary {
+		v, _ := field.ValueOf(l.db.Statement.Context, l.value)
+		res = append(res, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: v})
+	}
+	return res
+}
+
+// increment 在更新内容中追加版本号自增
+// map 直接追加, 结构体按 gorm Updates 的规则取非零值字段
+func (l *versionLock) increment(updates interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	switch v := updates.(type) {
+	case map[string]interface{}:
+		for k, vv := range v {
+			res[k] = vv
+		}
+	default:
+		stmt := &gorm.Statement{DB: l.db}
+		if err := stmt.Parse(updates); err != nil {
+			return nil, fmt.Errorf("xgorm: unsupported updates %T: %w", updates, err)
+		}
+		rv := reflect.Indirect(reflect.ValueOf(updates))
+		if rv.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("xgorm: unsupported updates %T", updates)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.PrimaryKey || !field.Updatable {
+				continue
+			}
+			if vv, zero := field.ValueOf(l.db.Statement.Context, rv); !zero {
+				res[field.DBName] = vv
+			}
+		}
+	}
+	delete(res, l.field.Name)
+	res[l.field.DBName] = gorm.Expr("? + 1", clause.Column{Name: l.field.DBName})
+	return res, nil
+}
+
+// ----------------------------------------------------------------
+
+// initVersion 新建数据时版本号为 0 的设置为 1
+func initVersion(db *gorm.DB, target interface{}) {
+	value := reflect.Indirect(reflect.ValueOf(target))
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		for i := 0; i < value.Len(); i++ {
+			item := value.Index(i)
+			if item.Kind() != reflect.Ptr && item.CanAddr() {
+				item = item.Addr()
+			}
+			initVersion(db, item.Interface())
+		}
+		return
+	}
+	if lock := newVersionLock(db, target); lock != nil && lock.version() == 0 {
+		lock.set(1)
+	}
+}
+
+// saveVersioned 带乐观锁的 Save, 版本号不一致时返回 ErrStaleObject, 数据不存在时返回 ErrNotFound
+func (r *gormRepository) saveVersioned(db *gorm.DB, target interface{}) error {
+	lock := newVersionLock(db, target)
+	if lock == nil {
+		return r.HandleError(db.Save(target))
+	}
+	if lock.isNew() {
+		if lock.version() == 0 {
+			lock.set(1)
+		}
+		return r.HandleError(db.Save(target))
+	}
+
+	current := lock.version()
+	lock.set(current + 1)
+	res := db.Model(target).
+		Where(lock.condition(current)).
+		Select("*").
+		Updates(target)
+	if err := r.HandleError(res); err != nil {
+		lock.set(current)
+		return err
+	}
+	if res.RowsAffected == 0 {
+		lock.set(current)
+		return r.staleOrNotFound(db.Session(&gorm.Session{NewDB: true}).Model(target).Where(clause.And(lock.primaryConditions()...)))
+	}
+	return nil
+}
+
+// staleOrNotFound 更新 0 行时, 数据存在则为版本冲突, 否则为数据不存在
+func (r *gormRepository) staleOrNotFound(query *gorm.DB) error {
+	var total int64
+	if err := r.HandleError(query.Count(&total)); err != nil {
+		return err
+	}
+	if total > 0 {
+		return ErrStaleObject
+	}
+	return gormrepository.ErrNotFound
+}
+
+// SaveWithRetry 乐观锁更新, 版本冲突时重新加载数据, 再次执行 mutate 后保存, 最多尝试 attempts 次
+func (r *gormRepository) SaveWithRetry(target interface{}, attempts int, mutate func() error) error {
+	r.logger.Debugf("Executing SaveWithRetry on %T", target)
+	if attempts <= 0 {
+		attempts = 1
+	}
+	lock := newVersionLock(r.db, target)
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			// 重新加载最新数据
+			res := r.db.Where(clause.And(lock.primaryConditions()...)).First(target)
+			if err := r.HandleOneError(res); err != nil {
+				return err
+			}
+		}
+		if err = mutate(); err != nil {
+			return err
+		}
+		if err = r.saveVersioned(r.db, target); !errors.Is(err, ErrStaleObject) || lock == nil {
+			return err
+		}
+	}
+	return err
+}
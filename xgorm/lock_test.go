@@ -0,0 +1,119 @@
+package xgorm
+
+import (
+	"errors"
+	"testing"
+
+	gormrepository "github.com/aklinkert/go-gorm-repository"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type lockAccount struct {
+	ID      uint `gorm:"primarykey"`
+	Name    string
+	Balance int
+	Version int64 `xgorm:"version"`
+}
+
+func TestSaveVersioned(t *testing.T) {
+	db := newTestDB(t, &lockAccount{})
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+
+	account := &lockAccount{Name: "brick", Balance: 10}
+	assert.Nil(t, repo.Create(account))
+	assert.Equal(t, int64(1), account.Version)
+
+	// 两个请求读取同一版本
+	a, b := &lockAccount{}, &lockAccount{}
+	assert.Nil(t, repo.GetOneByID(a, "1"))
+	assert.Nil(t, repo.GetOneByID(b, "1"))
+
+	a.Balance = 20
+	assert.Nil(t, repo.Save(a))
+	assert.Equal(t, int64(2), a.Version)
+
+	b.Balance = 30
+	err := repo.Save(b)
+	assert.True(t, errors.Is(err, ErrStaleObject))
+	assert.Equal(t, int64(1), b.Version)
+
+	latest := &lockAccount{}
+	assert.Nil(t, repo.GetOneByID(latest, "1"))
+	assert.Equal(t, 20, latest.Balance)
+	assert.Equal(t, int64(2), latest.Version)
+
+	// 数据不存在不是版本冲突
+	missing := &lockAccount{ID: 99, Version: 1}
+	err = repo.Save(missing)
+	assert.True(t, errors.Is(err, gormrepository.ErrNotFound))
+	assert.False(t, errors.Is(err, ErrStaleObject))
+
+	// 新数据通过 Save 创建
+	created := &lockAccount{Name: "new"}
+	assert.Nil(t, repo.Save(created))
+	assert.Equal(t, int64(1), created.Version)
+	assert.NotZero(t, created.ID)
+}
+
+func TestUpdateWhereVersioned(t *testing.T) {
+	db := newTestDB(t, &lockAccount{})
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+	account := &lockAccount{Name: "brick", Balance: 10}
+	assert.Nil(t, repo.Create(account))
+
+	stale := *account
+	assert.Nil(t, repo.UpdateWhere(account, map[string]interface{}{"name": "brick"}, map[string]interface{}{"balance": 11}))
+	assert.Equal(t, int64(2), account.Version)
+
+	err := repo.UpdateWhere(&stale, map[string]interface{}{"name": "brick"}, lockAccount{Balance: 12})
+	assert.True(t, errors.Is(err, ErrStaleObject))
+
+	assert.Nil(t, repo.UpdateWhere(account, map[string]interface{}{"name": "brick"}, lockAccount{Balance: 12}))
+	assert.Equal(t, int64(3), account.Version)
+
+	// 未指定版本号时只自增
+	assert.Nil(t, repo.UpdateWhere(&lockAccount{}, map[string]interface{}{"name": "brick"}, map[string]interface{}{"balance": 13}))
+	latest := &lockAccount{}
+	assert.Nil(t, repo.GetOneByID(latest, "1"))
+	assert.Equal(t, 13, latest.Balance)
+	assert.Equal(t, int64(4), latest.Version)
+
+	err = repo.UpdateWhere(&lockAccount{Version: 4}, map[string]interface{}{"name": "nobody"}, map[string]interface{}{"balance": 1})
+	assert.True(t, errors.Is(err, gormrepository.ErrNotFound))
+}
+
+func TestSaveWithRetry(t *testing.T) {
+	db := newTestDB(t, &lockAccount{})
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+	assert.Nil(t, repo.Create(&lockAccount{Name: "brick", Balance: 10}))
+
+	account := &lockAccount{}
+	assert.Nil(t, repo.GetOneByID(account, "1"))
+
+	calls := 0
+	err := repo.SaveWithRetry(account, 3, func() error {
+		calls++
+		if calls == 1 {
+			// 模拟并发修改
+			assert.Nil(t, db.Model(&lockAccount{}).Where("id = ?", 1).
+				Updates(map[string]interface{}{"balance": 100, "version": 2}).Error)
+		}
+		account.Balance += 5
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 105, account.Balance)
+	assert.Equal(t, int64(3), account.Version)
+
+	// 持续冲突时返回 ErrStaleObject
+	err = repo.SaveWithRetry(account, 2, func() error {
+		assert.Nil(t, db.Exec("UPDATE lock_accounts SET version = version + 1").Error)
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrStaleObject))
+
+	errMutate := errors.New("mutate")
+	assert.Equal(t, errMutate, repo.SaveWithRetry(account, 2, func() error { return errMutate }))
+}
@@ -0,0 +1,366 @@
+/*
+ * @Date: 2026-10-19 10:02:11
+ * @LastEditTime: 2026-10-19 10:02:11
+ * @Description:
+ */
+package xgorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// RedactedValue 敏感字段参数在日志中的替换值
+const RedactedValue = "******"
+
+// Logger 基于 logrus 的 gorm logger.Interface 实现
+type Logger struct {
+	logger           *logrus.Logger
+	level            gormlogger.LogLevel
+	slowThreshold    time.Duration
+	ignoreNotFound   bool
+	traceIDKeys      []interface{}
+	sensitiveColumns map[string]struct{}
+}
+
+// LoggerOption ...
+type LoggerOption func(*Logger)
+
+// WithLogLevel gorm 日志级别
+func WithLogLevel(level gormlogger.LogLevel) LoggerOption {
+	return func(l *Logger) {
+		l.level = level
+	}
+}
+
+// WithSlowThreshold 慢查询阈值, 0 表示不检测慢查询
+func WithSlowThreshold(v time.Duration) LoggerOption {
+	return func(l *Logger) {
+		l.slowThreshold = v
+	}
+}
+
+// WithIgnoreRecordNotFound 忽略 ErrRecordNotFound 错误日志
+func WithIgnoreRecordNotFound() LoggerOption {
+	return func(l *Logger) {
+		l.ignoreNotFound = true
+	}
+}
+
+// WithTraceIDKeys 从 context 中读取 trace id 的 key, 按顺序取第一个非空值
+func WithTraceIDKeys(keys ...interface{}) LoggerOption {
+	return func(l *Logger) {
+		if len(keys) > 0 {
+			l.traceIDKeys = keys
+		}
+	}
+}
+
+// WithSensitiveColumns 敏感字段, 日志中对应的参数会被替换为 RedactedValue
+func WithSensitiveColumns(columns ...string) LoggerOption {
+	return func(l *Logger) {
+		for _, column := range columns {
+			l.sensitiveColumns[strings.ToLower(column)] = struct{}{}
+		}
+	}
+}
+
+// NewLogger ...
+func NewLogger(logger *logrus.Logger, opts ...LoggerOption) *Logger {
+	l := &Logger{
+		logger:           logger,
+		level:            gormlogger.Warn,
+		slowThreshold:    200 * time.Millisecond,
+		traceIDKeys:      []interface{}{"trace_id"},
+		sensitiveColumns: make(map[string]struct{}),
+	}
+	for _, fn := range opts {
+		fn(l)
+	}
+	return l
+}
+
+// LogMode ...
+func (l *Logger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	newLogger := *l
+	newLogger.level = level
+	return &newLogger
+}
+
+// Info ...
+func (l *Logger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.entry(ctx).Infof(msg, data...)
+	}
+}
+
+// Warn ...
+func (l *Logger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.entry(ctx).Warnf(msg, data...)
+	}
+}
+
+// Error ...
+func (l *Logger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.entry(ctx).Errorf(msg, data...)
+	}
+}
+
+// Trace 输出 sql 日志, 包含 sql, rows, elapsed, caller, trace_id 字段
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	fields := func() *logrus.Entry {
+		sql, rows := fc()
+		entry := l.entry(ctx).WithFields(logrus.Fields{
+			"sql":     sql,
+			"elapsed": elapsed.String(),
+		})
+		if rows != -1 {
+			entry = entry.WithField("rows", rows)
+		}
+		return entry
+	}
+	switch {
+	case err != nil && l.level >= gormlogger.Error && (!errors.Is(err, gorm.ErrRecordNotFound) || !l.ignoreNotFound):
+		fields().WithError(err).Error("sql error")
+	case l.slowThreshold != 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		fields().Warnf("slow sql >= %v", l.slowThreshold)
+	case l.level == gormlogger.Info:
+		fields().Info("sql")
+	}
+}
+
+// ParamsFilter 替换敏感字段参数, 仅作用于日志输出
+func (l *Logger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if len(l.sensitiveColumns) == 0 || len(params) == 0 {
+		return sql, params
+	}
+	columns := placeholderColumns(sql)
+	res := make([]interface{}, len(params))
+	copy(res, params)
+	for i := range res {
+		if i >= len(columns) {
+			break
+		}
+		if _, ok := l.sensitiveColumns[columns[i]]; ok {
+			res[i] = RedactedValue
+		}
+	}
+	return sql, res
+}
+
+// entry 公共字段
+func (l *Logger) entry(ctx context.Context) *logrus.Entry {
+	entry := l.logger.WithField("caller", utils.FileWithLineNum())
+	if ctx == nil {
+		return entry
+	}
+	for _, key := range l.traceIDKeys {
+		if v := ctx.Value(key); v != nil {
+			return entry.WithField("trace_id", fmt.Sprint(v))
+		}
+	}
+	return entry
+}
+
+// ----------------------------------------------------------------
+
+// placeholderSkipWords 向前查找占位符所属字段时跳过的关键字
+var placeholderSkipWords = map[string]struct{}{
+	"in": {}, "not": {}, "like": {}, "ilike": {}, "between": {}, "and": {}, "is": {},
+}
+
+// placeholderColumns 按顺序返回 sql 中每个 ? 占位符对应的字段名(小写), 无法识别的为空字符串
+//
+//	INSERT INTO `t` (`a`,`b`) VALUES (?,?),(?,?)  => [a b a b]
+//	UPDATE `t` SET `a`=?,`b`=? WHERE `t`.`id` = ? => [a b id]
+func placeholderColumns(sql string) []string {
+	tokens := tokenizeSQL(sql)
+	res := make([]string, 0)
+
+	var (
+		insertColumns []string // INSERT 字段列表
+		inValues      bool     // 是否处于 VALUES 区域
+		valueIndex    int      // VALUES 括号内的位置
+		depth         int      // 括号深度
+	)
+	for i, token := range tokens {
+		word := strings.ToLower(token.text)
+		switch {
+		case token.kind == tokenWord && word == "values" && len(insertColumns) == 0:
+			insertColumns = lastColumnList(tokens[:i])
+			inValues = len(insertColumns) > 0
+		case token.kind == tokenWord && inValues && (word == "on" || word == "returning"):
+			inValues = false
+		case token.text == "(":
+			depth++
+			if inValues && depth == 1 {
+				valueIndex = 0
+			}
+		case token.text == ")":
+			depth--
+		case token.text == "," && inValues && depth == 1:
+			valueIndex++
+		case token.text == "?":
+			if inValues && depth == 1 {
+				if valueIndex < len(insertColumns) {
+					res = append(res, insertColumns[valueIndex])
+				} else {
+					res = append(res, "")
+				}
+				continue
+			}
+			res = append(res, precedingColumn(tokens[:i]))
+		}
+	}
+	return res
+}
+
+// lastColumnList 返回 VALUES 之前最后一个括号内的字段列表
+func lastColumnList(tokens []sqlToken) []string {
+	end := len(tokens) - 1
+	if end < 0 || tokens[end].text != ")" {
+		return nil
+	}
+	columns := make([]string, 0)
+	for i := end - 1; i >= 0; i-- {
+		switch tokens[i].kind {
+		case tokenIdent, tokenWord:
+			columns = append([]string{strings.ToLower(tokens[i].text)}, columns...)
+		default:
+			if tokens[i].text == "(" {
+				return columns
+			}
+		}
+	}
+	return nil
+}
+
+// precedingColumn 返回占位符前最近的字段名
+func precedingColumn(tokens []sqlToken) string {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		token := tokens[i]
+		switch token.kind {
+		case tokenIdent:
+			return strings.ToLower(token.text)
+		case tokenWord:
+			if _, ok := placeholderSkipWords[strings.ToLower(token.text)]; ok {
+				continue
+			}
+			if isSQLKeyword(token.text) {
+				return ""
+			}
+			return strings.ToLower(token.text)
+		case tokenString:
+			return ""
+		}
+	}
+	return ""
+}
+
+// sqlKeywords 可能出现在占位符之前的非字段关键字
+var sqlKeywords = map[string]struct{}{
+	"select": {}, "from": {}, "where": {}, "limit": {}, "offset": {}, "set": {}, "values": {},
+	"or": {}, "having": {}, "by": {}, "on": {}, "then": {}, "else": {}, "when": {}, "case": {},
+}
+
+// isSQLKeyword ...
+func isSQLKeyword(s string) bool {
+	_, ok := sqlKeywords[strings.ToLower(s)]
+	return ok
+}
+
+type sqlTokenKind int
+
+const (
+	tokenWord   sqlTokenKind = iota // 未加引号的单词
+	tokenIdent                      // 加引号的标识符
+	tokenString                     // 字符串常量
+	tokenSymbol                     // 符号
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// tokenizeSQL 简单的 sql 分词, 标识符中的 `t`.`col` 只保留最后一段
+func tokenizeSQL(sql string) []sqlToken {
+	tokens := make([]sqlToken, 0)
+	runes := []rune(sql)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+		case c == '`' || c == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != c {
+				j++
+			}
+			tokens = appendName(tokens, sqlToken{kind: tokenIdent, text: string(runes[i+1 : j])})
+			i = j
+		case c == '\'':
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == '\\' {
+					j++
+				} else if runes[j] == '\'' {
+					if j+1 < len(runes) && runes[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j > len(runes) {
+				j = len(runes)
+			}
+			tokens = append(tokens, sqlToken{kind: tokenString, text: string(runes[i+1 : j])})
+			i = j
+		case isWordRune(c):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			tokens = appendName(tokens, sqlToken{kind: tokenWord, text: string(runes[i:j])})
+			i = j - 1
+		default:
+			tokens = append(tokens, sqlToken{kind: tokenSymbol, text: string(c)})
+		}
+	}
+	return tokens
+}
+
+// isWordRune ...
+func isWordRune(c rune) bool {
+	return c == '_' || c == '$' || c == '@' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// appendName 追加标识符, `t`.`col` 合并为 col
+func appendName(tokens []sqlToken, token sqlToken) []sqlToken {
+	n := len(tokens)
+	if n >= 2 && tokens[n-1].kind == tokenSymbol && tokens[n-1].text == "." &&
+		(tokens[n-2].kind == tokenIdent || tokens[n-2].kind == tokenWord) {
+		if token.kind == tokenWord {
+			// `t`.col 中 col 视为标识符
+			token.kind = tokenIdent
+		}
+		tokens[n-2] = token
+		return tokens[:n-1]
+	}
+	return append(tokens, token)
+}
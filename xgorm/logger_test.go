@@ -0,0 +1,107 @@
+package xgorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type logUser struct {
+	ID    uint `gorm:"primarykey"`
+	Name  string
+	Phone string
+}
+
+func TestPlaceholderColumns(t *testing.T) {
+	assert.Equal(t, []string{"name", "phone", "name", "phone"},
+		placeholderColumns("INSERT INTO `users` (`name`,`phone`) VALUES (?,?),(?,?) RETURNING `id`"))
+	assert.Equal(t, []string{"phone", "id"},
+		placeholderColumns("UPDATE `users` SET `phone`=? WHERE `users`.`id` = ?"))
+	assert.Equal(t, []string{"id", "id", "age", "age", ""},
+		placeholderColumns(`SELECT * FROM "users" WHERE users.id IN (?,?) AND "age" BETWEEN ? AND ? LIMIT ?`))
+	assert.Equal(t, []string{"name"},
+		placeholderColumns("SELECT * FROM users WHERE note = 'a ? b' AND name like ?"))
+}
+
+func TestLoggerParamsFilter(t *testing.T) {
+	l := NewLogger(logrus.New(), WithSensitiveColumns("Phone"))
+	sql := "UPDATE `users` SET `phone`=?,`name`=? WHERE `id` = ?"
+	_, params := l.ParamsFilter(context.Background(), sql, "13800000000", "brick", 1)
+	assert.Equal(t, []interface{}{RedactedValue, "brick", 1}, params)
+
+	_, params = NewLogger(logrus.New()).ParamsFilter(context.Background(), sql, "13800000000", "brick", 1)
+	assert.Equal(t, []interface{}{"13800000000", "brick", 1}, params)
+}
+
+func TestLoggerTrace(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	l := NewLogger(logger, WithSensitiveColumns("phone"), WithSlowThreshold(time.Hour))
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: l.LogMode(gormlogger.Info)})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&logUser{}))
+
+	hook.Reset()
+	ctx := context.WithValue(context.Background(), "trace_id", "abc")
+	assert.Nil(t, db.WithContext(ctx).Create(&logUser{Name: "brick", Phone: "13800000000"}).Error)
+	entry := hook.LastEntry()
+	assert.NotNil(t, entry)
+	assert.Equal(t, logrus.InfoLevel, entry.Level)
+	assert.Equal(t, "abc", entry.Data["trace_id"])
+	assert.Equal(t, int64(1), entry.Data["rows"])
+	assert.NotEmpty(t, entry.Data["caller"])
+	assert.NotEmpty(t, entry.Data["elapsed"])
+	assert.Contains(t, entry.Data["sql"], RedactedValue)
+	assert.NotContains(t, entry.Data["sql"], "13800000000")
+	assert.Contains(t, entry.Data["sql"], "brick")
+
+	// 慢查询
+	hook.Reset()
+	slow := NewLogger(logger, WithSlowThreshold(time.Millisecond))
+	slow.Trace(ctx, time.Now().Add(-time.Second), func() (string, int64) { return "SELECT 1", -1 }, nil)
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	_, ok := hook.LastEntry().Data["rows"]
+	assert.False(t, ok)
+
+	// 错误
+	hook.Reset()
+	slow.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 0 }, errors.New("boom"))
+	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+
+	hook.Reset()
+	NewLogger(logger, WithIgnoreRecordNotFound()).
+		Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 0 }, gorm.ErrRecordNotFound)
+	assert.Nil(t, hook.LastEntry())
+
+	// Warn 级别不输出普通 sql
+	hook.Reset()
+	slow.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 0 }, nil)
+	assert.Nil(t, hook.LastEntry())
+}
+
+func TestRepositoryDebugLogger(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(&logUser{}))
+
+	repo := NewGormRepository(db, logger, true, false, 0, "")
+	users := make([]logUser, 0)
+	assert.Nil(t, repo.GetAll(&users))
+	entry := hook.LastEntry()
+	assert.NotNil(t, entry)
+	assert.Contains(t, entry.Data["sql"], "SELECT")
+}
+
+func TestRepositoryNilDB(t *testing.T) {
+	assert.NotPanics(t, func() {
+		NewGormRepository(nil, logrus.New(), true, false, 0, "")
+	})
+}
@@ -0,0 +1,337 @@
+// Package migrate 基于版本号的 sql 迁移
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	m, err := migrate.New(db, migrations, migrate.WithDir("migrations"))
+//	applied, err := m.Up()
+//
+// 执行迁移时在 {table}_lock 表中加锁, 进程异常退出后锁不会自动释放,
+// 可以通过 WithLockTTL 让过期的锁被其他进程接管, 或者调用 ForceUnlock 手动释放
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/falcolee/xutils/xgen"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLocked another runner holds the migration lock
+	ErrLocked = errors.New("migrate: locked by another runner")
+	// ErrNoDown migration has no down sql
+	ErrNoDown = errors.New("migrate: no down migration")
+	// ErrUnknownVersion version not found in migration files
+	ErrUnknownVersion = errors.New("migrate: unknown version")
+)
+
+// record 版本记录
+type record struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// lockRecord 迁移锁, 同一时间只有一行
+type lockRecord struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"size:255"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+	Missing   bool       `json:"missing"` // 已执行但迁移文件不存在
+}
+
+// Migrator ...
+type Migrator struct {
+	db         *gorm.DB
+	option     *Option
+	migrations []*Migration
+	owner      string
+}
+
+// New 从 fsys 加载迁移文件
+func New(db *gorm.DB, fsys fs.FS, options ...OptionFn) (*Migrator, error) {
+	option := newOption(options...)
+	migrations, err := Load(fsys, option.Dir)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		option:     option,
+		migrations: migrations,
+		owner:      fmt.Sprintf("%s:%d:%s", host, os.Getpid(), xgen.UUID()),
+	}, nil
+}
+
+// Migrations 全部迁移, 按版本号升序
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+// Status 返回每个版本的执行状态
+func (m *Migrator) Status() ([]*Status, error) {
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := &Status{Version: migration.Version, Name: migration.Name}
+		if r, ok := applied[migration.Version]; ok {
+			appliedAt := r.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		res = append(res, status)
+	}
+	for _, r := range applied {
+		appliedAt := r.AppliedAt
+		res = append(res, &Status{
+			Version:   r.Version,
+			Name:      r.Name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+// Up 执行全部未执行的迁移, 返回本次执行的迁移
+func (m *Migrator) Up() ([]*Migration, error) {
+	return m.UpTo(0)
+}
+
+// UpTo 执行版本号 <= version 的未执行迁移, version 为 0 时执行全部
+func (m *Migrator) UpTo(version int64) (res []*Migration, err error) {
+	if version != 0 && m.find(version) == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	err = m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if version != 0 && migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(migration, true); err != nil {
+				return err
+			}
+			res = append(res, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// Down 回滚最近执行的 n 个迁移, 返回本次回滚的迁移
+func (m *Migrator) Down(n int) (res []*Migration, err error) {
+	if n < 1 {
+		return nil, fmt.Errorf("migrate: invalid step %d", n)
+	}
+	err = m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i] > versions[j]
+		})
+		if n < len(versions) {
+			versions = versions[:n]
+		}
+		// 先校验再执行, 避免回滚到一半
+		migrations := make([]*Migration, 0, len(versions))
+		for _, version := range versions {
+			migration := m.find(version)
+			if migration == nil {
+				return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d %s", ErrNoDown, migration.Version, migration.Name)
+			}
+			migrations = append(migrations, migration)
+		}
+		for _, migration := range migrations {
+			if err := m.run(migration, false); err != nil {
+				return err
+			}
+			res = append(res, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// ----------------------------------------------------------------
+
+// find ...
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
+
+// init 创建版本记录表与锁表
+func (m *Migrator) init() error {
+	if err := m.db.Table(m.option.Table).AutoMigrate(&record{}); err != nil {
+		return err
+	}
+	return m.db.Table(m.lockTable()).AutoMigrate(&lockRecord{})
+}
+
+// lockTable ...
+func (m *Migrator) lockTable() string {
+	return m.option.Table + "_lock"
+}
+
+// applied 已执行的版本
+func (m *Migrator) applied() (map[int64]*record, error) {
+	records := make([]*record, 0)
+	if err := m.db.Table(m.option.Table).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[int64]*record, len(records))
+	for _, r := range records {
+		res[r.Version] = r
+	}
+	return res, nil
+}
+
+// run 在事务中执行迁移并更新版本记录
+func (m *Migrator) run(migration *Migration, up bool) error {
+	sql, direction := migration.Up, "up"
+	if !up {
+		sql, direction = migration.Down, "down"
+	}
+	statements := SplitStatements(sql)
+	if m.option.DryRun {
+		m.option.Logger.Infof("migrate: [dry run] %s %d %s", direction, migration.Version, migration.Name)
+		for _, statement := range statements {
+			m.option.Logger.Info(statement)
+		}
+		return nil
+	}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Table(m.option.Table).Create(&record{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		}
+		return tx.Table(m.option.Table).Where("version = ?", migration.Version).Delete(&record{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: %s %d %s: %w", direction, migration.Version, migration.Name, err)
+	}
+	m.option.Logger.Infof("migrate: %s %d %s", direction, migration.Version, migration.Name)
+	return nil
+}
+
+// withLock 获取迁移锁后执行 fn, fn 成功但释放锁失败时返回释放锁的错误
+func (m *Migrator) withLock(fn func() error) (err error) {
+	if err = m.init(); err != nil {
+		return err
+	}
+	if err = m.lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := m.Unlock(); unlockErr != nil {
+			m.option.Logger.WithError(unlockErr).Errorf("migrate: unlock %s", m.owner)
+			if err == nil {
+				err = fmt.Errorf("migrate: unlock: %w", unlockErr)
+			}
+		}
+	}()
+	return fn()
+}
+
+// lock 插入唯一的锁记录, 已存在时等待直到超时, 超过 LockTTL 的锁视为过期并删除
+func (m *Migrator) lock() error {
+	deadline := time.Now().Add(m.option.LockTimeout)
+	for {
+		err := m.db.Table(m.lockTable()).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&lockRecord{ID: 1, Owner: m.owner, LockedAt: time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		current := &lockRecord{}
+		if err = m.db.Table(m.lockTable()).Where("id = ?", 1).Take(current).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if current.Owner == m.owner {
+			return nil
+		}
+		if m.option.LockTTL > 0 && time.Since(current.LockedAt) > m.option.LockTTL {
+			// 只删除读到的这把锁, 避免误删其他进程刚获取的锁
+			res := m.db.Table(m.lockTable()).Where("id = ? AND owner = ?", 1, current.Owner).Delete(&lockRecord{})
+			if res.Error != nil {
+				return res.Error
+			}
+			m.option.Logger.Warnf("migrate: remove stale lock %s locked at %s", current.Owner, current.LockedAt.Format(time.RFC3339))
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %s", ErrLocked, current.Owner)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Unlock 释放当前进程持有的锁
+func (m *Migrator) Unlock() error {
+	return m.db.Table(m.lockTable()).Where("id = ? AND owner = ?", 1, m.owner).Delete(&lockRecord{}).Error
+}
+
+// ForceUnlock 强制释放锁, 用于迁移进程异常退出后的恢复, 需要确认没有其他进程正在迁移
+func (m *Migrator) ForceUnlock() error {
+	if err := m.init(); err != nil {
+		return err
+	}
+	return m.db.Table(m.lockTable()).Where("id = ?", 1).Delete(&lockRecord{}).Error
+}
@@ -0,0 +1,229 @@
+package migrate
+
+import (
+	"embed"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+//go:embed testdata/migrations/*.sql
+var testMigrations embed.FS
+
+func newTestDB(t *testing.T) *gorm.DB {
+	dsn := filepath.Join(t.TempDir(), "migrate.db") + "?_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+	assert.Nil(t, err)
+	return db
+}
+
+func newTestMigrator(t *testing.T, db *gorm.DB, options ...OptionFn) *Migrator {
+	logger, _ := test.NewNullLogger()
+	options = append([]OptionFn{WithDir("testdata/migrations"), WithLogger(logger)}, options...)
+	m, err := New(db, testMigrations, options...)
+	assert.Nil(t, err)
+	return m
+}
+
+func versions(migrations []*Migration) []int64 {
+	res := make([]int64, 0, len(migrations))
+	for _, m := range migrations {
+		res = append(res, m.Version)
+	}
+	return res
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testMigrations, "testdata/migrations")
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, versions(migrations))
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.NotEmpty(t, migrations[0].Down)
+	assert.Empty(t, migrations[2].Down)
+
+	_, err = Load(fstest.MapFS{
+		"1_a.up.sql": {Data: []byte("SELECT 1")},
+		"1_b.up.sql": {Data: []byte("SELECT 1")},
+	}, ".")
+	assert.NotNil(t, err)
+
+	_, err = Load(fstest.MapFS{
+		"1_a.down.sql": {Data: []byte("SELECT 1")},
+	}, ".")
+	assert.NotNil(t, err)
+
+	migrations, err = Load(fstest.MapFS{
+		"README.md":   {Data: []byte("# migrations")},
+		"10_b.up.sql": {Data: []byte("SELECT 1")},
+		"9_a.up.sql":  {Data: []byte("SELECT 1")},
+	}, ".")
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{9, 10}, versions(migrations))
+}
+
+func TestSplitStatements(t *testing.T) {
+	assert.Equal(t, []string{
+		"CREATE TABLE t (id INT)",
+		"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`)",
+		"UPDATE t SET name = 'it''s; ok'",
+	}, SplitStatements(`
+-- comment; with semicolon
+CREATE TABLE t (id INT);
+/* block; comment */
+INSERT INTO t VALUES ('a;b', "c;d", `+"`e;f`"+`);
+UPDATE t SET name = 'it''s; ok';
+;
+`))
+}
+
+func TestMigrator(t *testing.T) {
+	db := newTestDB(t)
+	m := newTestMigrator(t, db)
+
+	status, err := m.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(status))
+	for _, s := range status {
+		assert.False(t, s.Applied)
+	}
+
+	// up to
+	applied, err := m.UpTo(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, versions(applied))
+	var email string
+	assert.Nil(t, db.Raw("SELECT email FROM users WHERE id = 1").Scan(&email).Error)
+	assert.Equal(t, "brick@example.com", email)
+	var name string
+	assert.Nil(t, db.Raw("SELECT name FROM users WHERE id = 1").Scan(&name).Error)
+	assert.Equal(t, "brick; admin", name)
+
+	_, err = m.UpTo(100)
+	assert.True(t, errors.Is(err, ErrUnknownVersion))
+
+	// up
+	applied, err = m.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{3}, versions(applied))
+	applied, err = m.Up()
+	assert.Nil(t, err)
+	assert.Empty(t, applied)
+
+	status, err = m.Status()
+	assert.Nil(t, err)
+	for _, s := range status {
+		assert.True(t, s.Applied)
+		assert.NotNil(t, s.AppliedAt)
+	}
+
+	for _, n := range []int{0, -1} {
+		rolled, err := m.Down(n)
+		assert.NotNil(t, err)
+		assert.Empty(t, rolled)
+	}
+
+	// 3 没有 down
+	_, err = m.Down(1)
+	assert.True(t, errors.Is(err, ErrNoDown))
+	assert.True(t, db.Migrator().HasTable("orders"))
+
+	assert.Nil(t, db.Exec("DROP TABLE orders").Error)
+	assert.Nil(t, db.Table("schema_migrations").Where("version = ?", 3).Delete(&record{}).Error)
+	rolled, err := m.Down(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2}, versions(rolled))
+	assert.False(t, db.Migrator().HasColumn("users", "email"))
+
+	rolled, err = m.Down(5)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1}, versions(rolled))
+	assert.False(t, db.Migrator().HasTable("users"))
+}
+
+func TestMigratorTransaction(t *testing.T) {
+	db := newTestDB(t)
+	logger, _ := test.NewNullLogger()
+	m, err := New(db, fstest.MapFS{
+		"1_ok.up.sql":  {Data: []byte("CREATE TABLE a (id INT);")},
+		"2_bad.up.sql": {Data: []byte("CREATE TABLE b (id INT); INSERT INTO missing VALUES (1);")},
+	}, WithLogger(logger))
+	assert.Nil(t, err)
+	applied, err := m.Up()
+	assert.NotNil(t, err)
+	assert.Equal(t, []int64{1}, versions(applied))
+	// 失败的迁移整体回滚
+	assert.False(t, db.Migrator().HasTable("b"))
+	status, err := m.Status()
+	assert.Nil(t, err)
+	assert.True(t, status[0].Applied)
+	assert.False(t, status[1].Applied)
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	db := newTestDB(t)
+	logger, hook := test.NewNullLogger()
+	m, err := New(db, testMigrations, WithDir("testdata/migrations"), WithDryRun(), WithLogger(logger))
+	assert.Nil(t, err)
+	applied, err := m.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, versions(applied))
+	assert.False(t, db.Migrator().HasTable("users"))
+	assert.NotEmpty(t, hook.AllEntries())
+	status, err := m.Status()
+	assert.Nil(t, err)
+	assert.False(t, status[0].Applied)
+}
+
+func TestMigratorLock(t *testing.T) {
+	db := newTestDB(t)
+	m1 := newTestMigrator(t, db)
+	m2 := newTestMigrator(t, db, WithLockTimeout(200*time.Millisecond))
+
+	assert.Nil(t, m1.init())
+	assert.Nil(t, m1.lock())
+	_, err := m2.Up()
+	assert.True(t, errors.Is(err, ErrLocked))
+
+	// 等待锁释放
+	m3 := newTestMigrator(t, db, WithLockTimeout(5*time.Second))
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		m1.Unlock()
+	}()
+	applied, err := m3.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(applied))
+
+	// 异常退出后强制释放
+	assert.Nil(t, m1.lock())
+	assert.Nil(t, m2.ForceUnlock())
+	_, err = m2.Down(1)
+	assert.True(t, errors.Is(err, ErrNoDown))
+
+	// 过期的锁被接管
+	stale := &lockRecord{ID: 1, Owner: "crashed", LockedAt: time.Now().Add(-time.Hour)}
+	assert.Nil(t, db.Table(m1.lockTable()).Create(stale).Error)
+	_, err = m2.Down(1)
+	assert.True(t, errors.Is(err, ErrLocked))
+	m4 := newTestMigrator(t, db, WithLockTimeout(0), WithLockTTL(time.Minute))
+	_, err = m4.Down(1)
+	assert.True(t, errors.Is(err, ErrNoDown))
+	var count int64
+	assert.Nil(t, db.Table(m1.lockTable()).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+
+	// 释放锁失败时返回错误
+	err = m4.withLock(func() error {
+		return db.Migrator().DropTable(m4.lockTable())
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "migrate: unlock")
+}
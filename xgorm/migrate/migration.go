@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int64  // 版本号
+	Name    string // 名称
+	Up      string // 升级 sql
+	Down    string // 回滚 sql, 可以为空
+}
+
+// migrationFile 迁移文件名, 如 20231001120000_create_users.up.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load 从 fs 中加载迁移文件, 按版本号升序
+//
+//	1_create_users.up.sql
+//	1_create_users.down.sql
+//	2_add_email.up.sql
+func Load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	migrations := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFile.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid version %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			migrations[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("migrate: duplicate version %d: %s, %s", version, m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	res := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migrate: version %d %s has no up migration", m.Version, m.Name)
+		}
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+// ----------------------------------------------------------------
+
+// SplitStatements 按 ; 拆分多条 sql, 忽略引号与注释中的 ;
+func SplitStatements(sql string) []string {
+	res := make([]string, 0)
+	var (
+		current strings.Builder
+		quote   rune // 当前所在引号
+	)
+	runes := []rune(sql)
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			res = append(res, s)
+		}
+		current.Reset()
+	}
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			current.WriteRune(c)
+			if c == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteRune(c)
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			// 单行注释
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			current.WriteRune('\n')
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			// 多行注释
+			for i += 2; i+1 < len(runes) && (runes[i] != '*' || runes[i+1] != '/'); i++ {
+			}
+			i++
+			current.WriteRune(' ')
+		case c == ';':
+			flush()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush()
+	return res
+}
@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option ...
+type Option struct {
+	Dir         string         // 迁移文件目录
+	Table       string         // 版本记录表
+	LockTimeout time.Duration  // 获取锁的超时时间
+	LockTTL     time.Duration  // 锁的有效期, 超过后视为持有者已退出, 0 表示不过期
+	DryRun      bool           // 只输出将要执行的 sql, 不实际执行
+	Logger      *logrus.Logger // 日志
+}
+
+// ----------------------------------------------------------------
+
+// OptionFn ...
+type OptionFn func(*Option)
+
+// WithDir 迁移文件所在目录, 默认为 fs 根目录
+func WithDir(v string) OptionFn {
+	return func(o *Option) {
+		o.Dir = v
+	}
+}
+
+// WithTable 版本记录表, 锁表为 {table}_lock
+func WithTable(v string) OptionFn {
+	return func(o *Option) {
+		if v != "" {
+			o.Table = v
+		}
+	}
+}
+
+// WithLockTimeout 等待其他迁移进程释放锁的超时时间, 0 表示不等待
+func WithLockTimeout(v time.Duration) OptionFn {
+	return func(o *Option) {
+		o.LockTimeout = v
+	}
+}
+
+// WithLockTTL 锁的有效期, 超过后其他进程可以接管, 需要大于最长一次迁移的耗时
+func WithLockTTL(v time.Duration) OptionFn {
+	return func(o *Option) {
+		o.LockTTL = v
+	}
+}
+
+// WithDryRun 只输出将要执行的 sql, 不实际执行
+func WithDryRun() OptionFn {
+	return func(o *Option) {
+		o.DryRun = true
+	}
+}
+
+// WithLogger ...
+func WithLogger(v *logrus.Logger) OptionFn {
+	return func(o *Option) {
+		if v != nil {
+			o.Logger = v
+		}
+	}
+}
+
+// ----------------------------------------------------------------
+
+// newOption ...
+func newOption(options ...OptionFn) *Option {
+	o := &Option{
+		Dir:         ".",
+		Table:       "schema_migrations",
+		LockTimeout: 10 * time.Second,
+		Logger:      logrus.StandardLogger(),
+	}
+	for _, fn := range options {
+		fn(o)
+	}
+	return o
+}
@@ -0,0 +1,6 @@
+-- 用户表
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY,
+    name VARCHAR(64) NOT NULL DEFAULT ''
+);
+INSERT INTO users (id, name) VALUES (1, 'brick; admin');
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN email VARCHAR(128);
+/* 默认邮箱; 之后可修改 */
+UPDATE users SET email = 'brick@example.com';
@@ -8,13 +8,17 @@ package xgorm
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	gormrepository "github.com/aklinkert/go-gorm-repository"
 	"github.com/falcolee/xutils/xcache"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 type NullType byte
@@ -34,23 +38,41 @@ type GormTransactionRepository interface {
 	FindWhereBatch(target interface{}, filters map[string]interface{}, limit, offset int, orderBy string, preloads ...string) error
 	FindWhereCount(target interface{}, filters map[string]interface{}) int64
 	UpdateWhere(target interface{}, filters map[string]interface{}, updates interface{}, preloads ...string) error
+	SaveWithRetry(target interface{}, attempts int, mutate func() error) error
+	Whitelist(model interface{}) (*Whitelist, error)
+	SetWhitelist(model interface{}, whitelist *Whitelist)
+	Restore(target interface{}, filters map[string]interface{}) error
+	ForceDelete(target interface{}) error
+	ForceDeleteWhere(target interface{}, filters map[string]interface{}) error
+	FindTrashed(target interface{}, filters map[string]interface{}, preloads ...string) error
+	Aggregate(model interface{}, dest interface{}, query AggregateQuery) error
 }
 
 type gormRepository struct {
 	logger       *logrus.Logger
+	sqlLogger    gormlogger.Interface
 	db           *gorm.DB
 	defaultJoins []string
 	debug        bool
 	useCache     bool
 	cacheTtl     time.Duration
 	cachePrefix  string
+	whitelists   sync.Map // reflect.Type => *Whitelist
 }
 
 // NewGormRepository returns a new base repository that implements TransactionRepository
+// debug 模式下 sql 日志通过 Logger 输出到 logger, 如果 db 已经使用了 *Logger 则沿用其配置
 func NewGormRepository(db *gorm.DB, logger *logrus.Logger, debug bool, useCache bool, cacheTtl time.Duration, cachePrefix string, defaultJoins ...string) GormTransactionRepository {
+	var sqlLogger gormlogger.Interface
+	if l, ok := dbLogger(db).(*Logger); ok {
+		sqlLogger = l.LogMode(gormlogger.Info)
+	} else {
+		sqlLogger = NewLogger(logger).LogMode(gormlogger.Info)
+	}
 	return &gormRepository{
 		defaultJoins: defaultJoins,
 		logger:       logger,
+		sqlLogger:    sqlLogger,
 		db:           db,
 		debug:        debug,
 		useCache:     useCache,
@@ -59,6 +81,14 @@ func NewGormRepository(db *gorm.DB, logger *logrus.Logger, debug bool, useCache
 	}
 }
 
+// dbLogger db 为空时返回 nil, 与之前一样允许延迟传入 db
+func dbLogger(db *gorm.DB) gormlogger.Interface {
+	if db == nil || db.Config == nil {
+		return nil
+	}
+	return db.Logger
+}
+
 func (r *gormRepository) DB() *gorm.DB {
 	return r.DBWithPreloads(nil)
 }
@@ -67,7 +97,6 @@ func (r *gormRepository) GetAll(target interface{}, preloads ...string) error {
 	r.logger.Debugf("Executing GetAll on %T", target)
 
 	res := r.DBWithPreloads(preloads).
-		Unscoped().
 		Find(target)
 
 	return r.HandleError(res)
@@ -77,7 +106,6 @@ func (r *gormRepository) GetBatch(target interface{}, limit, offset int, preload
 	r.logger.Debugf("Executing GetBatch on %T", target)
 
 	res := r.DBWithPreloads(preloads).
-		Unscoped().
 		Limit(limit).
 		Offset(offset).
 		Find(target)
@@ -87,62 +115,107 @@ func (r *gormRepository) GetBatch(target interface{}, limit, offset int, preload
 
 func (r *gormRepository) UpdateWhere(target interface{}, filters map[string]interface{}, updates interface{}, preloads ...string) error {
 	r.logger.Debugf("Executing UpdateWhere on %T with filters = %+v ", target, filters)
-
-	res := r.DBWithPreloads(preloads).
+	filters, err := r.checkFilters(target, filters)
+	if err != nil {
+		return err
+	}
+	if filters, err = r.blindFilters(target, filters); err != nil {
+		return err
+	}
+	db := r.DBWithPreloads(preloads).
 		Model(target).
-		Where(filters).
-		Updates(updates)
+		Where(filters)
 
-	return r.HandleError(res)
+	// 乐观锁: 校验 target 的版本号并在同一条 UPDATE 中自增
+	lock := newVersionLock(r.db, target)
+	if lock == nil {
+		return r.HandleError(db.Updates(updates))
+	}
+	if updates, err = lock.increment(updates); err != nil {
+		return err
+	}
+	current := lock.version()
+	if current != 0 {
+		db = db.Where(lock.condition(current))
+	}
+	res := db.Updates(updates)
+	if err := r.HandleError(res); err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+	if res.RowsAffected == 0 {
+		return r.staleOrNotFound(r.db.Model(target).Where(filters))
+	}
+	lock.set(current + 1)
+	return nil
 }
 
 func (r *gormRepository) FindWhere(target interface{}, filters map[string]interface{}, preloads ...string) error {
 	r.logger.Debugf("Executing FindWhere on %T with filters = %+v ", target, filters)
-	cond, vals, err := r.whereBuild(filters)
+	cond, vals, err := r.whereBuildChecked(target, filters)
 	if err != nil {
 		return err
 	}
-	res := r.DBWithPreloads(preloads).
-		Where(cond, vals...).
-		Order("id desc").
-		Find(target)
+	orders, err := primaryOrder(r.db, target)
+	if err != nil {
+		return err
+	}
+	db := r.DBWithPreloads(preloads).
+		Where(cond, vals...)
+	for _, order := range orders {
+		db = db.Order(order)
+	}
+	res := db.Find(target)
 
 	return r.HandleError(res)
 }
 
 func (r *gormRepository) FindWhereBatch(target interface{}, filters map[string]interface{}, limit, offset int, orderBy string, preloads ...string) error {
 	r.logger.Debugf("Executing FindWhereBatch on %T with filters = %+v ", target, filters)
-	cond, vals, err := r.whereBuild(filters)
+	cond, vals, err := r.whereBuildChecked(target, filters)
 	if err != nil {
 		return err
 	}
-	if orderBy == "" {
-		orderBy = "id desc"
+	orders, err := r.sortOrders(target, orderBy)
+	if err != nil {
+		return err
 	}
-	res := r.DBWithPreloads(preloads).
+	db := r.DBWithPreloads(preloads).
 		Where(cond, vals...).
 		Limit(limit).
-		Offset(offset).
-		Order(orderBy).
-		Find(target)
+		Offset(offset)
+	for _, order := range orders {
+		db = db.Order(order)
+	}
+	res := db.Find(target)
 
 	return r.HandleError(res)
 }
 
 func (r *gormRepository) FindWhereCount(target interface{}, filters map[string]interface{}) int64 {
 	r.logger.Debugf("Executing FindWhereCount on %T with filters = %+v ", target, filters)
+	total, _ := r.countWhere(target, filters)
+	return total
+}
+
+// countWhere 同 FindWhereCount, 返回查询错误
+func (r *gormRepository) countWhere(target interface{}, filters map[string]interface{}) (int64, error) {
 	var total int64
-	cond, vals, err := r.whereBuild(filters)
+	cond, vals, err := r.whereBuildChecked(target, filters)
 	if err != nil {
-		return 0
+		return 0, err
 	}
-	r.DB().Where(cond, vals...).Find(target).Count(&total)
-	return total
+	if err := r.HandleError(r.DB().Where(cond, vals...).Find(target).Count(&total)); err != nil {
+		return 0, err
+	}
+	return total, nil
 }
 
 func (r *gormRepository) DeleteWhere(target interface{}, filters map[string]interface{}) error {
 	r.logger.Debugf("Executing Delete on %T with filters = %+v ", target, filters)
-	cond, vals, err := r.whereBuild(filters)
+	cond, vals, err := r.whereBuildChecked(target, filters)
 	if err != nil {
 		return err
 	}
@@ -174,9 +247,11 @@ func (r *gormRepository) GetWhereBatch(target interface{}, condition string, lim
 
 func (r *gormRepository) GetByField(target interface{}, field string, value interface{}, preloads ...string) error {
 	r.logger.Debugf("Executing GetByField on %T with %v = %v", target, field, value)
-
-	res := r.DBWithPreloads(preloads).
-		Where(fmt.Sprintf("%v = ?", field), value).
+	db, err := r.whereFields(r.DBWithPreloads(preloads), target, map[string]interface{}{field: value})
+	if err != nil {
+		return err
+	}
+	res := db.
 		Find(target)
 
 	return r.HandleError(res)
@@ -185,9 +260,9 @@ func (r *gormRepository) GetByField(target interface{}, field string, value inte
 func (r *gormRepository) GetByFields(target interface{}, filters map[string]interface{}, preloads ...string) error {
 	r.logger.Debugf("Executing GetByField on %T with filters = %+v", target, filters)
 
-	db := r.DBWithPreloads(preloads)
-	for field, value := range filters {
-		db = db.Where(fmt.Sprintf("%v = ?", field), value)
+	db, err := r.whereFields(r.DBWithPreloads(preloads), target, filters)
+	if err != nil {
+		return err
 	}
 
 	res := db.Find(target)
@@ -197,9 +272,11 @@ func (r *gormRepository) GetByFields(target interface{}, filters map[string]inte
 
 func (r *gormRepository) GetByFieldBatch(target interface{}, field string, value interface{}, limit, offset int, preloads ...string) error {
 	r.logger.Debugf("Executing GetByField on %T with %v = %v", target, field, value)
-
-	res := r.DBWithPreloads(preloads).
-		Where(fmt.Sprintf("%v = ?", field), value).
+	db, err := r.whereFields(r.DBWithPreloads(preloads), target, map[string]interface{}{field: value})
+	if err != nil {
+		return err
+	}
+	res := db.
 		Limit(limit).
 		Offset(offset).
 		Find(target)
@@ -210,9 +287,9 @@ func (r *gormRepository) GetByFieldBatch(target interface{}, field string, value
 func (r *gormRepository) GetByFieldsBatch(target interface{}, filters map[string]interface{}, limit, offset int, preloads ...string) error {
 	r.logger.Debugf("Executing GetByField on %T with filters = %+v", target, filters)
 
-	db := r.DBWithPreloads(preloads)
-	for field, value := range filters {
-		db = db.Where(fmt.Sprintf("%v = ?", field), value)
+	db, err := r.whereFields(r.DBWithPreloads(preloads), target, filters)
+	if err != nil {
+		return err
 	}
 
 	res := db.
@@ -231,9 +308,11 @@ func (r *gormRepository) GetOneByField(target interface{}, field string, value i
 		ctx = xcache.NewExpiration(ctx, r.cacheTtl)
 		ctx = xcache.NewKey(ctx, keyStr)
 	}
-	res := r.DBWithPreloads(preloads).WithContext(ctx).
-		Where(fmt.Sprintf("%v = ?", field), value).
-		First(target)
+	db, err := r.whereFields(r.DBWithPreloads(preloads).WithContext(ctx), target, map[string]interface{}{field: value})
+	if err != nil {
+		return err
+	}
+	res := db.First(target)
 
 	return r.HandleOneError(res)
 }
@@ -246,9 +325,9 @@ func (r *gormRepository) GetOneByFields(target interface{}, filters map[string]i
 		ctx = xcache.NewExpiration(ctx, r.cacheTtl)
 		ctx = xcache.NewKey(ctx, keyStr)
 	}
-	db := r.DBWithPreloads(preloads).WithContext(ctx)
-	for field, value := range filters {
-		db = db.Where(fmt.Sprintf("%v = ?", field), value)
+	db, err := r.whereFields(r.DBWithPreloads(preloads).WithContext(ctx), target, filters)
+	if err != nil {
+		return err
 	}
 
 	res := db.First(target)
@@ -272,14 +351,14 @@ func (r *gormRepository) GetOneByID(target interface{}, id string, preloads ...s
 
 func (r *gormRepository) Create(target interface{}) error {
 	r.logger.Debugf("Executing Create on %T", target)
-
+	initVersion(r.db, target)
 	res := r.db.Create(target)
 	return r.HandleError(res)
 }
 
 func (r *gormRepository) CreateTx(target interface{}, tx *gorm.DB) error {
 	r.logger.Debugf("Executing Create on %T", target)
-
+	initVersion(tx, target)
 	res := tx.Create(target)
 	return r.HandleError(res)
 }
@@ -287,15 +366,13 @@ func (r *gormRepository) CreateTx(target interface{}, tx *gorm.DB) error {
 func (r *gormRepository) Save(target interface{}) error {
 	r.logger.Debugf("Executing Save on %T", target)
 
-	res := r.db.Save(target)
-	return r.HandleError(res)
+	return r.saveVersioned(r.db, target)
 }
 
 func (r *gormRepository) SaveTx(target interface{}, tx *gorm.DB) error {
 	r.logger.Debugf("Executing Save on %T", target)
 
-	res := tx.Save(target)
-	return r.HandleError(res)
+	return r.saveVersioned(tx, target)
 }
 
 func (r *gormRepository) Delete(target interface{}) error {
@@ -346,12 +423,125 @@ func (r *gormRepository) DBWithPreloads(preloads []string) *gorm.DB {
 	}
 
 	if r.debug {
-		dbConn = dbConn.Debug()
+		dbConn = dbConn.Session(&gorm.Session{Logger: r.sqlLogger})
 	}
 
 	return dbConn
 }
 
+// Whitelist 返回模型的字段白名单, 未设置时根据模型 schema 生成
+func (r *gormRepository) Whitelist(model interface{}) (*Whitelist, error) {
+	typ := modelType(model)
+	if w, ok := r.whitelists.Load(typ); ok {
+		return w.(*Whitelist), nil
+	}
+	w, err := NewWhitelist(r.db, model)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := r.whitelists.LoadOrStore(typ, w)
+	return actual.(*Whitelist), nil
+}
+
+// SetWhitelist 设置模型的字段白名单
+func (r *gormRepository) SetWhitelist(model interface{}, whitelist *Whitelist) {
+	r.whitelists.Store(modelType(model), whitelist)
+}
+
+// modelType 模型的结构体类型, 兼容指针与切片
+func modelType(model interface{}) reflect.Type {
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// checkField 校验字段, 返回数据库字段名
+func (r *gormRepository) checkField(target interface{}, field string) (string, error) {
+	w, err := r.Whitelist(target)
+	if err != nil {
+		return "", err
+	}
+	return w.Column(field)
+}
+
+// checkFilters 校验过滤条件
+func (r *gormRepository) checkFilters(target interface{}, filters map[string]interface{}) (map[string]interface{}, error) {
+	w, err := r.Whitelist(target)
+	if err != nil {
+		return nil, err
+	}
+	return w.Filters(filters)
+}
+
+// checkSort 校验排序规则
+func (r *gormRepository) checkSort(target interface{}, orderBy string) ([]clause.OrderByColumn, error) {
+	w, err := r.Whitelist(target)
+	if err != nil {
+		return nil, err
+	}
+	return w.Sort(orderBy)
+}
+
+// sortOrders 未指定排序时按主键倒序, 否则按白名单校验
+func (r *gormRepository) sortOrders(target interface{}, orderBy string) ([]clause.OrderByColumn, error) {
+	if orderBy == "" {
+		return primaryOrder(r.db, target)
+	}
+	return r.checkSort(target, orderBy)
+}
+
+// primaryOrder 主键倒序, 不是用户输入所以不经过白名单, 没有主键时不排序
+func primaryOrder(db *gorm.DB, target interface{}) ([]clause.OrderByColumn, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(target); err != nil {
+		return nil, err
+	}
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil && len(stmt.Schema.PrimaryFields) > 0 {
+		field = stmt.Schema.PrimaryFields[0]
+	}
+	if field == nil {
+		return nil, nil
+	}
+	return []clause.OrderByColumn{{
+		Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+		Desc:   true,
+	}}, nil
+}
+
+// whereFields 校验字段并添加等值条件, 加密字段改写为盲索引字段
+func (r *gormRepository) whereFields(db *gorm.DB, target interface{}, filters map[string]interface{}) (*gorm.DB, error) {
+	columns := make(map[string]interface{}, len(filters))
+	for field, value := range filters {
+		column, err := r.checkField(target, field)
+		if err != nil {
+			return nil, err
+		}
+		columns[column] = value
+	}
+	columns, err := r.blindFilters(target, columns)
+	if err != nil {
+		return nil, err
+	}
+	for column, value := range columns {
+		db = db.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+	}
+	return db, nil
+}
+
+// whereBuildChecked 校验过滤条件后构建 where
+func (r *gormRepository) whereBuildChecked(target interface{}, filters map[string]interface{}) (whereSQL string, vals []interface{}, err error) {
+	if filters, err = r.checkFilters(target, filters); err != nil {
+		return "", nil, err
+	}
+	if filters, err = r.blindFilters(target, filters); err != nil {
+		return "", nil, err
+	}
+	return r.whereBuild(filters)
+}
+
 // sql build where
 func (r *gormRepository) whereBuild(where map[string]interface{}) (whereSQL string, vals []interface{}, err error) {
 	for k, v := range where {
@@ -370,7 +560,6 @@ func (r *gormRepository) whereBuild(where map[string]interface{}) (whereSQL stri
 		strings.Join(ks, ",")
 		switch len(ks) {
 		case 1:
-			//fmt.Println(reflect.TypeOf(v))
 			switch v := v.(type) {
 			case NullType:
 				if v == IsNotNull {
@@ -412,6 +601,8 @@ func (r *gormRepository) whereBuild(where map[string]interface{}) (whereSQL stri
 			case "like":
 				whereSQL += fmt.Sprint("`", k, "`", " like ?")
 				vals = append(vals, v)
+			default:
+				return "", nil, &FieldError{Field: strings.Join(ks, " "), Err: ErrOperatorNotAllowed}
 			}
 		}
 	}
@@ -0,0 +1,388 @@
+/*
+ * @Date: 2026-10-19 15:10:42
+ * @LastEditTime: 2026-10-19 15:10:42
+ * @Description:
+ */
+package xgorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/falcolee/xutils/xhashring"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrNoShard no shard configured
+	ErrNoShard = errors.New("xgorm: no shard")
+	// ErrShardExists shard name already used
+	ErrShardExists = errors.New("xgorm: shard already exists")
+)
+
+// ShardedRepository 按分片 key 一致性哈希路由到不同数据库
+type ShardedRepository interface {
+	// Shard 返回 key 所在分片的 repository
+	Shard(key string) GormTransactionRepository
+	// DB 返回 key 所在分片的 *gorm.DB
+	DB(key string) *gorm.DB
+	// Node 返回 key 所在分片的名称
+	Node(key string) string
+	// Nodes 返回全部分片名称
+	Nodes() []string
+	// Each 依次对每个分片执行 fn, 用于建表等操作
+	Each(fn func(node string, repo GormTransactionRepository) error) error
+	// FindWhereAll 在全部分片上查询, 按 orderBy 归并后返回 offset, limit 范围内的数据
+	FindWhereAll(target interface{}, filters map[string]interface{}, limit, offset int, orderBy string, preloads ...string) error
+	// FindWhereCountAll 全部分片的数量之和, 任一分片查询失败时返回错误
+	FindWhereCountAll(target interface{}, filters map[string]interface{}) (int64, error)
+	// AddShard 添加分片, 添加前可以通过 Moves 获取需要迁移的 key
+	AddShard(node string, db *gorm.DB) error
+	// Moves 返回添加分片 node 后需要迁移的 key
+	Moves(keys []string, node string) []xhashring.Move
+}
+
+type shardedRepository struct {
+	mu    sync.RWMutex
+	ring  *xhashring.Ring
+	repos map[string]GormTransactionRepository
+	dbs   map[string]*gorm.DB
+	newFn func(db *gorm.DB) GormTransactionRepository
+}
+
+// NewShardedRepository 参数与 NewGormRepository 相同, 每个分片使用相同的配置
+func NewShardedRepository(shards map[string]*gorm.DB, logger *logrus.Logger, debug bool, useCache bool, cacheTtl time.Duration, cachePrefix string, defaultJoins ...string) (ShardedRepository, error) {
+	if len(shards) == 0 {
+		return nil, ErrNoShard
+	}
+	r := &shardedRepository{
+		ring:  xhashring.NewRing(),
+		repos: make(map[string]GormTransactionRepository, len(shards)),
+		dbs:   make(map[string]*gorm.DB, len(shards)),
+		newFn: func(db *gorm.DB) GormTransactionRepository {
+			return NewGormRepository(db, logger, debug, useCache, cacheTtl, cachePrefix, defaultJoins...)
+		},
+	}
+	for node, db := range shards {
+		if err := r.AddShard(node, db); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *shardedRepository) Shard(key string) GormTransactionRepository {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.repos[r.Node(key)]
+}
+
+func (r *shardedRepository) DB(key string) *gorm.DB {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dbs[r.Node(key)]
+}
+
+func (r *shardedRepository) Node(key string) string {
+	node, _ := r.ring.Node(key)
+	return node
+}
+
+func (r *shardedRepository) Nodes() []string {
+	return r.ring.Nodes()
+}
+
+func (r *shardedRepository) Each(fn func(node string, repo GormTransactionRepository) error) error {
+	for _, node := range r.Nodes() {
+		r.mu.RLock()
+		repo := r.repos[node]
+		r.mu.RUnlock()
+		if err := fn(node, repo); err != nil {
+			return fmt.Errorf("shard %s: %w", node, err)
+		}
+	}
+	return nil
+}
+
+func (r *shardedRepository) FindWhereAll(target interface{}, filters map[string]interface{}, limit, offset int, orderBy string, preloads ...string) error {
+	sliceValue := reflect.ValueOf(target)
+	if sliceValue.Kind() != reflect.Ptr || sliceValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("xgorm: target must be a pointer to slice, got %T", target)
+	}
+	sliceType := sliceValue.Elem().Type()
+	// 每个分片取前 offset+limit 条, 归并后再截取
+	size := -1
+	if limit > 0 {
+		size = offset + limit
+	}
+
+	nodes := r.Nodes()
+	results := make([]reflect.Value, len(nodes))
+	errs := make([]error, len(nodes))
+	wg := sync.WaitGroup{}
+	for i, node := range nodes {
+		r.mu.RLock()
+		repo := r.repos[node]
+		r.mu.RUnlock()
+		wg.Add(1)
+		go func(i int, node string, repo GormTransactionRepository) {
+			defer wg.Done()
+			ptr := reflect.New(sliceType)
+			if err := repo.FindWhereBatch(ptr.Interface(), filters, size, 0, orderBy, preloads...); err != nil {
+				errs[i] = fmt.Errorf("shard %s: %w", node, err)
+				return
+			}
+			results[i] = ptr.Elem()
+		}(i, node, repo)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	merged := reflect.MakeSlice(sliceType, 0, 0)
+	for _, res := range results {
+		merged = reflect.AppendSlice(merged, res)
+	}
+	if err := r.sortMerged(merged, target, orderBy); err != nil {
+		return err
+	}
+	start, end := offset, merged.Len()
+	if start > end {
+		start = end
+	}
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	sliceValue.Elem().Set(merged.Slice(start, end))
+	return nil
+}
+
+func (r *shardedRepository) FindWhereCountAll(target interface{}, filters map[string]interface{}) (int64, error) {
+	var total int64
+	err := r.Each(func(node string, repo GormTransactionRepository) error {
+		count, err := repo.(*gormRepository).countWhere(target, filters)
+		total += count
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *shardedRepository) AddShard(node string, db *gorm.DB) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.repos[node]; ok {
+		return fmt.Errorf("%w: %s", ErrShardExists, node)
+	}
+	r.repos[node] = r.newFn(db)
+	r.dbs[node] = db
+	r.ring.Add(node, 1)
+	return nil
+}
+
+func (r *shardedRepository) Moves(keys []string, node string) []xhashring.Move {
+	return r.ring.Moves(keys, node, 1)
+}
+
+// sortMerged 按排序规则对归并后的数据稳定排序
+func (r *shardedRepository) sortMerged(merged reflect.Value, target interface{}, orderBy string) error {
+	var (
+		repo GormTransactionRepository
+		db   *gorm.DB
+	)
+	r.mu.RLock()
+	for node := range r.repos {
+		repo, db = r.repos[node], r.dbs[node]
+		break
+	}
+	r.mu.RUnlock()
+	var (
+		orders []clause.OrderByColumn
+		err    error
+	)
+	if orderBy == "" {
+		// 与各分片相同, 默认按主键倒序
+		orders, err = primaryOrder(db, target)
+	} else {
+		var w *Whitelist
+		if w, err = repo.Whitelist(target); err == nil {
+			orders, err = w.Sort(orderBy)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(target); err != nil {
+		return err
+	}
+	fields := make([]*schema.Field, 0, len(orders))
+	for _, order := range orders {
+		field := stmt.Schema.LookUpField(order.Column.Name)
+		if field == nil {
+			return &FieldError{Field: order.Column.Name, Err: ErrInvalidSort}
+		}
+		fields = append(fields, field)
+	}
+
+	ctx := context.Background()
+	keys := make([][]interface{}, merged.Len())
+	for i := range keys {
+		item := reflect.Indirect(merged.Index(i))
+		keys[i] = make([]interface{}, len(fields))
+		for j, field := range fields {
+			keys[i][j], _ = field.ValueOf(ctx, item)
+		}
+	}
+	index := make([]int, merged.Len())
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		return lessOrder(keys[index[a]], keys[index[b]], orders)
+	})
+	sorted := reflect.MakeSlice(merged.Type(), merged.Len(), merged.Len())
+	for i, j := range index {
+		sorted.Index(i).Set(merged.Index(j))
+	}
+	reflect.Copy(merged, sorted)
+	return nil
+}
+
+// lessOrder 按多个排序字段比较
+func lessOrder(a, b []interface{}, orders []clause.OrderByColumn) bool {
+	for i, order := range orders {
+		c := compareValue(a[i], b[i])
+		if c == 0 {
+			continue
+		}
+		if order.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// compareValue 比较两个字段值, nil 最小
+func compareValue(a, b interface{}) int {
+	a, b = sortValue(a), sortValue(b)
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch av := a.(type) {
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			switch {
+			case av.Before(bv):
+				return -1
+			case av.After(bv):
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			}
+			return 1
+		}
+	case int64:
+		if bv, ok := b.(int64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case uint64:
+		if bv, ok := b.(uint64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// sortValue 统一数值类型, 展开指针与 driver.Valuer
+func sortValue(v interface{}) interface{} {
+	if valuer, ok := v.(driver.Valuer); ok {
+		rv := reflect.ValueOf(valuer)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		v = value
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	}
+	return rv.Interface()
+}
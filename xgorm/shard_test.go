@@ -0,0 +1,137 @@
+package xgorm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type shardOrder struct {
+	ID        uint `gorm:"primarykey"`
+	Tenant    string
+	Amount    int
+	CreatedAt time.Time
+}
+
+func newTestShards(t *testing.T, nodes ...string) map[string]*gorm.DB {
+	shards := make(map[string]*gorm.DB, len(nodes))
+	for _, node := range nodes {
+		db := newTestDB(t, &shardOrder{})
+		sqlDB, err := db.DB()
+		assert.Nil(t, err)
+		sqlDB.SetMaxOpenConns(1)
+		shards[node] = db
+	}
+	return shards
+}
+
+func TestShardedRepository(t *testing.T) {
+	_, err := NewShardedRepository(nil, logrus.New(), false, false, 0, "")
+	assert.True(t, errors.Is(err, ErrNoShard))
+
+	shards := newTestShards(t, "db0", "db1", "db2")
+	repo, err := NewShardedRepository(shards, logrus.New(), false, false, 0, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db0", "db1", "db2"}, repo.Nodes())
+
+	base := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	used := make(map[string]struct{})
+	for i := 0; i < 30; i++ {
+		tenant := fmt.Sprintf("tenant_%d", i)
+		order := &shardOrder{Tenant: tenant, Amount: i, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		assert.Nil(t, repo.Shard(tenant).Create(order))
+		used[repo.Node(tenant)] = struct{}{}
+		assert.Equal(t, shards[repo.Node(tenant)], repo.DB(tenant))
+	}
+	assert.Equal(t, 3, len(used))
+
+	// 同一个 key 总是路由到同一个分片
+	found := make([]*shardOrder, 0)
+	assert.Nil(t, repo.Shard("tenant_7").FindWhere(&found, map[string]interface{}{"tenant": "tenant_7"}))
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, 7, found[0].Amount)
+
+	total, err := repo.FindWhereCountAll(&[]shardOrder{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(30), total)
+	total, err = repo.FindWhereCountAll(&[]shardOrder{}, map[string]interface{}{"amount >=": 10})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), total)
+	_, err = repo.FindWhereCountAll(&[]shardOrder{}, map[string]interface{}{"unknown": 1})
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+
+	// 归并排序与分页
+	orders := make([]*shardOrder, 0)
+	assert.Nil(t, repo.FindWhereAll(&orders, nil, 5, 3, "-created_at"))
+	assert.Equal(t, []int{26, 25, 24, 23, 22}, amounts(orders))
+
+	orders = make([]*shardOrder, 0)
+	assert.Nil(t, repo.FindWhereAll(&orders, map[string]interface{}{"amount <": 10}, 0, 0, "amount"))
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, amounts(orders))
+
+	values := make([]shardOrder, 0)
+	assert.Nil(t, repo.FindWhereAll(&values, nil, 3, 28, "amount"))
+	assert.Equal(t, 2, len(values))
+	assert.Equal(t, 28, values[0].Amount)
+
+	assert.True(t, errors.Is(repo.FindWhereAll(&orders, nil, 1, 0, "unknown"), ErrFieldNotAllowed))
+	assert.NotNil(t, repo.FindWhereAll(orders, nil, 1, 0, ""))
+
+	count := 0
+	assert.Nil(t, repo.Each(func(node string, r GormTransactionRepository) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, 3, count)
+
+	// 任一分片失败时返回错误, 不会少计数量
+	sqlDB, err := shards["db1"].DB()
+	assert.Nil(t, err)
+	assert.Nil(t, sqlDB.Close())
+	total, err = repo.FindWhereCountAll(&[]shardOrder{}, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "shard db1")
+	assert.Equal(t, int64(0), total)
+}
+
+func TestShardedRepositoryAddShard(t *testing.T) {
+	shards := newTestShards(t, "db0", "db1")
+	repo, err := NewShardedRepository(shards, logrus.New(), false, false, 0, "")
+	assert.Nil(t, err)
+
+	keys := make([]string, 0, 100)
+	before := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("tenant_%d", i)
+		keys = append(keys, key)
+		before[key] = repo.Node(key)
+	}
+	moves := repo.Moves(keys, "db2")
+	assert.NotEmpty(t, moves)
+	assert.Equal(t, 2, len(repo.Nodes()))
+
+	extra := newTestShards(t, "db2")
+	assert.Nil(t, repo.AddShard("db2", extra["db2"]))
+	assert.True(t, errors.Is(repo.AddShard("db2", extra["db2"]), ErrShardExists))
+	moved := 0
+	for _, key := range keys {
+		if repo.Node(key) != before[key] {
+			assert.Equal(t, "db2", repo.Node(key))
+			moved++
+		}
+	}
+	assert.Equal(t, len(moves), moved)
+}
+
+func amounts(orders []*shardOrder) []int {
+	res := make([]int, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, o.Amount)
+	}
+	return res
+}
@@ -0,0 +1,112 @@
+/*
+ * @Date: 2026-10-19 16:42:03
+ * @LastEditTime: 2026-10-19 16:42:03
+ * @Description:
+ */
+package xgorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrSoftDeleteNotSupported model has no gorm.DeletedAt field
+var ErrSoftDeleteNotSupported = errors.New("xgorm: model does not support soft delete")
+
+// softDeleteColumn 模型的软删除字段, 不支持软删除时返回 ErrSoftDeleteNotSupported
+func (r *gormRepository) softDeleteColumn(target interface{}) (*schema.Schema, *schema.Field, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(target); err != nil {
+		return nil, nil, err
+	}
+	field := softDeleteField(stmt.Schema)
+	if field == nil {
+		return nil, nil, ErrSoftDeleteNotSupported
+	}
+	return stmt.Schema, field, nil
+}
+
+// Restore 恢复软删除的数据, filters 为空时按 target 的主键恢复
+func (r *gormRepository) Restore(target interface{}, filters map[string]interface{}) error {
+	r.logger.Debugf("Executing Restore on %T with filters = %+v ", target, filters)
+	s, field, err := r.softDeleteColumn(target)
+	if err != nil {
+		return err
+	}
+	cond, vals, err := r.whereBuildChecked(target, filters)
+	if err != nil {
+		return err
+	}
+	if cond == "" && !hasPrimaryKey(s, target) {
+		return gorm.ErrMissingWhereClause
+	}
+	db := r.db.Unscoped().
+		Model(target).
+		Where(clause.Neq{Column: clause.Column{Name: field.DBName}, Value: nil})
+	if cond != "" {
+		db = db.Where(cond, vals...)
+	}
+	res := db.Update(field.DBName, nil)
+	return r.HandleError(res)
+}
+
+// ForceDelete 物理删除, 忽略软删除
+func (r *gormRepository) ForceDelete(target interface{}) error {
+	r.logger.Debugf("Executing ForceDelete on %T", target)
+
+	res := r.db.Unscoped().Delete(target)
+	return r.HandleError(res)
+}
+
+// ForceDeleteWhere 按条件物理删除, 包括已软删除的数据
+func (r *gormRepository) ForceDeleteWhere(target interface{}, filters map[string]interface{}) error {
+	r.logger.Debugf("Executing ForceDeleteWhere on %T with filters = %+v ", target, filters)
+	cond, vals, err := r.whereBuildChecked(target, filters)
+	if err != nil {
+		return err
+	}
+	res := r.db.Unscoped().Where(cond, vals...).Delete(target)
+	return r.HandleError(res)
+}
+
+// FindTrashed 只查询已软删除的数据
+func (r *gormRepository) FindTrashed(target interface{}, filters map[string]interface{}, preloads ...string) error {
+	r.logger.Debugf("Executing FindTrashed on %T with filters = %+v ", target, filters)
+	_, field, err := r.softDeleteColumn(target)
+	if err != nil {
+		return err
+	}
+	cond, vals, err := r.whereBuildChecked(target, filters)
+	if err != nil {
+		return err
+	}
+	orders, err := primaryOrder(r.db, target)
+	if err != nil {
+		return err
+	}
+	db := r.DBWithPreloads(preloads).
+		Unscoped().
+		Where(clause.Neq{Column: clause.Column{Name: field.DBName}, Value: nil}).
+		Where(cond, vals...)
+	for _, order := range orders {
+		db = db.Order(order)
+	}
+	res := db.Find(target)
+
+	return r.HandleError(res)
+}
+
+// hasPrimaryKey target 是否为主键非零的结构体
+func hasPrimaryKey(s *schema.Schema, target interface{}) bool {
+	rv := reflect.Indirect(reflect.ValueOf(target))
+	if rv.Kind() != reflect.Struct || s.PrioritizedPrimaryField == nil {
+		return false
+	}
+	_, zero := s.PrioritizedPrimaryField.ValueOf(context.Background(), rv)
+	return !zero
+}
@@ -0,0 +1,57 @@
+package xgorm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSoftDeleteLifecycle(t *testing.T) {
+	db := newTestDB(t, &auditUser{}, &auditTag{})
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+
+	for _, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, repo.Create(&auditUser{Name: name}))
+	}
+	assert.Nil(t, repo.Delete(&auditUser{ID: 1}))
+	assert.Nil(t, repo.DeleteWhere(&auditUser{}, map[string]interface{}{"name": "b"}))
+
+	// GetAll 不包含已删除的数据
+	users := make([]*auditUser, 0)
+	assert.Nil(t, repo.GetAll(&users))
+	assert.Equal(t, 1, len(users))
+	users = make([]*auditUser, 0)
+	assert.Nil(t, repo.GetBatch(&users, 10, 0))
+	assert.Equal(t, 1, len(users))
+
+	trashed := make([]*auditUser, 0)
+	assert.Nil(t, repo.FindTrashed(&trashed, nil))
+	assert.Equal(t, 2, len(trashed))
+	trashed = make([]*auditUser, 0)
+	assert.Nil(t, repo.FindTrashed(&trashed, map[string]interface{}{"name": "a"}))
+	assert.Equal(t, 1, len(trashed))
+
+	// 按主键恢复
+	user := &auditUser{ID: 1}
+	assert.Nil(t, repo.Restore(user, nil))
+	assert.False(t, user.DeletedAt.Valid)
+	// 按条件恢复
+	assert.Nil(t, repo.Restore(&auditUser{}, map[string]interface{}{"name": "b"}))
+	assert.True(t, errors.Is(repo.Restore(&auditUser{}, nil), gorm.ErrMissingWhereClause))
+	users = make([]*auditUser, 0)
+	assert.Nil(t, repo.GetAll(&users))
+	assert.Equal(t, 3, len(users))
+
+	assert.Nil(t, repo.ForceDelete(&auditUser{ID: 1}))
+	assert.Nil(t, repo.Delete(&auditUser{ID: 2}))
+	assert.Nil(t, repo.ForceDeleteWhere(&auditUser{}, map[string]interface{}{"name": "b"}))
+	var total int64
+	assert.Nil(t, db.Unscoped().Model(&auditUser{}).Count(&total).Error)
+	assert.Equal(t, int64(1), total)
+
+	assert.True(t, errors.Is(repo.Restore(&auditTag{ID: 1}, nil), ErrSoftDeleteNotSupported))
+	assert.True(t, errors.Is(repo.FindTrashed(&[]*auditTag{}, nil), ErrSoftDeleteNotSupported))
+}
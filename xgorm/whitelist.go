@@ -0,0 +1,230 @@
+/*
+ * @Date: 2026-10-19 11:20:37
+ * @LastEditTime: 2026-10-19 11:20:37
+ * @Description:
+ */
+package xgorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrFieldNotAllowed field is not in the whitelist
+	ErrFieldNotAllowed = errors.New("xgorm: field not allowed")
+	// ErrOperatorNotAllowed operator is not supported
+	ErrOperatorNotAllowed = errors.New("xgorm: operator not allowed")
+	// ErrInvalidSort sort spec is invalid
+	ErrInvalidSort = errors.New("xgorm: invalid sort")
+)
+
+// FieldError 字段校验错误, 可通过 errors.Is 判断具体类型
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error ...
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Err.Error(), e.Field)
+}
+
+// Unwrap ...
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// whereOperators whereBuild 支持的操作符
+var whereOperators = map[string]struct{}{
+	"=": {}, ">": {}, ">=": {}, "<": {}, "<=": {}, "!=": {}, "<>": {}, "in": {}, "like": {},
+}
+
+// ----------------------------------------------------------------
+
+// SortField 排序字段
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort 解析排序规则, 多个字段用逗号分隔
+//
+//	-created_at,name    => created_at desc, name asc
+//	id desc, +name      => id desc, name asc
+func ParseSort(spec string) ([]SortField, error) {
+	res := make([]SortField, 0)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		sort := SortField{}
+		parts := strings.Fields(item)
+		switch len(parts) {
+		case 1:
+		case 2:
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				sort.Desc = true
+			default:
+				return nil, &FieldError{Field: item, Err: ErrInvalidSort}
+			}
+		default:
+			return nil, &FieldError{Field: item, Err: ErrInvalidSort}
+		}
+		field := parts[0]
+		if strings.HasPrefix(field, "-") {
+			if sort.Desc || len(parts) == 2 {
+				return nil, &FieldError{Field: item, Err: ErrInvalidSort}
+			}
+			sort.Desc = true
+			field = field[1:]
+		} else if strings.HasPrefix(field, "+") {
+			field = field[1:]
+		}
+		if !isFieldName(field) {
+			return nil, &FieldError{Field: item, Err: ErrInvalidSort}
+		}
+		sort.Field = field
+		res = append(res, sort)
+	}
+	if len(res) == 0 {
+		return nil, &FieldError{Field: spec, Err: ErrInvalidSort}
+	}
+	return res, nil
+}
+
+// isFieldName 字段名只允许字母数字下划线
+func isFieldName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ----------------------------------------------------------------
+
+// Whitelist 基于模型 schema 的字段白名单
+// 输入字段可以是数据库字段名, 结构体字段名或 json tag 名
+type Whitelist struct {
+	mu      sync.RWMutex
+	columns map[string]string // 输入名 => 数据库字段名
+}
+
+// NewWhitelist 根据模型 schema 构建白名单, fields 不为空时只允许指定的字段
+func NewWhitelist(db *gorm.DB, model interface{}, fields ...string) (*Whitelist, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	all := make(map[string]string)
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		all[field.DBName] = field.DBName
+		all[field.Name] = field.DBName
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+			all[tag] = field.DBName
+		}
+	}
+	w := &Whitelist{columns: all}
+	if len(fields) == 0 {
+		return w, nil
+	}
+	allowed := make(map[string]struct{})
+	for _, field := range fields {
+		column, ok := all[field]
+		if !ok {
+			return nil, &FieldError{Field: field, Err: ErrFieldNotAllowed}
+		}
+		allowed[column] = struct{}{}
+	}
+	w.columns = make(map[string]string)
+	for name, column := range all {
+		if _, ok := allowed[column]; ok {
+			w.columns[name] = column
+		}
+	}
+	return w, nil
+}
+
+// Allow 添加白名单字段, 用于关联表等 schema 之外的字段
+func (w *Whitelist) Allow(name, column string) *Whitelist {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.columns[name] = column
+	return w
+}
+
+// Column 返回字段对应的数据库字段名
+func (w *Whitelist) Column(field string) (string, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if column, ok := w.columns[field]; ok {
+		return column, nil
+	}
+	return "", &FieldError{Field: field, Err: ErrFieldNotAllowed}
+}
+
+// Sort 解析并校验排序规则
+func (w *Whitelist) Sort(spec string) ([]clause.OrderByColumn, error) {
+	sorts, err := ParseSort(spec)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]clause.OrderByColumn, 0, len(sorts))
+	for _, sort := range sorts {
+		column, err := w.Column(sort.Field)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, clause.OrderByColumn{
+			Column: clause.Column{Name: column},
+			Desc:   sort.Desc,
+		})
+	}
+	return res, nil
+}
+
+// Filters 校验 FindWhere 格式的过滤条件, 返回替换为数据库字段名的新条件
+//
+//	{"name": "brick", "createdAt >=": 1, "age > or": 18}
+func (w *Whitelist) Filters(filters map[string]interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{}, len(filters))
+	for k, v := range filters {
+		ks := strings.Split(k, " ")
+		if len(ks) > 3 {
+			return nil, &FieldError{Field: k, Err: ErrOperatorNotAllowed}
+		}
+		if len(ks) > 1 {
+			if _, ok := whereOperators[strings.ToLower(ks[1])]; !ok {
+				return nil, &FieldError{Field: k, Err: ErrOperatorNotAllowed}
+			}
+		}
+		if len(ks) == 3 {
+			if logic := strings.ToLower(ks[2]); logic != "or" && logic != "and" {
+				return nil, &FieldError{Field: k, Err: ErrOperatorNotAllowed}
+			}
+		}
+		column, err := w.Column(ks[0])
+		if err != nil {
+			return nil, err
+		}
+		ks[0] = column
+		res[strings.Join(ks, " ")] = v
+	}
+	return res, nil
+}
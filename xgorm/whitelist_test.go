@@ -0,0 +1,175 @@
+package xgorm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type sortUser struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	Name      string `json:"name"`
+	Age       int    `json:"age"`
+	CreatedAt int64  `json:"createdAt"`
+	Password  string `json:"-"`
+}
+
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	assert.Nil(t, err)
+	assert.Nil(t, db.AutoMigrate(models...))
+	return db
+}
+
+func TestParseSort(t *testing.T) {
+	sorts, err := ParseSort("-created_at, name,+id,age desc, Score ASC")
+	assert.Nil(t, err)
+	assert.Equal(t, []SortField{
+		{Field: "created_at", Desc: true},
+		{Field: "name"},
+		{Field: "id"},
+		{Field: "age", Desc: true},
+		{Field: "Score"},
+	}, sorts)
+
+	for _, spec := range []string{"", ",", "id desc limit", "-id desc", "id;drop", "rand()", "id sideways"} {
+		_, err := ParseSort(spec)
+		assert.True(t, errors.Is(err, ErrInvalidSort), spec)
+	}
+}
+
+func TestWhitelist(t *testing.T) {
+	db := newTestDB(t, &sortUser{})
+
+	w, err := NewWhitelist(db, &[]sortUser{})
+	assert.Nil(t, err)
+	for name, column := range map[string]string{
+		"id": "id", "ID": "id", "createdAt": "created_at", "CreatedAt": "created_at", "created_at": "created_at", "password": "password",
+	} {
+		c, err := w.Column(name)
+		assert.Nil(t, err)
+		assert.Equal(t, column, c)
+	}
+	_, err = w.Column("name; drop table users")
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+
+	orders, err := w.Sort("-createdAt,name")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(orders))
+	assert.Equal(t, "created_at", orders[0].Column.Name)
+	assert.True(t, orders[0].Desc)
+
+	filters, err := w.Filters(map[string]interface{}{"createdAt >=": 1, "age > or": 18, "name": "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"created_at >=": 1, "age > or": 18, "name": "a"}, filters)
+	_, err = w.Filters(map[string]interface{}{"age regexp": 1})
+	assert.True(t, errors.Is(err, ErrOperatorNotAllowed))
+	_, err = w.Filters(map[string]interface{}{"age > xor": 1})
+	assert.True(t, errors.Is(err, ErrOperatorNotAllowed))
+	_, err = w.Filters(map[string]interface{}{"1=1 or": 1})
+	assert.True(t, errors.Is(err, ErrOperatorNotAllowed))
+
+	limited, err := NewWhitelist(db, &sortUser{}, "name", "createdAt")
+	assert.Nil(t, err)
+	_, err = limited.Column("password")
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+	c, err := limited.Column("created_at")
+	assert.Nil(t, err)
+	assert.Equal(t, "created_at", c)
+	limited.Allow("age", "age")
+	_, err = limited.Column("age")
+	assert.Nil(t, err)
+
+	_, err = NewWhitelist(db, &sortUser{}, "unknown")
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+}
+
+func TestRepositoryWhitelist(t *testing.T) {
+	db := newTestDB(t, &sortUser{})
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+	for i, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, repo.Create(&sortUser{Name: name, Age: 20 + i, CreatedAt: int64(3 - i)}))
+	}
+
+	users := make([]sortUser, 0)
+	assert.Nil(t, repo.FindWhereBatch(&users, map[string]interface{}{"age >=": 21}, 10, 0, "createdAt"))
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "c", users[0].Name)
+
+	users = make([]sortUser, 0)
+	assert.Nil(t, repo.FindWhereBatch(&users, nil, 10, 0, ""))
+	assert.Equal(t, "c", users[0].Name)
+
+	err := repo.FindWhereBatch(&users, nil, 10, 0, "(CASE WHEN 1=1 THEN name END)")
+	assert.True(t, errors.Is(err, ErrInvalidSort))
+	err = repo.FindWhereBatch(&users, nil, 10, 0, "-unknown")
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+	err = repo.FindWhere(&users, map[string]interface{}{"name = 'a' or 1": 1})
+	assert.NotNil(t, err)
+	assert.Equal(t, int64(0), repo.FindWhereCount(&sortUser{}, map[string]interface{}{"unknown": 1}))
+	assert.Equal(t, int64(3), repo.FindWhereCount(&sortUser{}, map[string]interface{}{"age >": 0}))
+
+	users = make([]sortUser, 0)
+	assert.Nil(t, repo.GetByField(&users, "name", "b"))
+	assert.Equal(t, 1, len(users))
+	err = repo.GetByField(&users, "1=1 OR name", "b")
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+
+	user := sortUser{}
+	assert.Nil(t, repo.GetOneByFields(&user, map[string]interface{}{"Name": "c"}))
+	assert.Equal(t, 22, user.Age)
+	err = repo.GetOneByFields(&user, map[string]interface{}{"name = name --": "c"})
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+
+	// 自定义白名单
+	w, err := NewWhitelist(db, &sortUser{}, "name")
+	assert.Nil(t, err)
+	repo.SetWhitelist(&sortUser{}, w)
+	err = repo.FindWhereBatch(&users, nil, 10, 0, "age")
+	assert.True(t, errors.Is(err, ErrFieldNotAllowed))
+	assert.Nil(t, repo.FindWhereBatch(&users, nil, 10, 0, "-name"))
+	// 默认的主键排序不受白名单限制
+	assert.Nil(t, repo.FindWhereBatch(&users, nil, 10, 0, ""))
+	assert.Equal(t, "c", users[0].Name)
+}
+
+type sortCode struct {
+	Code string `gorm:"primaryKey"`
+	Name string
+}
+
+type sortLog struct {
+	Message string
+}
+
+func TestRepositoryDefaultSort(t *testing.T) {
+	db := newTestDB(t, &sortCode{}, &sortLog{})
+	repo := NewGormRepository(db, logrus.New(), false, false, 0, "")
+	for _, code := range []string{"a", "c", "b"} {
+		assert.Nil(t, repo.Create(&sortCode{Code: code, Name: code}))
+		assert.Nil(t, repo.Create(&sortLog{Message: code}))
+	}
+
+	// 没有 id 字段时按主键倒序
+	codes := make([]sortCode, 0)
+	assert.Nil(t, repo.FindWhereBatch(&codes, nil, 10, 0, ""))
+	assert.Equal(t, []string{"c", "b", "a"}, []string{codes[0].Code, codes[1].Code, codes[2].Code})
+	codes = make([]sortCode, 0)
+	assert.Nil(t, repo.FindWhere(&codes, map[string]interface{}{"name !=": "x"}))
+	assert.Equal(t, "c", codes[0].Code)
+
+	// 没有主键时不排序
+	logs := make([]sortLog, 0)
+	assert.Nil(t, repo.FindWhereBatch(&logs, nil, 10, 0, ""))
+	assert.Equal(t, 3, len(logs))
+	logs = make([]sortLog, 0)
+	assert.Nil(t, repo.FindWhere(&logs, map[string]interface{}{"message !=": "x"}))
+	assert.Equal(t, 3, len(logs))
+}
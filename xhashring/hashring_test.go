@@ -25,3 +25,57 @@ func TestInitHashRing(t *testing.T) {
 		})
 	}
 }
+
+func TestRing(t *testing.T) {
+	ring := NewRing("db0", "db1", "db2")
+	if nodes := ring.Nodes(); len(nodes) != 3 || nodes[0] != "db0" {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	keys := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprintf("tenant_%d", i))
+	}
+	before := make(map[string]string, len(keys))
+	for _, key := range keys {
+		node, ok := ring.Node(key)
+		if !ok {
+			t.Fatalf("no node for %s", key)
+		}
+		before[key] = node
+	}
+
+	moves := ring.Moves(keys, "db3", 1)
+	if len(moves) == 0 || len(moves) == len(keys) {
+		t.Fatalf("unexpected moves %d", len(moves))
+	}
+	// 计算迁移计划不修改哈希环
+	if len(ring.Nodes()) != 3 {
+		t.Fatal("ring changed by Moves")
+	}
+
+	ring.Add("db3", 1)
+	moved := make(map[string]Move, len(moves))
+	for _, m := range moves {
+		if m.To != "db3" || m.From != before[m.Key] {
+			t.Fatalf("unexpected move %+v", m)
+		}
+		moved[m.Key] = m
+	}
+	for _, key := range keys {
+		node, _ := ring.Node(key)
+		if _, ok := moved[key]; ok {
+			if node != "db3" {
+				t.Fatalf("%s should move to db3, got %s", key, node)
+			}
+		} else if node != before[key] {
+			t.Fatalf("%s should stay on %s, got %s", key, before[key], node)
+		}
+	}
+
+	ring.Remove("db3")
+	for _, key := range keys {
+		if node, _ := ring.Node(key); node != before[key] {
+			t.Fatalf("%s should return to %s, got %s", key, before[key], node)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * @Date: 2026-10-19 15:02:11
+ * @LastEditTime: 2026-10-19 15:02:11
+ * @Description:
+ */
+package xhashring
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/serialx/hashring"
+)
+
+// Move 节点变化后需要迁移的 key
+type Move struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Ring 使用真实节点名的一致性哈希环, 并发安全
+type Ring struct {
+	mu      sync.RWMutex
+	ring    *hashring.HashRing
+	weights map[string]int
+}
+
+// NewRing 创建哈希环, 每个节点权重为 1
+func NewRing(nodes ...string) *Ring {
+	weights := make(map[string]int, len(nodes))
+	for _, node := range nodes {
+		weights[node] = 1
+	}
+	return NewWeightedRing(weights)
+}
+
+// NewWeightedRing 创建带权重的哈希环, 权重越大分配到的 key 越多
+func NewWeightedRing(weights map[string]int) *Ring {
+	copied := make(map[string]int, len(weights))
+	for node, weight := range weights {
+		if weight <= 0 {
+			weight = 1
+		}
+		copied[node] = weight
+	}
+	return &Ring{
+		ring:    hashring.NewWithWeights(copied),
+		weights: copied,
+	}
+}
+
+// Node 返回 key 所在的节点
+func (r *Ring) Node(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ring.GetNode(key)
+}
+
+// Nodes 返回全部节点, 按名称排序
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	nodes := make([]string, 0, len(r.weights))
+	for node := range r.weights {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Add 添加节点, 节点已存在时更新权重
+func (r *Ring) Add(node string, weight int) {
+	if weight <= 0 {
+		weight = 1
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.weights[node]; ok {
+		r.ring = r.ring.UpdateWeightedNode(node, weight)
+	} else {
+		r.ring = r.ring.AddWeightedNode(node, weight)
+	}
+	r.weights[node] = weight
+}
+
+// Remove 移除节点
+func (r *Ring) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ring = r.ring.RemoveNode(node)
+	delete(r.weights, node)
+}
+
+// Moves 返回添加节点后归属发生变化的 key, 不修改当前哈希环
+func (r *Ring) Moves(keys []string, node string, weight int) []Move {
+	r.mu.RLock()
+	weights := make(map[string]int, len(r.weights)+1)
+	for n, w := range r.weights {
+		weights[n] = w
+	}
+	r.mu.RUnlock()
+	next := NewWeightedRing(weights)
+	next.Add(node, weight)
+
+	res := make([]Move, 0)
+	for _, key := range keys {
+		from, _ := r.Node(key)
+		to, _ := next.Node(key)
+		if from != to {
+			res = append(res, Move{Key: key, From: from, To: to})
+		}
+	}
+	return res
+}
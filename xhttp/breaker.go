@@ -0,0 +1,292 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断中, 可通过 errors.Is 判断
+var ErrCircuitOpen = errors.New("xhttp: circuit breaker is open")
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+// ...
+const (
+	BreakerClosed   BreakerState = iota // 正常请求
+	BreakerOpen                         // 熔断, 直接返回错误
+	BreakerHalfOpen                     // 放行少量探测请求
+)
+
+// String ...
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOption 按 host 熔断, 零值字段使用默认值
+type BreakerOption struct {
+	Window              time.Duration                        // 统计窗口, 默认 1 分钟
+	MinRequests         int                                  // 窗口内请求数达到后才计算失败率, 默认 20
+	FailureRatio        float64                              // 失败率阈值, 默认 0.5
+	ConsecutiveFailures int                                  // 连续失败阈值, 默认 5
+	OpenTimeout         time.Duration                        // 熔断持续时间, 之后进入半开状态, 默认 30 秒
+	HalfOpenRequests    int                                  // 半开状态的探测请求数, 全部成功后恢复, 默认 1
+	IsFailure           func(resp *Response, err error) bool // 默认网络错误、429 与 5xx 为失败
+}
+
+// BreakerError 熔断期间的请求直接返回该错误
+type BreakerError struct {
+	Host  string
+	State BreakerState
+	Until time.Time // 预计进入半开状态的时间
+}
+
+// Error ...
+func (e *BreakerError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", ErrCircuitOpen, e.Host, e.State)
+}
+
+// Is ...
+func (e *BreakerError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// BreakerSnapshot 熔断器状态快照
+type BreakerSnapshot struct {
+	Host                string       `json:"host"`
+	State               BreakerState `json:"state"`
+	Requests            int          `json:"requests"`
+	Failures            int          `json:"failures"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            time.Time    `json:"opened_at"`
+}
+
+// Breakers 各 host 熔断器的状态, 未开启时为空
+func (t *Client) Breakers() []BreakerSnapshot {
+	if t.breakers == nil {
+		return nil
+	}
+	return t.breakers.snapshot()
+}
+
+// ----------------------------------------------------------------
+
+// breakerGroup ...
+type breakerGroup struct {
+	mu       sync.Mutex
+	option   BreakerOption
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+// newBreakerGroup ...
+func newBreakerGroup(o BreakerOption) *breakerGroup {
+	if o.Window <= 0 {
+		o.Window = time.Minute
+	}
+	if o.MinRequests <= 0 {
+		o.MinRequests = 20
+	}
+	if o.FailureRatio <= 0 {
+		o.FailureRatio = 0.5
+	}
+	if o.ConsecutiveFailures <= 0 {
+		o.ConsecutiveFailures = 5
+	}
+	if o.OpenTimeout <= 0 {
+		o.OpenTimeout = 30 * time.Second
+	}
+	if o.HalfOpenRequests <= 0 {
+		o.HalfOpenRequests = 1
+	}
+	if o.IsFailure == nil {
+		o.IsFailure = isFailure
+	}
+	return &breakerGroup{
+		option:   o,
+		breakers: make(map[string]*breaker),
+		now:      time.Now,
+	}
+}
+
+// get ...
+func (g *breakerGroup) get(host string) *breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.breakers[host]
+	if !ok {
+		b = &breaker{host: host, group: g, windowStart: g.now()}
+		g.breakers[host] = b
+	}
+	return b
+}
+
+// middleware 熔断包含全部重试, 按最终结果统计
+func (g *breakerGroup) middleware(next Doer) Doer {
+	return DoerFunc(func(req *http.Request) (*Response, error) {
+		b := g.get(req.URL.Host)
+		generation, err := b.allow()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := next.Do(req)
+		// 调用方取消的请求不计入统计
+		canceled := req.Context().Err() != nil || errors.Is(err, context.Canceled)
+		b.record(generation, !canceled, g.option.IsFailure(resp, err))
+		return resp, err
+	})
+}
+
+// snapshot ...
+func (g *breakerGroup) snapshot() []BreakerSnapshot {
+	g.mu.Lock()
+	list := make([]*breaker, 0, len(g.breakers))
+	for _, b := range g.breakers {
+		list = append(list, b)
+	}
+	g.mu.Unlock()
+	res := make([]BreakerSnapshot, 0, len(list))
+	for _, b := range list {
+		res = append(res, b.snapshot())
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Host < res[j].Host })
+	return res
+}
+
+// isFailure ...
+func isFailure(resp *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// ----------------------------------------------------------------
+
+// breaker 单个 host 的熔断器, 状态变化时 generation 加一, 忽略之前放行请求的结果
+type breaker struct {
+	mu          sync.Mutex
+	host        string
+	group       *breakerGroup
+	state       BreakerState
+	generation  int
+	windowStart time.Time
+	requests    int
+	failures    int
+	consecutive int
+	openedAt    time.Time
+	inflight    int // 半开状态已放行的请求
+	successes   int // 半开状态成功的请求
+}
+
+// allow ...
+func (b *breaker) allow() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.group.now()
+	o := &b.group.option
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= o.Window {
+			b.reset(now)
+		}
+	case BreakerOpen:
+		if now.Before(b.openedAt.Add(o.OpenTimeout)) {
+			return 0, &BreakerError{Host: b.host, State: b.state, Until: b.openedAt.Add(o.OpenTimeout)}
+		}
+		b.transition(BreakerHalfOpen, now)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.inflight >= o.HalfOpenRequests {
+			return 0, &BreakerError{Host: b.host, State: b.state}
+		}
+		b.inflight++
+	}
+	return b.generation, nil
+}
+
+// record counted 为 false 时只释放半开状态的名额
+func (b *breaker) record(generation int, counted, failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	now := b.group.now()
+	o := &b.group.option
+	switch b.state {
+	case BreakerClosed:
+		if !counted {
+			return
+		}
+		b.requests++
+		if !failure {
+			b.consecutive = 0
+			return
+		}
+		b.failures++
+		b.consecutive++
+		if b.consecutive >= o.ConsecutiveFailures ||
+			(b.requests >= o.MinRequests && float64(b.failures) >= o.FailureRatio*float64(b.requests)) {
+			b.transition(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		switch {
+		case !counted:
+			b.inflight--
+		case failure:
+			b.transition(BreakerOpen, now)
+		default:
+			b.successes++
+			if b.successes >= o.HalfOpenRequests {
+				b.transition(BreakerClosed, now)
+			}
+		}
+	}
+}
+
+// transition ...
+func (b *breaker) transition(state BreakerState, now time.Time) {
+	b.state = state
+	b.generation++
+	b.inflight, b.successes = 0, 0
+	switch state {
+	case BreakerOpen:
+		b.openedAt = now
+	case BreakerClosed:
+		b.reset(now)
+	}
+}
+
+// reset 开始新的统计窗口
+func (b *breaker) reset(now time.Time) {
+	b.windowStart = now
+	b.requests, b.failures, b.consecutive = 0, 0, 0
+}
+
+// snapshot ...
+func (b *breaker) snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerSnapshot{
+		Host:                b.host,
+		State:               b.state,
+		Requests:            b.requests,
+		Failures:            b.failures,
+		ConsecutiveFailures: b.consecutive,
+		OpenedAt:            b.openedAt,
+	}
+}
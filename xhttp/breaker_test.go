@@ -0,0 +1,108 @@
+package xhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock ...
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestBreakerConsecutive(t *testing.T) {
+	var status, count int32 = http.StatusServiceUnavailable, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	client := New(WithCircuitBreaker(BreakerOption{ConsecutiveFailures: 3, OpenTimeout: time.Minute}))
+	client.breakers.now = clock.Now
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ctx, server.URL, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	// 熔断后不再请求
+	_, err := client.Get(ctx, server.URL, nil)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	var e *BreakerError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, BreakerOpen, e.State)
+	assert.Equal(t, clock.now.Add(time.Minute), e.Until)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+
+	host := mustHost(server.URL)
+	snapshots := client.Breakers()
+	assert.Equal(t, 1, len(snapshots))
+	assert.Equal(t, host, snapshots[0].Host)
+	assert.Equal(t, "open", snapshots[0].State.String())
+
+	// 半开状态探测失败重新熔断
+	clock.now = clock.now.Add(time.Minute)
+	_, err = client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	_, err = client.Get(ctx, server.URL, nil)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	// 探测成功后恢复
+	atomic.StoreInt32(&status, http.StatusOK)
+	clock.now = clock.now.Add(time.Minute)
+	_, err = client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, BreakerClosed, client.Breakers()[0].State)
+	_, err = client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+}
+
+func TestBreakerRatio(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 交替失败, 不会连续失败
+		if atomic.AddInt32(&count, 1)%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client := New(WithCircuitBreaker(BreakerOption{MinRequests: 10, FailureRatio: 0.5, ConsecutiveFailures: 3}))
+	for i := 0; i < 9; i++ {
+		_, err := client.Get(ctx, server.URL, nil)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, BreakerClosed, client.Breakers()[0].State)
+	assert.Equal(t, 4, client.Breakers()[0].Failures)
+	client.Get(ctx, server.URL, nil)
+	assert.Equal(t, BreakerOpen, client.Breakers()[0].State)
+
+	// 窗口过期后重新统计
+	client = New(WithCircuitBreaker(BreakerOption{Window: time.Second, MinRequests: 2}))
+	clock := &fakeClock{now: time.Now()}
+	client.breakers.now = clock.Now
+	atomic.StoreInt32(&count, 1)
+	client.Get(ctx, server.URL, nil)
+	clock.now = clock.now.Add(2 * time.Second)
+	client.Get(ctx, server.URL, nil)
+	assert.Equal(t, 1, client.Breakers()[0].Requests)
+	assert.Nil(t, New().Breakers())
+}
+
+func mustHost(s string) string {
+	u, _ := url.Parse(s)
+	return u.Host
+}
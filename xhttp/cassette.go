@@ -0,0 +1,344 @@
+package xhttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+// ErrInteractionNotFound 回放时没有匹配的记录
+var ErrInteractionNotFound = errors.New("xhttp: cassette interaction not found")
+
+// CassetteMode 录制模式
+type CassetteMode int
+
+// ...
+const (
+	ModeReplay      CassetteMode = iota // 只回放, 没有匹配的记录时返回 ErrInteractionNotFound
+	ModeRecord                          // 发送真实请求并录制, Stop 时覆盖写入文件
+	ModePassthrough                     // 直接发送请求, 不读写文件
+)
+
+// Interaction 一次请求与响应
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest ...
+type CassetteRequest struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     string      `json:"body,omitempty"`
+	Encoding string      `json:"encoding,omitempty"` // 非 UTF-8 内容使用 base64
+}
+
+// CassetteResponse ...
+type CassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	Encoding   string      `json:"encoding,omitempty"`
+}
+
+// Cassette 录制文件, JSON 格式
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// LoadCassette ...
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("xhttp: cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save ...
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// ----------------------------------------------------------------
+
+// Matcher 判断请求与记录是否匹配, body 为请求体
+type Matcher func(req *http.Request, body []byte, recorded *CassetteRequest) bool
+
+// MatchMethod ...
+func MatchMethod(req *http.Request, body []byte, recorded *CassetteRequest) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL 完整地址, 包含 query
+func MatchURL(req *http.Request, body []byte, recorded *CassetteRequest) bool {
+	return req.URL.String() == recorded.URL
+}
+
+// MatchPath 只匹配路径与 query, 用于 httptest 等每次端口不同的服务
+func MatchPath(req *http.Request, body []byte, recorded *CassetteRequest) bool {
+	u, err := url.Parse(recorded.URL)
+	return err == nil && req.URL.RequestURI() == u.RequestURI()
+}
+
+// MatchBody ...
+func MatchBody(req *http.Request, body []byte, recorded *CassetteRequest) bool {
+	b, err := decodeCassetteBody(recorded.Body, recorded.Encoding)
+	return err == nil && bytes.Equal(body, b)
+}
+
+// MatchHeader 匹配指定的请求头
+func MatchHeader(keys ...string) Matcher {
+	return func(req *http.Request, body []byte, recorded *CassetteRequest) bool {
+		for _, k := range keys {
+			if req.Header.Get(k) != recorded.Header.Get(k) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ----------------------------------------------------------------
+
+// RecorderOption ...
+type RecorderOption struct {
+	Transport http.RoundTripper // 真实请求使用的 transport, 默认为 http.DefaultTransport
+	Matchers  []Matcher         // 默认为 MatchMethod 与 MatchURL
+	Redact    []string          // 录制时隐藏的请求头与响应头
+}
+
+// RecorderOptionFn ...
+type RecorderOptionFn func(*RecorderOption)
+
+// WithRecorderTransport ...
+func WithRecorderTransport(v http.RoundTripper) RecorderOptionFn {
+	return func(o *RecorderOption) {
+		o.Transport = v
+	}
+}
+
+// WithMatchers 替换默认的匹配规则
+func WithMatchers(v ...Matcher) RecorderOptionFn {
+	return func(o *RecorderOption) {
+		o.Matchers = v
+	}
+}
+
+// WithRecorderRedact 录制时隐藏的请求头与响应头, 如 Authorization、Set-Cookie
+func WithRecorderRedact(v ...string) RecorderOptionFn {
+	return func(o *RecorderOption) {
+		o.Redact = append(o.Redact, v...)
+	}
+}
+
+// Recorder 录制与回放的 http.RoundTripper
+//
+//	r, err := xhttp.NewRecorder("testdata/users.json", xhttp.ModeReplay)
+//	defer r.Stop()
+//	client := xhttp.New(xhttp.WithRoundTripper(r))
+type Recorder struct {
+	mu       sync.Mutex
+	path     string
+	mode     CassetteMode
+	option   *RecorderOption
+	cassette *Cassette
+	used     map[*Interaction]bool
+}
+
+// NewRecorder 回放模式下读取文件, 录制模式下从空记录开始
+func NewRecorder(path string, mode CassetteMode, options ...RecorderOptionFn) (*Recorder, error) {
+	o := &RecorderOption{
+		Transport: http.DefaultTransport,
+		Matchers:  []Matcher{MatchMethod, MatchURL},
+	}
+	for _, fn := range options {
+		fn(o)
+	}
+	r := &Recorder{
+		path:     path,
+		mode:     mode,
+		option:   o,
+		cassette: &Cassette{},
+		used:     make(map[*Interaction]bool),
+	}
+	if mode == ModeReplay {
+		c, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+	}
+	return r, nil
+}
+
+// RoundTrip ...
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch r.mode {
+	case ModeReplay:
+		return r.replay(req)
+	case ModeRecord:
+		return r.record(req)
+	}
+	return r.option.Transport.RoundTrip(req)
+}
+
+// Stop 录制模式下写入文件
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
+
+// replay 优先使用未回放过的记录, 全部回放过时重复使用最后一条
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched *Interaction
+	for _, i := range r.cassette.Interactions {
+		if !r.match(req, body, &i.Request) {
+			continue
+		}
+		matched = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if matched == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+	}
+	r.used[matched] = true
+	b, err := decodeCassetteBody(matched.Response.Body, matched.Response.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode:    matched.Response.StatusCode,
+		Status:        matched.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        matched.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
+
+// redact 复制 header 并隐藏 Redact 中的字段
+func (r *Recorder) redact(h http.Header) http.Header {
+	header := h.Clone()
+	for _, k := range r.option.Redact {
+		if header.Get(k) != "" {
+			header.Set(k, _curlRedacted)
+		}
+	}
+	return header
+}
+
+// record ...
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.option.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	i := &Interaction{
+		Request: CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.redact(req.Header),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     r.redact(resp.Header),
+		},
+	}
+	i.Request.Body, i.Request.Encoding = encodeCassetteBody(body)
+	i.Response.Body, i.Response.Encoding = encodeCassetteBody(b)
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// match ...
+func (r *Recorder) match(req *http.Request, body []byte, recorded *CassetteRequest) bool {
+	for _, m := range r.option.Matchers {
+		if !m(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// readRequestBody 读取后重新设置 Body, 不影响后续发送
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// encodeCassetteBody ...
+func encodeCassetteBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+// decodeCassetteBody ...
+func decodeCassetteBody(s, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,135 @@
+package xhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		switch r.URL.Path {
+		case "/binary":
+			w.Write([]byte{0xff, 0xfe, 0x00})
+		case "/echo":
+			b, _ := io.ReadAll(r.Body)
+			w.Write(b)
+		default:
+			w.Header().Set("X-Count", strconv.Itoa(int(n)))
+			w.Header().Set("Set-Cookie", "session=secret")
+			w.Write([]byte("count " + strconv.Itoa(int(n))))
+		}
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "recorder.json")
+
+	// 录制
+	recorder, err := NewRecorder(path, ModeRecord, WithRecorderRedact(HeaderKeyAuthorization, "Cookie", "Set-Cookie"))
+	assert.Nil(t, err)
+	client := New(WithRoundTripper(recorder))
+	header := http.Header{}
+	header.Set(HeaderKeyAuthorization, "Bearer token")
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ctx, server.URL+"/count?a=1", header)
+		assert.Nil(t, err)
+		assert.Equal(t, "count "+strconv.Itoa(i+1), resp.String())
+	}
+	resp, err := client.Get(ctx, server.URL+"/binary", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xff, 0xfe, 0x00}, resp.Bytes())
+	for _, body := range []string{`{"id":1}`, `{"id":2}`} {
+		resp, err = client.Post(ctx, server.URL+"/echo", nil, body)
+		assert.Nil(t, err)
+		assert.Equal(t, body, resp.String())
+	}
+	assert.Nil(t, recorder.Stop())
+	server.Close()
+
+	cassette, err := LoadCassette(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(cassette.Interactions))
+	assert.Equal(t, "***", cassette.Interactions[0].Request.Header.Get(HeaderKeyAuthorization))
+	assert.Equal(t, "***", cassette.Interactions[0].Response.Header.Get("Set-Cookie"))
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "Bearer token")
+	assert.NotContains(t, string(b), "session=secret")
+	assert.Equal(t, "base64", cassette.Interactions[2].Response.Encoding)
+
+	// 回放, 服务已关闭
+	recorder, err = NewRecorder(path, ModeReplay, WithMatchers(MatchMethod, MatchPath, MatchBody))
+	assert.Nil(t, err)
+	client = New(WithRoundTripper(recorder))
+	for _, expect := range []string{"count 1", "count 2", "count 2"} {
+		resp, err := client.Get(ctx, "http://127.0.0.1:1/count?a=1", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, expect, resp.String())
+		assert.Equal(t, expect[len(expect)-1:], resp.Header.Get("X-Count"))
+	}
+	resp, err = client.Get(ctx, "http://127.0.0.1:1/binary", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xff, 0xfe, 0x00}, resp.Bytes())
+	resp, err = client.Post(ctx, "http://127.0.0.1:1/echo", nil, `{"id":2}`)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":2}`, resp.String())
+
+	_, err = client.Post(ctx, "http://127.0.0.1:1/echo", nil, `{"id":3}`)
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+	_, err = client.Get(ctx, "http://127.0.0.1:1/count", nil)
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+	assert.Nil(t, recorder.Stop())
+
+	// 默认匹配完整地址
+	recorder, err = NewRecorder(path, ModeReplay)
+	assert.Nil(t, err)
+	_, err = New(WithRoundTripper(recorder)).Get(ctx, "http://127.0.0.1:1/count?a=1", nil)
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+}
+
+func TestRecorderHeaderMatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "header.json")
+	cassette := &Cassette{Interactions: []*Interaction{
+		{
+			Request:  CassetteRequest{Method: MethodGet, URL: "https://api.example.com/me", Header: http.Header{"X-Tenant": {"a"}}},
+			Response: CassetteResponse{StatusCode: http.StatusOK, Status: "200 OK", Body: "tenant a"},
+		},
+		{
+			Request:  CassetteRequest{Method: MethodGet, URL: "https://api.example.com/me", Header: http.Header{"X-Tenant": {"b"}}},
+			Response: CassetteResponse{StatusCode: http.StatusOK, Status: "200 OK", Body: "tenant b"},
+		},
+	}}
+	assert.Nil(t, cassette.Save(path))
+
+	recorder, err := NewRecorder(path, ModeReplay, WithMatchers(MatchMethod, MatchURL, MatchHeader("X-Tenant")))
+	assert.Nil(t, err)
+	client := New(WithRoundTripper(recorder))
+	header := http.Header{}
+	header.Set("X-Tenant", "b")
+	resp, err := client.Get(ctx, "https://api.example.com/me", header)
+	assert.Nil(t, err)
+	assert.Equal(t, "tenant b", resp.String())
+
+	_, err = NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay)
+	assert.NotNil(t, err)
+}
+
+func TestRecorderPassthrough(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passthrough.json")
+	recorder, err := NewRecorder(path, ModePassthrough)
+	assert.Nil(t, err)
+	resp, err := New(WithRoundTripper(recorder)).Get(ctx, prefix+"/get", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Nil(t, recorder.Stop())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
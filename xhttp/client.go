@@ -14,8 +14,9 @@ import (
 type Client struct {
 	*http.Client
 
-	option *Option
-	trace  *Trace
+	option   *Option
+	breakers *breakerGroup
+	limiters *limiterGroup
 }
 
 // Get ...
@@ -43,59 +44,99 @@ func (t *Client) Patch(ctx context.Context, url string, header http.Header) (*Re
 	return t.Do(ctx, MethodPatch, url, header, nil)
 }
 
+// Stream 不读取响应体, 调用方需要关闭 resp.Body
+func (t *Client) Stream(
+	ctx context.Context, method string, url string, header http.Header, data interface{},
+) (*Response, error) {
+	return t.Do(context.WithValue(ctx, streamKey{}, true), method, url, header, data)
+}
+
 // Do ...
 func (t *Client) Do(
 	ctx context.Context, method string, url string, header http.Header, data interface{},
 ) (*Response, error) {
-	defer func() {
-		if t.trace != nil {
-			t.trace.Finish()
-		}
-	}()
-	if header == nil {
-		header = http.Header{}
-		header.Set(HeaderKeyContentType, HeaderKeyContentTypeValueJSON)
-	}
 	// trace
+	var trace *Trace
 	if t.option.TraceEnable {
-		t.trace = new(Trace)
-		ctx = t.trace.WithClientTrace(ctx)
+		trace = new(Trace)
+		ctx = trace.WithClientTrace(ctx)
+		defer trace.Finish()
+	}
+	req, err := NewRequest(ctx, method, url, header, data)
+	if err != nil {
+		return nil, err
 	}
+	req.redact = t.option.CurlRedact
+	var doer Doer = DoerFunc(func(req *http.Request) (*Response, error) {
+		return t.send(req, trace)
+	})
+	if t.breakers != nil {
+		doer = t.breakers.middleware(doer)
+	}
+	if t.limiters != nil {
+		doer = t.limiters.middleware(doer)
+	}
+	for i := len(t.option.Middlewares) - 1; i >= 0; i-- {
+		doer = t.option.Middlewares[i](doer)
+	}
+	resp, err := doer.Do(req.Request)
+	if resp == nil && err == nil {
+		err = ErrNoResponse
+	}
+	if resp != nil {
+		resp.request = req
+	}
+	if t.option.CurlLogger != nil && (err != nil || (resp != nil && resp.StatusCode >= http.StatusBadRequest)) {
+		entry := t.option.CurlLogger.WithField("curl", req.Curl())
+		if err != nil {
+			entry.WithError(err).Warn("xhttp: request failed")
+		} else {
+			entry.WithField("status", resp.StatusCode).Warn("xhttp: request failed")
+		}
+	}
+	return resp, err
+}
+
+// send 发送请求并读取响应, 按 RetryPolicy 重试
+func (t *Client) send(req *http.Request, trace *Trace) (*Response, error) {
 	var (
 		resp *http.Response
 		err  error
 	)
-	var body io.Reader
-	m, ok := data.(map[string]interface{})
-	if ok && header.Get(HeaderKeyContentType) == HeaderKeyContentTypeValueFormData {
-		header, body = BuildFormData(header, m)
-	} else {
-		body = BuildReader(data, header.Get(HeaderKeyContentType))
-	}
-
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return nil, err
-	}
-	req.Header = header
 	// retry
-	for i := 0; i < t.option.RetryTimes; i++ {
+	for attempt := 1; ; attempt++ {
+		if trace != nil {
+			trace.RequestAttempt = attempt
+		}
 		resp, err = t.Client.Do(req)
-		if err == nil {
+		delay, retry := t.option.RetryPolicy.Retry(req, resp, err, attempt)
+		if !retry || !rewindBody(req) {
 			break
 		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
 	}
 	if err != nil {
 		return nil, err
 	}
-	reader := resp.Body
 	res := &Response{
 		Response: resp,
-		trace:    t.trace,
+		trace:    trace,
 	}
+	// stream 由调用方读取并关闭 Body
+	if isStream(req.Context()) {
+		return res, nil
+	}
+	defer resp.Body.Close()
+	reader := resp.Body
 	// gzip
 	if resp.Header.Get(HeaderKeyContentEncoding) == HeaderKeyContentEncodingValueGzip {
-		if _, ok := body.(*gzip.Reader); ok {
+		if _, ok := req.Body.(*gzip.Reader); ok {
 			reader, err = gzip.NewReader(reader)
 			if err != nil {
 				return nil, err
@@ -109,21 +150,38 @@ func (t *Client) Do(
 	return res, nil
 }
 
+// streamKey ...
+type streamKey struct{}
+
+// isStream ...
+func isStream(ctx context.Context) bool {
+	v, _ := ctx.Value(streamKey{}).(bool)
+	return v
+}
+
 // ----------------------------------------------------------------
 
 // New ...
 func New(options ...OptionFn) *Client {
-	config := defaultOption
-	for _, fn := range options {
-		fn(config)
-	}
+	config := newOption(options...)
 	cookieJar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
-	return &Client{
+	var transport http.RoundTripper = config.Transport
+	if config.RoundTripper != nil {
+		transport = config.RoundTripper
+	}
+	client := &Client{
 		Client: &http.Client{
 			Timeout:   config.RequestTimeout,
-			Transport: config.Transport,
+			Transport: transport,
 			Jar:       cookieJar,
 		},
 		option: config,
 	}
+	if config.Breaker != nil {
+		client.breakers = newBreakerGroup(*config.Breaker)
+	}
+	if config.RateLimit != nil || len(config.HostRateLimits) > 0 {
+		client.limiters = newLimiterGroup(config.RateLimit, config.HostRateLimits)
+	}
+	return client
 }
@@ -1,10 +1,15 @@
 package xhttp
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,10 +18,89 @@ import (
 )
 
 var (
-	prefix = "https://httpbin.org"
+	prefix string
 	ctx    = context.Background()
 )
 
+func TestMain(m *testing.M) {
+	server := httptest.NewServer(newHTTPBin())
+	prefix = server.URL
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+// newHTTPBin 本地实现 httpbin.org 的部分接口, 测试不依赖网络
+func newHTTPBin() http.Handler {
+	mux := http.NewServeMux()
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		res := map[string]interface{}{
+			"method":  r.Method,
+			"url":     r.URL.String(),
+			"headers": flatten(r.Header),
+			"args":    flatten(r.URL.Query()),
+		}
+		contentType := r.Header.Get(HeaderKeyContentType)
+		switch {
+		case strings.HasPrefix(contentType, HeaderKeyContentTypeValueFormData):
+			r.ParseMultipartForm(1 << 20)
+			files := map[string]string{}
+			for k, fhs := range r.MultipartForm.File {
+				f, _ := fhs[0].Open()
+				b, _ := io.ReadAll(f)
+				f.Close()
+				files[k] = string(b)
+			}
+			res["form"], res["files"] = flatten(r.MultipartForm.Value), files
+		case contentType == HeaderKeyContentTypeValueForm:
+			r.ParseForm()
+			res["form"] = flatten(r.PostForm)
+		default:
+			b, _ := io.ReadAll(r.Body)
+			res["data"] = string(b)
+			var v interface{}
+			if xjson.Decode(string(b), &v) == nil {
+				res["json"] = v
+			}
+		}
+		w.Header().Set(HeaderKeyContentType, HeaderKeyContentTypeValueJSON)
+		w.Write([]byte(xjson.Encode(res)))
+	}
+	for _, path := range []string{"/get", "/post", "/put", "/delete", "/patch"} {
+		mux.HandleFunc(path, echo)
+	}
+	mux.HandleFunc("/gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderKeyContentType, HeaderKeyContentTypeValueJSON)
+		w.Header().Set(HeaderKeyContentEncoding, HeaderKeyContentEncodingValueGzip)
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte(`{"gzipped": true, "method": "` + r.Method + `"}`))
+		gw.Close()
+	})
+	mux.HandleFunc("/cookies/set/", func(w http.ResponseWriter, r *http.Request) {
+		kv := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/cookies/set/"), "/", 2)
+		http.SetCookie(w, &http.Cookie{Name: kv[0], Value: kv[1], Path: "/"})
+		http.Redirect(w, r, "/cookies", http.StatusFound)
+	})
+	mux.HandleFunc("/cookies", func(w http.ResponseWriter, r *http.Request) {
+		cookies := map[string]string{}
+		for _, c := range r.Cookies() {
+			cookies[c.Name] = c.Value
+		}
+		w.Header().Set(HeaderKeyContentType, HeaderKeyContentTypeValueJSON)
+		w.Write([]byte(xjson.Encode(map[string]interface{}{"cookies": cookies})))
+	})
+	return mux
+}
+
+// flatten ...
+func flatten(values map[string][]string) map[string]string {
+	res := make(map[string]string, len(values))
+	for k, v := range values {
+		res[k] = strings.Join(v, ",")
+	}
+	return res
+}
+
 func TestRequest(t *testing.T) {
 	client := New()
 
@@ -53,6 +137,7 @@ func TestPost(t *testing.T) {
 	assert.Nil(t, err1)
 	if resp1 != nil {
 		fmt.Println(xjson.Pretty(resp1.String()))
+		assert.Equal(t, "brick", xjson.New(resp1.String()).Key("json").Key("name").ToString())
 	}
 	/*
 		{
@@ -84,6 +169,7 @@ func TestPost(t *testing.T) {
 	assert.Nil(t, err2)
 	if resp2 != nil {
 		fmt.Println(xjson.Pretty(resp2.String()))
+		assert.Equal(t, "1", xjson.New(resp2.String()).Key("form").Key("number").ToString())
 	}
 	/*
 		{
@@ -119,6 +205,9 @@ func TestUploadFile(t *testing.T) {
 	assert.Nil(t, err3)
 	if resp3 != nil {
 		fmt.Println(xjson.Pretty(resp3.String()))
+		readme, _ := os.ReadFile("./README.md")
+		assert.Equal(t, string(readme), xjson.New(resp3.String()).Key("files").Key("file").ToString())
+		assert.Equal(t, "brick", xjson.New(resp3.String()).Key("form").Key("name").ToString())
 	}
 	/*
 		{
@@ -166,6 +255,7 @@ func TestCookies(t *testing.T) {
 	if resp != nil {
 		fmt.Println(xjson.Pretty(resp.Header))
 		fmt.Println(xjson.Pretty(resp.String()))
+		assert.Equal(t, "world", xjson.New(resp.String()).Key("cookies").Key("hello").ToString())
 	}
 	/*
 		{
@@ -189,6 +279,7 @@ func TestGzip(t *testing.T) {
 	assert.Nil(t, err)
 	if resp != nil {
 		fmt.Println(xjson.Pretty(resp.String()))
+		assert.Equal(t, "true", xjson.New(resp.String()).Key("gzipped").ToString())
 	}
 }
 
@@ -25,6 +25,10 @@ const (
 	HeaderKeyLocation        = "Location"
 	HeaderKeyAuthorization   = "Authorization"
 	HeaderKeyAcceptEncoding  = "Accept-Encoding"
+	HeaderKeyRetryAfter      = "Retry-After"
+	HeaderKeyIdempotencyKey  = "Idempotency-Key"
+	HeaderKeyRange           = "Range"
+	HeaderKeyContentRange    = "Content-Range"
 
 	HeaderKeyContentTypeValueJSON     = "application/json"
 	HeaderKeyContentTypeValueForm     = "application/x-www-form-urlencoded"
@@ -0,0 +1,116 @@
+package xhttp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/falcolee/xutils/xfile"
+)
+
+// _curlRedacted 隐藏后的请求头值
+const _curlRedacted = "***"
+
+// Curl 导出 curl 命令, multipart 文件以 @path 引用
+//
+//	curl -X POST 'https://httpbin.org/post' -H 'Content-Type: application/json' --data-raw '{"name":"brick"}'
+func (t *Request) Curl() string {
+	parts := []string{"curl"}
+	if t.Method != MethodGet {
+		parts = append(parts, "-X", t.Method)
+	}
+	parts = append(parts, shellQuote(t.URL.String()))
+
+	multipart := strings.HasPrefix(t.Header.Get(HeaderKeyContentType), HeaderKeyContentTypeValueFormData)
+	compressed := false
+	keys := make([]string, 0, len(t.Header))
+	for k := range t.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch {
+		case k == HeaderKeyContentLength:
+			continue
+		case k == HeaderKeyContentType && multipart:
+			// boundary 由 curl 生成
+			continue
+		case k == HeaderKeyAcceptEncoding && isCompressed(t.Header.Get(k)):
+			compressed = true
+			continue
+		}
+		for _, v := range t.Header[k] {
+			if t.redacted(k) {
+				v = _curlRedacted
+			}
+			parts = append(parts, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	if m, ok := t.data.(map[string]interface{}); ok && multipart {
+		fields := make([]string, 0, len(m))
+		for k := range m {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+		for _, k := range fields {
+			v := fmt.Sprint(m[k])
+			if strings.HasPrefix(v, "@") && xfile.IsExist(strings.TrimPrefix(v, "@")) {
+				parts = append(parts, "-F", shellQuote(k+"="+v))
+			} else if strings.HasPrefix(v, "@") || strings.HasPrefix(v, "<") {
+				parts = append(parts, "--form-string", shellQuote(k+"="+v))
+			} else {
+				parts = append(parts, "-F", shellQuote(k+"="+v))
+			}
+		}
+	} else if body, ok := t.body(); ok && t.data != nil {
+		parts = append(parts, "--data-raw", shellQuote(body))
+	}
+	if compressed {
+		parts = append(parts, "--compressed")
+	}
+	return strings.Join(parts, " ")
+}
+
+// body 通过 GetBody 读取请求体, 不影响请求本身
+func (t *Request) body() (string, bool) {
+	if t.GetBody == nil {
+		return "", false
+	}
+	r, err := t.GetBody()
+	if err != nil {
+		return "", false
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// redacted ...
+func (t *Request) redacted(key string) bool {
+	for _, v := range t.redact {
+		if strings.EqualFold(v, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompressed ...
+func isCompressed(encoding string) bool {
+	for _, v := range []string{"gzip", "deflate", "br"} {
+		if strings.Contains(encoding, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote 单引号包裹, 内部的单引号先闭合再转义
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,69 @@
+package xhttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestCurl(t *testing.T) {
+	req, err := NewRequest(ctx, MethodPost, "https://httpbin.org/post?a=1", nil, map[string]interface{}{
+		"name": "it's",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X POST 'https://httpbin.org/post?a=1' -H 'Content-Type: application/json' --data-raw '{"name":"it'\''s"}'`, req.Curl())
+	// 导出后请求体仍可发送
+	b, _ := req.body()
+	assert.Equal(t, `{"name":"it's"}`, b)
+
+	header := http.Header{}
+	header.Set(HeaderKeyContentType, HeaderKeyContentTypeValueForm)
+	header.Set(HeaderKeyAuthorization, "Bearer token")
+	header.Set(HeaderKeyAcceptEncoding, "gzip, deflate")
+	req, err = NewRequest(ctx, MethodPost, "https://httpbin.org/post", header, map[string]interface{}{"a": 1, "b": "x y"})
+	assert.Nil(t, err)
+	req.Redact("authorization")
+	assert.Equal(t, `curl -X POST 'https://httpbin.org/post' -H 'Authorization: ***' -H 'Content-Type: application/x-www-form-urlencoded' --data-raw 'a=1&b=x+y' --compressed`, req.Curl())
+
+	header = http.Header{}
+	header.Set(HeaderKeyContentType, HeaderKeyContentTypeValueFormData)
+	req, err = NewRequest(ctx, MethodPost, "https://httpbin.org/post", header, map[string]interface{}{
+		"file": "@./README.md",
+		"name": "brick",
+		"at":   "@nobody",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `curl -X POST 'https://httpbin.org/post' --form-string 'at=@nobody' -F 'file=@./README.md' -F 'name=brick'`, req.Curl())
+	// 传入的 header 不被修改
+	assert.Equal(t, HeaderKeyContentTypeValueFormData, header.Get(HeaderKeyContentType))
+
+	req, err = NewRequest(ctx, MethodGet, "https://httpbin.org/get", http.Header{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `curl 'https://httpbin.org/get'`, req.Curl())
+}
+
+func TestResponseCurl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	client := New(WithCurlRedact(HeaderKeyAuthorization), WithCurlDebug(logger))
+
+	header := http.Header{}
+	header.Set(HeaderKeyAuthorization, "Bearer token")
+	resp, err := client.Delete(ctx, server.URL+"/items/1", header)
+	assert.Nil(t, err)
+	assert.Equal(t, "curl -X DELETE '"+server.URL+"/items/1' -H 'Authorization: ***'", resp.Curl())
+	assert.True(t, strings.Contains(buf.String(), "status=500"))
+	assert.False(t, strings.Contains(buf.String(), "Bearer token"))
+	assert.Equal(t, "", (&Response{}).Curl())
+}
@@ -0,0 +1,90 @@
+package xhttp
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/falcolee/xutils/xconvert"
+)
+
+// GetJSON GET 请求并解码响应, client 为空时使用 New()
+//
+//	user, err := xhttp.GetJSON[User](ctx, client, url, nil)
+func GetJSON[T any](ctx context.Context, client *Client, url string, header http.Header) (T, error) {
+	return DoJSON[T](ctx, client, MethodGet, url, header, nil)
+}
+
+// PostJSON POST 请求并解码响应
+func PostJSON[T any](ctx context.Context, client *Client, url string, header http.Header, data interface{}) (T, error) {
+	return DoJSON[T](ctx, client, MethodPost, url, header, data)
+}
+
+// DoJSON 请求并按 Content-Type 解码响应, 非 2xx 时返回 *StatusError
+func DoJSON[T any](
+	ctx context.Context, client *Client, method string, url string, header http.Header, data interface{},
+) (T, error) {
+	var res T
+	if client == nil {
+		client = New()
+	}
+	if header == nil {
+		header = http.Header{}
+		header.Set(HeaderKeyContentType, HeaderKeyContentTypeValueJSON)
+	} else {
+		header = header.Clone()
+	}
+	if header.Get(HeaderKeyAccept) == "" {
+		header.Set(HeaderKeyAccept, HeaderKeyContentTypeValueJSON)
+	}
+	resp, err := client.Do(ctx, method, url, header, data)
+	if err != nil {
+		return res, err
+	}
+	if err := resp.Err(); err != nil {
+		return res, err
+	}
+	err = resp.Decode(&res)
+	return res, err
+}
+
+// Decode 按 Content-Type 解码响应体, 支持 JSON、XML 与表单, 未指定时按 JSON 解码
+//
+// XML 解码到 map 时使用 xconvert.XML2Object, 表单按字段名称通过 JSON 复制到 v
+func (t *Response) Decode(v interface{}) error {
+	if len(t.body) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(t.Header.Get(HeaderKeyContentType))
+	switch {
+	case mediaType == "" || strings.HasSuffix(mediaType, "json"):
+		return json.Unmarshal(t.body, v)
+	case strings.HasSuffix(mediaType, "xml"):
+		if !isMap(v) {
+			return xml.Unmarshal(t.body, v)
+		}
+		m, err := xconvert.XML2Object(string(t.body))
+		if err != nil {
+			return err
+		}
+		return xconvert.CopyStructByJSON(m, v)
+	case mediaType == HeaderKeyContentTypeValueForm:
+		return xconvert.CopyStructByJSON(ParseQuery(string(t.body)), v)
+	}
+	return fmt.Errorf("xhttp: unsupported content type %q", mediaType)
+}
+
+// isMap encoding/xml 不支持解码到 map
+func isMap(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	return rv.Kind() == reflect.Map
+}
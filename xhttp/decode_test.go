@@ -0,0 +1,100 @@
+package xhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type decodeUser struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+func newDecodeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set(HeaderKeyContentType, "application/json; charset=utf-8")
+			w.Write([]byte(`{"id":1,"name":"` + r.Header.Get(HeaderKeyAccept) + `"}`))
+		case "/xml":
+			w.Header().Set(HeaderKeyContentType, "application/xml")
+			w.Write([]byte(`<xml><id>2</id><name>brick</name></xml>`))
+		case "/form":
+			w.Header().Set(HeaderKeyContentType, HeaderKeyContentTypeValueForm)
+			w.Write([]byte(`name=brick&id=3`))
+		case "/echo":
+			w.Header().Set(HeaderKeyContentType, "application/problem+json")
+			b := make([]byte, r.ContentLength)
+			r.Body.Read(b)
+			w.Write(b)
+		case "/text":
+			w.Header().Set(HeaderKeyContentType, "text/plain")
+			w.Write([]byte("ok"))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("X-Reason", "missing")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(strings.Repeat("x", 1000)))
+		}
+	}))
+}
+
+func TestDoJSON(t *testing.T) {
+	server := newDecodeServer()
+	defer server.Close()
+	client := New()
+
+	user, err := GetJSON[decodeUser](ctx, client, server.URL+"/json", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, decodeUser{ID: 1, Name: HeaderKeyContentTypeValueJSON}, user)
+
+	user, err = PostJSON[decodeUser](ctx, nil, server.URL+"/echo", nil, decodeUser{ID: 9, Name: "echo"})
+	assert.Nil(t, err)
+	assert.Equal(t, decodeUser{ID: 9, Name: "echo"}, user)
+
+	user, err = GetJSON[decodeUser](ctx, client, server.URL+"/xml", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, decodeUser{ID: 2, Name: "brick"}, user)
+
+	m, err := GetJSON[map[string]string](ctx, client, server.URL+"/xml", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"id": "2", "name": "brick"}, m)
+
+	m, err = GetJSON[map[string]string](ctx, client, server.URL+"/form", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "3", m["id"])
+
+	p, err := GetJSON[*decodeUser](ctx, client, server.URL+"/empty", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, p)
+
+	_, err = GetJSON[decodeUser](ctx, client, server.URL+"/text", nil)
+	assert.NotNil(t, err)
+}
+
+func TestStatusError(t *testing.T) {
+	server := newDecodeServer()
+	defer server.Close()
+
+	_, err := GetJSON[decodeUser](ctx, nil, server.URL+"/missing", nil)
+	var e *StatusError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, http.StatusNotFound, e.StatusCode)
+	assert.Equal(t, MethodGet, e.Method)
+	assert.Equal(t, "missing", e.Header.Get("X-Reason"))
+	assert.Equal(t, _statusErrorBody, len(e.Body))
+	assert.True(t, strings.HasPrefix(e.Error(), "xhttp: GET "+server.URL+"/missing: 404 Not Found: xxx"))
+
+	// stream 响应读取片段
+	resp, err := New().Stream(ctx, MethodGet, server.URL+"/missing", nil, nil)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.True(t, errors.As(resp.Err(), &e))
+	assert.Equal(t, _statusErrorBody, len(e.Body))
+}
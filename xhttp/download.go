@@ -0,0 +1,239 @@
+package xhttp
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/falcolee/xutils/xcli/xloading"
+)
+
+// ErrChecksum 下载文件的校验和不一致
+var ErrChecksum = errors.New("xhttp: checksum mismatch")
+
+// DownloadOption ...
+type DownloadOption struct {
+	Header   http.Header                // 请求头
+	Resume   bool                       // 存在临时文件时使用 Range 断点续传
+	Hash     func() hash.Hash           // 校验和算法, 如 sha256.New
+	Checksum string                     // 预期的校验和, 十六进制
+	Progress func(written, total int64) // 进度回调, total 未知时为 -1
+	Bar      string                     // 终端进度条的消息, 为空时不显示
+}
+
+// DownloadOptionFn ...
+type DownloadOptionFn func(*DownloadOption)
+
+// WithDownloadHeader ...
+func WithDownloadHeader(v http.Header) DownloadOptionFn {
+	return func(o *DownloadOption) {
+		o.Header = v
+	}
+}
+
+// WithResume 是否断点续传, 默认开启
+func WithResume(v bool) DownloadOptionFn {
+	return func(o *DownloadOption) {
+		o.Resume = v
+	}
+}
+
+// WithChecksum 下载完成后校验, 不一致时删除临时文件
+func WithChecksum(fn func() hash.Hash, expect string) DownloadOptionFn {
+	return func(o *DownloadOption) {
+		o.Hash = fn
+		o.Checksum = expect
+	}
+}
+
+// WithProgress 进度回调
+func WithProgress(fn func(written, total int64)) DownloadOptionFn {
+	return func(o *DownloadOption) {
+		o.Progress = fn
+	}
+}
+
+// WithProgressBar 使用 xloading 进度条输出到终端
+func WithProgressBar(message string) DownloadOptionFn {
+	return func(o *DownloadOption) {
+		o.Bar = message
+	}
+}
+
+// newDownloadOption ...
+func newDownloadOption(options ...DownloadOptionFn) *DownloadOption {
+	o := &DownloadOption{
+		Resume: true,
+	}
+	for _, fn := range options {
+		fn(o)
+	}
+	return o
+}
+
+// ----------------------------------------------------------------
+
+// Download 使用默认 Client 下载
+func Download(ctx context.Context, url, dst string, options ...DownloadOptionFn) error {
+	return New().Download(ctx, url, dst, options...)
+}
+
+// Download 下载到 dst.download 临时文件, 完成并校验后重命名为 dst
+//
+//	err := client.Download(ctx, url, "./data/app.tar.gz",
+//	    xhttp.WithChecksum(sha256.New, "9f86d0..."),
+//	    xhttp.WithProgressBar("app.tar.gz"),
+//	)
+func (t *Client) Download(ctx context.Context, url, dst string, options ...DownloadOptionFn) (err error) {
+	o := newDownloadOption(options...)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".download"
+	var offset int64
+	if info, err := os.Stat(tmp); err == nil && o.Resume {
+		offset = info.Size()
+	}
+	header := http.Header{}
+	for k, v := range o.Header {
+		header[k] = v
+	}
+	if offset > 0 {
+		header.Set(HeaderKeyRange, fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := t.Stream(ctx, MethodGet, url, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flag, total := os.O_CREATE|os.O_WRONLY, int64(-1)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, size, ok := parseContentRange(resp.Header.Get(HeaderKeyContentRange))
+		if !ok || start != offset {
+			return fmt.Errorf("xhttp: download %s: unexpected content range %q", url, resp.Header.Get(HeaderKeyContentRange))
+		}
+		flag, total = flag|os.O_APPEND, size
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// 临时文件已经完整, 上次在重命名前中断
+		if _, size, ok := parseContentRange(resp.Header.Get(HeaderKeyContentRange)); !ok || size != offset {
+			os.Remove(tmp)
+			return fmt.Errorf("xhttp: download %s: %s", url, resp.Status)
+		}
+		return finishDownload(tmp, dst, o)
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		// 服务端不支持 Range 时重新下载
+		offset, flag = 0, flag|os.O_TRUNC
+	default:
+		return newStatusError(resp)
+	}
+	if total < 0 && resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	f, err := os.OpenFile(tmp, flag, 0644)
+	if err != nil {
+		return err
+	}
+	w := &progressWriter{written: offset, total: total, fn: o.Progress}
+	if o.Bar != "" {
+		w.bar = xloading.NewProgress(total, o.Bar)
+		defer func() {
+			if err != nil {
+				w.bar.Fail()
+			} else {
+				w.bar.Success()
+			}
+		}()
+	}
+	w.report()
+	// 中断时保留临时文件用于续传
+	_, err = io.Copy(io.MultiWriter(f, w), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return finishDownload(tmp, dst, o)
+}
+
+// finishDownload 校验后原子重命名
+func finishDownload(tmp, dst string, o *DownloadOption) error {
+	if o.Hash != nil {
+		f, err := os.Open(tmp)
+		if err != nil {
+			return err
+		}
+		h := o.Hash()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, o.Checksum) {
+			os.Remove(tmp)
+			return fmt.Errorf("%w: expect %s, got %s", ErrChecksum, o.Checksum, sum)
+		}
+	}
+	return os.Rename(tmp, dst)
+}
+
+// parseContentRange bytes 100-199/1000 或 bytes */1000, 总大小未知时为 -1
+func parseContentRange(v string) (int64, int64, bool) {
+	v = strings.TrimPrefix(v, "bytes ")
+	i := strings.Index(v, "/")
+	if i < 0 {
+		return 0, 0, false
+	}
+	total := int64(-1)
+	if s := v[i+1:]; s != "*" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total = n
+	}
+	if v[:i] == "*" {
+		return 0, total, true
+	}
+	start, err := strconv.ParseInt(strings.SplitN(v[:i], "-", 2)[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// progressWriter ...
+type progressWriter struct {
+	written int64
+	total   int64
+	fn      func(written, total int64)
+	bar     *xloading.Progress
+}
+
+// Write ...
+func (t *progressWriter) Write(p []byte) (int, error) {
+	t.written += int64(len(p))
+	t.report()
+	return len(p), nil
+}
+
+// report ...
+func (t *progressWriter) report() {
+	if t.fn != nil {
+		t.fn(t.written, t.total)
+	}
+	if t.bar != nil {
+		t.bar.Set(t.written)
+	}
+}
@@ -0,0 +1,123 @@
+package xhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 1024)))
+	}))
+	defer server.Close()
+
+	resp, err := New().Stream(ctx, MethodGet, server.URL, nil, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, resp.Bytes())
+	b, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, 1024, len(b))
+	assert.Nil(t, resp.Body.Close())
+}
+
+func TestDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(content)
+	var ranges int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderKeyRange) != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+		http.ServeContent(w, r, "data.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dst := filepath.Join(t.TempDir(), "sub", "data.txt")
+	var written, total int64
+	err := Download(ctx, server.URL, dst,
+		WithChecksum(sha256.New, hex.EncodeToString(sum[:])),
+		WithProgress(func(w, t int64) { written, total = w, t }),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(content)), written)
+	assert.Equal(t, int64(len(content)), total)
+	b, _ := os.ReadFile(dst)
+	assert.Equal(t, content, b)
+	_, err = os.Stat(dst + ".download")
+	assert.True(t, os.IsNotExist(err))
+
+	// 断点续传
+	assert.Nil(t, os.WriteFile(dst+".download", content[:300], 0644))
+	var first int64 = -1
+	err = New().Download(ctx, server.URL, dst, WithProgress(func(w, t int64) {
+		if first < 0 {
+			first = w
+		}
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(300), first)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&ranges))
+	b, _ = os.ReadFile(dst)
+	assert.Equal(t, content, b)
+
+	// 临时文件已完整
+	assert.Nil(t, os.WriteFile(dst+".download", content, 0644))
+	assert.Nil(t, Download(ctx, server.URL, dst))
+	b, _ = os.ReadFile(dst)
+	assert.Equal(t, content, b)
+
+	// 校验失败删除临时文件
+	err = Download(ctx, server.URL, dst, WithChecksum(sha256.New, "00"), WithProgressBar("data.txt"))
+	assert.True(t, errors.Is(err, ErrChecksum))
+	_, err = os.Stat(dst + ".download")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadNoRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "abc" || r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("full content"))
+	}))
+	defer server.Close()
+
+	dst := filepath.Join(t.TempDir(), "data.txt")
+	assert.Nil(t, os.WriteFile(dst+".download", []byte("stale"), 0644))
+	header := WithDownloadHeader(http.Header{"X-Token": {"abc"}})
+	assert.Nil(t, Download(ctx, server.URL, dst, header))
+	b, _ := os.ReadFile(dst)
+	assert.Equal(t, "full content", string(b))
+
+	assert.NotNil(t, Download(ctx, server.URL+"/missing", dst, header))
+	assert.NotNil(t, Download(ctx, server.URL, dst))
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, total, ok := parseContentRange("bytes 100-199/1000")
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), start)
+	assert.Equal(t, int64(1000), total)
+	_, total, ok = parseContentRange("bytes */1000")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1000), total)
+	_, total, ok = parseContentRange("bytes 0-9/*")
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1), total)
+	_, _, ok = parseContentRange("invalid")
+	assert.False(t, ok)
+}
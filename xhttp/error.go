@@ -0,0 +1,61 @@
+package xhttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// _statusErrorBody StatusError 保留的响应体长度
+const _statusErrorBody = 512
+
+// StatusError 非 2xx 响应
+//
+//	var e *xhttp.StatusError
+//	if errors.As(err, &e) && e.StatusCode == http.StatusNotFound { ... }
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte // 响应体的前 512 字节
+}
+
+// Error ...
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("xhttp: %s %s: %s", e.Method, e.URL, e.Status)
+	if len(e.Body) > 0 {
+		msg += ": " + string(e.Body)
+	}
+	return msg
+}
+
+// Err 非 2xx 时返回 *StatusError
+func (t *Response) Err() error {
+	if t.StatusCode >= 200 && t.StatusCode < 300 {
+		return nil
+	}
+	return newStatusError(t)
+}
+
+// newStatusError stream 响应从 Body 中读取片段
+func newStatusError(resp *Response) *StatusError {
+	e := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+	if resp.Request != nil {
+		e.Method, e.URL = resp.Request.Method, resp.Request.URL.String()
+	}
+	body := resp.body
+	if body == nil && resp.Body != nil {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, _statusErrorBody))
+	}
+	if len(body) > _statusErrorBody {
+		body = body[:_statusErrorBody]
+	}
+	e.Body = body
+	return e
+}
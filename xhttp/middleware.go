@@ -0,0 +1,99 @@
+package xhttp
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/falcolee/xutils/xgen"
+)
+
+// ErrNoResponse 中间件既没有返回响应也没有返回错误
+var ErrNoResponse = errors.New("xhttp: middleware returned no response")
+
+// Doer 发送请求并返回读取完成的响应
+type Doer interface {
+	Do(req *http.Request) (*Response, error)
+}
+
+// DoerFunc ...
+type DoerFunc func(req *http.Request) (*Response, error)
+
+// Do ...
+func (f DoerFunc) Do(req *http.Request) (*Response, error) {
+	return f(req)
+}
+
+// Middleware 请求中间件, 按注册顺序由外到内执行, 包含全部重试
+//
+//	xhttp.New(xhttp.WithMiddleware(func(next xhttp.Doer) xhttp.Doer {
+//	    return xhttp.DoerFunc(func(req *http.Request) (*xhttp.Response, error) {
+//	        req.Header.Set("X-Sign", sign(req))
+//	        return next.Do(req)
+//	    })
+//	}))
+type Middleware func(next Doer) Doer
+
+// HeaderKeyRequestID ...
+const HeaderKeyRequestID = "X-Request-Id"
+
+// RequestID 请求头中没有请求 ID 时生成, generator 为空时使用 UUID
+func RequestID(generator func() string) Middleware {
+	if generator == nil {
+		generator = xgen.UUID
+	}
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*Response, error) {
+			if req.Header.Get(HeaderKeyRequestID) == "" {
+				req.Header.Set(HeaderKeyRequestID, generator())
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// StaticHeader 注入固定的请求头, 请求中已有的字段不覆盖
+func StaticHeader(header http.Header) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*Response, error) {
+			for k, v := range header {
+				if _, ok := req.Header[http.CanonicalHeaderKey(k)]; !ok {
+					req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+				}
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// Logger 记录请求方法、地址、状态码与耗时, 失败或状态码 >= 400 时使用 Warn 级别
+func Logger(logger *logrus.Logger) Middleware {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req)
+			entry := logger.WithFields(logrus.Fields{
+				"method":  req.Method,
+				"url":     req.URL.String(),
+				"latency": time.Since(start).String(),
+			})
+			if id := req.Header.Get(HeaderKeyRequestID); id != "" {
+				entry = entry.WithField("request_id", id)
+			}
+			switch {
+			case err != nil:
+				entry.WithError(err).Warn("xhttp: request failed")
+			case resp.StatusCode >= http.StatusBadRequest:
+				entry.WithField("status", resp.StatusCode).Warn("xhttp: request")
+			default:
+				entry.WithField("status", resp.StatusCode).Info("xhttp: request")
+			}
+			return resp, err
+		})
+	}
+}
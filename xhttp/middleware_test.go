@@ -0,0 +1,94 @@
+package xhttp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderKeyRequestID, r.Header.Get(HeaderKeyRequestID))
+		w.Write([]byte(r.Header.Get(HeaderKeyAuthorization)))
+	}))
+	defer server.Close()
+
+	order := make([]string, 0)
+	trace := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*Response, error) {
+				order = append(order, name+" before")
+				resp, err := next.Do(req)
+				order = append(order, name+" after "+resp.String())
+				return resp, err
+			})
+		}
+	}
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+
+	client := New(
+		WithMiddleware(trace("a"), trace("b")),
+		WithMiddleware(
+			RequestID(func() string { return "req-1" }),
+			StaticHeader(http.Header{"authorization": {"Bearer token"}}),
+			Logger(logger),
+		),
+	)
+	resp, err := client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer token", resp.String())
+	assert.Equal(t, "req-1", resp.Header.Get(HeaderKeyRequestID))
+	assert.Equal(t, []string{"a before", "b before", "b after Bearer token", "a after Bearer token"}, order)
+	assert.True(t, strings.Contains(buf.String(), "request_id=req-1"))
+	assert.True(t, strings.Contains(buf.String(), "status=200"))
+
+	// 请求中已有的字段不覆盖
+	header := http.Header{}
+	header.Set(HeaderKeyAuthorization, "Basic abc")
+	header.Set(HeaderKeyRequestID, "req-2")
+	resp, err = client.Get(ctx, server.URL, header)
+	assert.Nil(t, err)
+	assert.Equal(t, "Basic abc", resp.String())
+	assert.Equal(t, "req-2", resp.Header.Get(HeaderKeyRequestID))
+
+	// 其他 Client 不受影响
+	resp, err = New().Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", resp.String())
+}
+
+func TestMiddlewareError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	_, err := New(WithMiddleware(Logger(logger))).Get(ctx, url, nil)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(buf.String(), "level=warning"))
+}
+
+func TestMiddlewareNoResponse(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	client := New(WithCurlDebug(logger), WithMiddleware(func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*Response, error) {
+			return nil, nil
+		})
+	}))
+	resp, err := client.Get(ctx, "http://127.0.0.1/", nil)
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, ErrNoResponse))
+	assert.Contains(t, buf.String(), "xhttp: request failed")
+}
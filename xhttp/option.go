@@ -4,15 +4,25 @@ import (
 	"net"
 	"net/http"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // Option ...
 type Option struct {
-	RetryTimes     int             // 请求重试次数
-	TraceEnable    bool            // Trace 开关
-	RequestTimeout time.Duration   // 请求超时
-	Dialer         *net.Dialer     // dialer 配置
-	Transport      *http.Transport // transport 配置
+	RetryTimes     int                        // 请求次数, 包含第一次请求
+	RetryPolicy    RetryPolicy                // 重试策略, 为空时按 RetryTimes 使用 NewBackoff
+	TraceEnable    bool                       // Trace 开关
+	RequestTimeout time.Duration              // 请求超时
+	Dialer         *net.Dialer                // dialer 配置
+	Transport      *http.Transport            // transport 配置
+	RoundTripper   http.RoundTripper          // 自定义 RoundTripper, 优先于 Transport
+	Middlewares    []Middleware               // 请求中间件
+	CurlRedact     []string                   // 导出 curl 命令时隐藏的请求头
+	CurlLogger     *logrus.Logger             // 请求失败时输出 curl 命令
+	Breaker        *BreakerOption             // 按 host 熔断
+	RateLimit      *RateLimitOption           // 按 host 限流
+	HostRateLimits map[string]RateLimitOption // 按 host 单独限流, 优先于 RateLimit
 }
 
 // ----------------------------------------------------------------
@@ -27,7 +37,7 @@ func WithTraceEnable() OptionFn {
 	}
 }
 
-// WithRetryTimes 重试次数
+// WithRetryTimes 请求次数, 使用默认的指数退避策略
 func WithRetryTimes(v int) OptionFn {
 	return func(o *Option) {
 		if v > 0 {
@@ -36,6 +46,13 @@ func WithRetryTimes(v int) OptionFn {
 	}
 }
 
+// WithRetryPolicy 自定义重试策略, 优先于 WithRetryTimes
+func WithRetryPolicy(v RetryPolicy) OptionFn {
+	return func(o *Option) {
+		o.RetryPolicy = v
+	}
+}
+
 // WithRequestTimeout 请求超时
 func WithRequestTimeout(v time.Duration) OptionFn {
 	return func(o *Option) {
@@ -57,11 +74,80 @@ func WithTransport(v *http.Transport) OptionFn {
 	}
 }
 
+// WithRoundTripper 如录制回放使用的 Recorder
+func WithRoundTripper(v http.RoundTripper) OptionFn {
+	return func(o *Option) {
+		o.RoundTripper = v
+	}
+}
+
+// WithMiddleware 追加请求中间件, 先注册的在外层
+func WithMiddleware(v ...Middleware) OptionFn {
+	return func(o *Option) {
+		o.Middlewares = append(o.Middlewares[:len(o.Middlewares):len(o.Middlewares)], v...)
+	}
+}
+
+// WithCurlRedact 导出 curl 命令时隐藏的请求头, 如 Authorization、Cookie
+func WithCurlRedact(v ...string) OptionFn {
+	return func(o *Option) {
+		o.CurlRedact = append(o.CurlRedact[:len(o.CurlRedact):len(o.CurlRedact)], v...)
+	}
+}
+
+// WithCurlDebug 请求失败或状态码 >= 400 时输出 curl 命令, 方便复现
+func WithCurlDebug(v *logrus.Logger) OptionFn {
+	return func(o *Option) {
+		if v == nil {
+			v = logrus.StandardLogger()
+		}
+		o.CurlLogger = v
+	}
+}
+
+// WithCircuitBreaker 按 host 熔断, 熔断期间返回 *BreakerError
+func WithCircuitBreaker(v BreakerOption) OptionFn {
+	return func(o *Option) {
+		o.Breaker = &v
+	}
+}
+
+// WithRateLimit 每个 host 使用独立的令牌桶
+func WithRateLimit(v RateLimitOption) OptionFn {
+	return func(o *Option) {
+		o.RateLimit = &v
+	}
+}
+
+// WithHostRateLimit 指定 host 的限流, host 包含端口时需要一致
+func WithHostRateLimit(host string, v RateLimitOption) OptionFn {
+	return func(o *Option) {
+		hosts := make(map[string]RateLimitOption, len(o.HostRateLimits)+1)
+		for k, vv := range o.HostRateLimits {
+			hosts[k] = vv
+		}
+		hosts[host] = v
+		o.HostRateLimits = hosts
+	}
+}
+
 // ----------------------------------------------------------------
 
 // defaultOption ...
 var defaultOption *Option
 
+// newOption 复制默认配置, 避免修改影响其他 Client
+func newOption(options ...OptionFn) *Option {
+	o := *defaultOption
+	for _, fn := range options {
+		fn(&o)
+	}
+	if o.RetryPolicy == nil {
+		o.RetryPolicy = NewBackoff(o.RetryTimes)
+	}
+	return &o
+}
+
 func init() {
 	defaultOption = &Option{
 		RetryTimes:     1,
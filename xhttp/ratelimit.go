@@ -0,0 +1,172 @@
+package xhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited 令牌不足且不等待, 或等待时间超过 ctx 的截止时间
+var ErrRateLimited = errors.New("xhttp: rate limited")
+
+// RateLimitOption 令牌桶限流, 每个 host 独立计算
+type RateLimitOption struct {
+	Rate  float64 // 每秒生成的令牌数
+	Burst int     // 桶容量, 默认为 1
+	Wait  bool    // 令牌不足时等待, 否则直接返回 ErrRateLimited
+}
+
+// RateLimitSnapshot 限流状态快照
+type RateLimitSnapshot struct {
+	Host   string  `json:"host"`
+	Rate   float64 `json:"rate"`
+	Burst  int     `json:"burst"`
+	Tokens float64 `json:"tokens"` // 当前可用令牌, 有请求等待时为负数
+}
+
+// RateLimits 各 host 的限流状态, 未开启时为空
+func (t *Client) RateLimits() []RateLimitSnapshot {
+	if t.limiters == nil {
+		return nil
+	}
+	return t.limiters.snapshot()
+}
+
+// ----------------------------------------------------------------
+
+// limiterGroup ...
+type limiterGroup struct {
+	mu      sync.Mutex
+	option  *RateLimitOption           // 默认配置, 为空时只限制 hosts 中的 host
+	hosts   map[string]RateLimitOption // 按 host 单独配置
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// newLimiterGroup ...
+func newLimiterGroup(option *RateLimitOption, hosts map[string]RateLimitOption) *limiterGroup {
+	return &limiterGroup{
+		option:  option,
+		hosts:   hosts,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// get 没有配置的 host 返回 nil
+func (g *limiterGroup) get(host string) *bucket {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, ok := g.buckets[host]; ok {
+		return b
+	}
+	o, ok := g.hosts[host]
+	if !ok {
+		if g.option == nil {
+			return nil
+		}
+		o = *g.option
+	}
+	if o.Burst <= 0 {
+		o.Burst = 1
+	}
+	b := &bucket{option: o, tokens: float64(o.Burst), last: g.now()}
+	g.buckets[host] = b
+	return b
+}
+
+// middleware ...
+func (g *limiterGroup) middleware(next Doer) Doer {
+	return DoerFunc(func(req *http.Request) (*Response, error) {
+		b := g.get(req.URL.Host)
+		if b == nil || b.option.Rate <= 0 {
+			return next.Do(req)
+		}
+		wait, ok := b.reserve(g.now())
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrRateLimited, req.URL.Host)
+		}
+		if wait > 0 {
+			ctx := req.Context()
+			if deadline, ok := ctx.Deadline(); ok && g.now().Add(wait).After(deadline) {
+				b.cancel()
+				return nil, fmt.Errorf("%w: %s", ErrRateLimited, req.URL.Host)
+			}
+			if err := sleep(ctx, wait); err != nil {
+				b.cancel()
+				return nil, err
+			}
+		}
+		return next.Do(req)
+	})
+}
+
+// snapshot ...
+func (g *limiterGroup) snapshot() []RateLimitSnapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	res := make([]RateLimitSnapshot, 0, len(g.buckets))
+	for host, b := range g.buckets {
+		b.mu.Lock()
+		b.refill(now)
+		res = append(res, RateLimitSnapshot{
+			Host:   host,
+			Rate:   b.option.Rate,
+			Burst:  b.option.Burst,
+			Tokens: b.tokens,
+		})
+		b.mu.Unlock()
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Host < res[j].Host })
+	return res
+}
+
+// ----------------------------------------------------------------
+
+// bucket 令牌桶, 等待模式下令牌可以为负数, 表示已预约的请求
+type bucket struct {
+	mu     sync.Mutex
+	option RateLimitOption
+	tokens float64
+	last   time.Time
+}
+
+// reserve 取出一个令牌, 返回需要等待的时间
+func (b *bucket) reserve(now time.Time) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens < 1 && !b.option.Wait {
+		return 0, false
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0, true
+	}
+	return time.Duration(-b.tokens / b.option.Rate * float64(time.Second)), true
+}
+
+// cancel 归还未使用的令牌
+func (b *bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if burst := float64(b.option.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+}
+
+// refill ...
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.option.Rate
+		if burst := float64(b.option.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+}
@@ -0,0 +1,68 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// 不等待
+	client := New(WithRateLimit(RateLimitOption{Rate: 1, Burst: 2}))
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(ctx, server.URL, nil)
+		assert.Nil(t, err)
+	}
+	_, err := client.Get(ctx, server.URL, nil)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	snapshots := client.RateLimits()
+	assert.Equal(t, 1, len(snapshots))
+	assert.Equal(t, mustHost(server.URL), snapshots[0].Host)
+	assert.Less(t, snapshots[0].Tokens, 1.0)
+
+	// 等待
+	client = New(WithRateLimit(RateLimitOption{Rate: 20, Burst: 1, Wait: true}))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(ctx, server.URL, nil)
+		assert.Nil(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	// 等待时间超过截止时间时直接返回
+	client = New(WithRateLimit(RateLimitOption{Rate: 0.1, Burst: 1, Wait: true}))
+	_, err = client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = client.Get(timeout, server.URL, nil)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.InDelta(t, 0, client.RateLimits()[0].Tokens, 0.01)
+}
+
+func TestHostRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+
+	client := New(WithHostRateLimit(mustHost(server.URL), RateLimitOption{Rate: 1}))
+	_, err := client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	_, err = client.Get(ctx, server.URL, nil)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	for i := 0; i < 3; i++ {
+		_, err = client.Get(ctx, other.URL, nil)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, len(client.RateLimits()))
+	assert.Nil(t, New().RateLimits())
+}
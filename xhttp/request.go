@@ -0,0 +1,47 @@
+package xhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// Request 请求, 保留原始数据用于导出 curl 命令
+type Request struct {
+	*http.Request
+
+	data   interface{}
+	redact []string
+}
+
+// NewRequest 按 Content-Type 构建请求体, header 为空时默认为 JSON, 不会修改传入的 header
+func NewRequest(ctx context.Context, method, url string, header http.Header, data interface{}) (*Request, error) {
+	if header == nil {
+		header = http.Header{}
+		header.Set(HeaderKeyContentType, HeaderKeyContentTypeValueJSON)
+	} else {
+		header = header.Clone()
+	}
+	var body io.Reader
+	m, ok := data.(map[string]interface{})
+	if ok && header.Get(HeaderKeyContentType) == HeaderKeyContentTypeValueFormData {
+		header, body = BuildFormData(header, m)
+	} else if data != nil {
+		body = BuildReader(data, header.Get(HeaderKeyContentType))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return &Request{
+		Request: req,
+		data:    data,
+	}, nil
+}
+
+// Redact 导出 curl 命令时隐藏的请求头
+func (t *Request) Redact(headers ...string) *Request {
+	t.redact = append(t.redact[:len(t.redact):len(t.redact)], headers...)
+	return t
+}
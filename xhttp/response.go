@@ -8,8 +8,9 @@ import (
 type Response struct {
 	*http.Response
 
-	trace *Trace
-	body  []byte
+	trace   *Trace
+	body    []byte
+	request *Request
 }
 
 // Bytes ...
@@ -53,3 +54,11 @@ func (t *Response) Trace() *Trace {
 	}
 	return t.trace
 }
+
+// Curl 导出请求对应的 curl 命令
+func (t *Response) Curl() string {
+	if t.request == nil {
+		return ""
+	}
+	return t.request.Curl()
+}
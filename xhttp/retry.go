@@ -0,0 +1,162 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 重试策略, 第 attempt 次请求结束后判断是否重试, 返回重试前的等待时间
+type RetryPolicy interface {
+	Retry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool)
+}
+
+// Backoff 指数退避重试, 只重试幂等请求
+//
+//	xhttp.New(xhttp.WithRetryPolicy(&xhttp.Backoff{
+//	    Attempts:    5,
+//	    BaseDelay:   200 * time.Millisecond,
+//	    MaxDelay:    5 * time.Second,
+//	    StatusCodes: []int{429, 503},
+//	}))
+type Backoff struct {
+	Attempts    int           // 最多请求次数, 包含第一次请求
+	BaseDelay   time.Duration // 第一次重试前的等待时间, 之后每次翻倍
+	MaxDelay    time.Duration // 等待时间上限, Retry-After 超过上限时不再重试
+	Jitter      float64       // 随机抖动比例 [0, 1], 避免多个客户端同时重试
+	StatusCodes []int         // 需要重试的状态码
+	Methods     []string      // 允许重试的请求方法, 带有 Idempotency-Key 的请求不受限制
+}
+
+// NewBackoff 默认重试 429/502/503/504 与网络错误, 间隔 100ms 起, 最多 10s
+func NewBackoff(attempts int) *Backoff {
+	return &Backoff{
+		Attempts:  attempts,
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  10 * time.Second,
+		Jitter:    0.2,
+		StatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		Methods: []string{MethodGet, MethodHead, MethodOptions, MethodPut, MethodDelete, http.MethodTrace},
+	}
+}
+
+// Retry ...
+func (t *Backoff) Retry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= t.Attempts || !t.allowMethod(req) {
+		return 0, false
+	}
+	if err != nil {
+		// 调用方取消或超时不再重试
+		if req.Context().Err() != nil || errors.Is(err, context.Canceled) {
+			return 0, false
+		}
+		return t.delay(attempt), true
+	}
+	if resp == nil || !t.retryStatus(resp.StatusCode) {
+		return 0, false
+	}
+	if wait, ok := RetryAfter(resp); ok {
+		if t.MaxDelay > 0 && wait > t.MaxDelay {
+			return 0, false
+		}
+		return wait, true
+	}
+	return t.delay(attempt), true
+}
+
+// delay BaseDelay * 2^(attempt-1), 按 Jitter 随机减少
+func (t *Backoff) delay(attempt int) time.Duration {
+	d := t.BaseDelay
+	for i := 1; i < attempt && (t.MaxDelay <= 0 || d < t.MaxDelay); i++ {
+		d *= 2
+	}
+	if t.MaxDelay > 0 && d > t.MaxDelay {
+		d = t.MaxDelay
+	}
+	if t.Jitter > 0 {
+		d -= time.Duration(float64(d) * t.Jitter * rand.Float64())
+	}
+	return d
+}
+
+// allowMethod ...
+func (t *Backoff) allowMethod(req *http.Request) bool {
+	if req.Header.Get(HeaderKeyIdempotencyKey) != "" {
+		return true
+	}
+	for _, m := range t.Methods {
+		if m == req.Method {
+			return true
+		}
+	}
+	return false
+}
+
+// retryStatus ...
+func (t *Backoff) retryStatus(code int) bool {
+	for _, c := range t.StatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryAfter 解析 Retry-After, 支持秒数与 HTTP 日期
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get(HeaderKeyRetryAfter)
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// ----------------------------------------------------------------
+
+// rewindBody 重试前通过 GetBody 重新获取请求体, 无法重放时返回 false
+func rewindBody(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	req.Body = body
+	return true
+}
+
+// sleep 等待期间可以被 ctx 取消
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
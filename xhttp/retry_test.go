@@ -0,0 +1,130 @@
+package xhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRetryPolicy(attempts int) *Backoff {
+	p := NewBackoff(attempts)
+	p.BaseDelay = time.Millisecond
+	return p
+}
+
+func TestRetryStatus(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New(WithTraceEnable(), WithRetryPolicy(newRetryPolicy(3)))
+	resp, err := client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", resp.String())
+	assert.Equal(t, 3, resp.Trace().RequestAttempt)
+
+	// 次数用尽后返回最后一次响应
+	atomic.StoreInt32(&count, -10)
+	resp, err = client.Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(-7), atomic.LoadInt32(&count))
+}
+
+func TestRetryBodyReplay(t *testing.T) {
+	var count int32
+	bodies := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	client := New(WithRetryPolicy(newRetryPolicy(3)))
+	// POST 默认不重试
+	_, err := client.Post(ctx, server.URL, nil, map[string]interface{}{"id": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	<-bodies
+
+	atomic.StoreInt32(&count, 0)
+	header := http.Header{}
+	header.Set(HeaderKeyIdempotencyKey, "order-1")
+	resp, err := client.Post(ctx, server.URL, header, `{"id":1}`)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, `{"id":1}`, <-bodies)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.Header().Set(HeaderKeyRetryAfter, "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := New(WithRetryPolicy(newRetryPolicy(2))).Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// 超过 MaxDelay 时不等待
+	atomic.StoreInt32(&count, 0)
+	policy := newRetryPolicy(2)
+	policy.MaxDelay = 100 * time.Millisecond
+	resp, err = New(WithRetryPolicy(policy)).Get(ctx, server.URL, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	d, ok := RetryAfter(&http.Response{Header: http.Header{HeaderKeyRetryAfter: {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}})
+	assert.True(t, ok)
+	assert.InDelta(t, float64(time.Hour), float64(d), float64(2*time.Second))
+}
+
+func TestBackoffDelay(t *testing.T) {
+	p := NewBackoff(10)
+	p.Jitter = 0
+	assert.Equal(t, 100*time.Millisecond, p.delay(1))
+	assert.Equal(t, 400*time.Millisecond, p.delay(3))
+	assert.Equal(t, 10*time.Second, p.delay(9))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.delay(2)
+		assert.True(t, d > 100*time.Millisecond && d <= 200*time.Millisecond)
+	}
+}
+
+func TestRetryTransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	client := New(WithTraceEnable(), WithRetryPolicy(newRetryPolicy(3)))
+	_, err := client.Get(ctx, url, nil)
+	assert.NotNil(t, err)
+
+	// 默认配置不受其他 Client 影响
+	assert.Equal(t, 1, defaultOption.RetryTimes)
+	assert.Nil(t, defaultOption.RetryPolicy)
+}